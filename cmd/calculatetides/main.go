@@ -7,18 +7,44 @@ import (
 	"os"
 	"time"
 
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/lpeqomt"
 	"github.com/mzeiher/perth3-go/pkg/solver"
+	"github.com/mzeiher/perth3-go/pkg/solver/prediction"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
 	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
 	"github.com/mzeiher/perth3-go/pkg/tidedatums"
+	"github.com/mzeiher/perth3-go/pkg/tideextrema"
+	"github.com/mzeiher/perth3-go/pkg/utils"
 )
 
 const supportedSolvers = "Supported solver:\n" +
 	"perth3 - perth3 solver from the dtu\n"
 
+const supportedLongPeriodTerms = "Supported long period equilibrium terms:\n" +
+	"perth3 - the 15 terms > 1mm used by perth3.f (default)\n" +
+	"cte    - all terms > 0.1mm of the Cartwright-Tayler-Edden tables\n"
+
+const supportedEpochs = "Supported datum epochs:\n" +
+	"2000-2020     - 2000-01-01 to 2020-01-01 in 15 minute steps\n" +
+	"nodalcycle    - full nodal cycle (18.61 years) from 2000-01-01 in 15 minute steps\n" +
+	"ntde1983-2001 - NOAA National Tidal Datum Epoch 1983-2001 in 6 minute steps\n"
+
+const supportedInterpolations = "Supported interpolation methods:\n" +
+	"bilinear - weighted mean of the 4 surrounding grid points (default)\n" +
+	"bicubic  - cubic convolution of the 16 surrounding grid points, bilinear at the coast\n" +
+	"nearest  - value of the nearest grid point\n"
+
 func main() {
 
 	var constituentDbPath string
-	flag.StringVar(&constituentDbPath, "constituentdb", "", "Path to constituentdb")
+	flag.StringVar(&constituentDbPath, "constituentdb", "", "Path to constituentdb, nested databases are used with all their layers")
+
+	var stationDbPath string
+	flag.StringVar(&stationDbPath, "stationdb", "", "Path to stationdb (optional, requires station)")
+
+	var stationId string
+	flag.StringVar(&stationId, "station", "", "id of the station in the stationdb to calculate the tide for instead of \"lat,lon\"")
 
 	var tideDataCache string
 	flag.StringVar(&tideDataCache, "tideDataCache", "", "Path to tide data cache (optional)")
@@ -32,9 +58,39 @@ func main() {
 	var stepDurationString string
 	flag.StringVar(&stepDurationString, "stepduration", "60s", "step duration in seconds")
 
+	var oceanSearchRadius float64
+	flag.Float64Var(&oceanSearchRadius, "oceansearchradius", 0, "radius in degree to search for the nearest ocean data if there is none at the location (optional)")
+
+	var interpolationString string
+	flag.StringVar(&interpolationString, "interpolation", "bilinear", "method to interpolate the constituent grids (bilinear, bicubic or nearest)")
+
 	var solverString string
 	flag.StringVar(&solverString, "solver", "perth3", "solver to use")
 
+	var longPeriodTermsString string
+	flag.StringVar(&longPeriodTermsString, "lpeqterms", "perth3", "terms of the long period equilibrium tide (perth3 or cte)")
+
+	var longPeriodConstituents bool
+	flag.BoolVar(&longPeriodConstituents, "lpconstituents", false, "use the long period constituents of the station or db (SA, SSA, MM, MF, MSF, MTM, MSQM) instead of their long period equilibrium terms")
+
+	var epochString string
+	flag.StringVar(&epochString, "epoch", "2000-2020", "datum epoch to calculate the tide datums for")
+
+	var epochStartString string
+	flag.StringVar(&epochStartString, "epochstart", "", "start of a custom datum epoch in rfc3339 format (optional, requires epochend)")
+
+	var epochEndString string
+	flag.StringVar(&epochEndString, "epochend", "", "end of a custom datum epoch in rfc3339 format (optional)")
+
+	var epochStepString string
+	flag.StringVar(&epochStepString, "epochstep", "15m", "step duration of a custom datum epoch")
+
+	var highLow bool
+	flag.BoolVar(&highLow, "highlow", false, "print the high and low waters between start and end time instead of the tide for each step")
+
+	var breakdown bool
+	flag.BoolVar(&breakdown, "breakdown", false, "print the contribution of each constituent for each step")
+
 	var help bool
 	flag.BoolVar(&help, "help", false, "print help")
 
@@ -44,19 +100,6 @@ func main() {
 		printHelpAndExit(nil)
 	}
 
-	var lat, lon float32
-	_, err := fmt.Sscanf(flag.Arg(0), "%f,%f", &lat, &lon)
-	if err != nil {
-		printHelpAndExit(err)
-	}
-
-	// load constituent db for lookup
-	constituentDb, err := tidedatadb.OpenTideDataDb(constituentDbPath, tidedatadb.MODE_READONLY)
-	if err != nil {
-		printHelpAndExit(err)
-	}
-	defer constituentDb.Close()
-
 	// parse start/end time and duration
 	startTime, err := time.Parse(time.RFC3339, startTimeString)
 	if err != nil {
@@ -85,48 +128,170 @@ func main() {
 		printHelpAndExit(err)
 	}
 
-	solverFunc, err := solver.GetSolver(solverType)
+	longPeriodTerms, err := lpeqomt.GetTermSetFromString(longPeriodTermsString)
 	if err != nil {
 		printHelpAndExit(err)
 	}
+	solverOptions := []solver.Option{solver.WithLongPeriodTerms(longPeriodTerms)}
+	if longPeriodConstituents {
+		solverOptions = append(solverOptions, solver.WithLongPeriodConstituents())
+	}
 
-	tideDatums, err := tidedatums.GetDatumsForLatLan(constituentDb, solverType, lat, lon)
+	interpolationMethod, err := utils.InterpolationMethodFromString(interpolationString)
 	if err != nil {
-		panic(err)
+		printHelpAndExit(err)
 	}
 
-	currentTime := startTimeUTC
+	epoch, err := tidedatums.GetEpochFromString(epochString)
+	if err != nil {
+		printHelpAndExit(err)
+	}
+	if epochStartString != "" || epochEndString != "" {
+		epoch, err = parseCustomEpoch(epochStartString, epochEndString, epochStepString)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+	}
 
+	// the harmonic constants of the station or interpolated from the constituent db
+	var provider constituents.HarmonicConstantsProvider
+	var lat, lon float32
+	if stationId != "" {
+		if stationDbPath == "" {
+			printHelpAndExit(errors.New("station needs a stationdb"))
+		}
+		stationDb, err := stationdb.OpenStationDb(stationDbPath, stationdb.MODE_READONLY)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		defer stationDb.Close()
+		station, err := stationDb.GetStation(stationId)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+
+		fmt.Printf("%-10s %s %s (%.4f,%.4f)\n", "Station", station.ID, station.Name, station.Lat, station.Lon)
+		provider = station
+		lat, lon = station.Lat, station.Lon
+	} else {
+		_, err := fmt.Sscanf(flag.Arg(0), "%f,%f", &lat, &lon)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+
+		// load constituent db for lookup
+		constituentDb, err := tidedatadb.OpenCompositeTideDataDb(constituentDbPath, tidedatadb.MODE_READONLY)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		defer constituentDb.Close()
+		constituentDb.SetNearestOceanSearchRadius(float32(oceanSearchRadius))
+		constituentDb.SetInterpolationMethod(interpolationMethod)
+		provider = constituentDb
+	}
+
+	seriesSolverFunc, err := solver.GetDetailedSeriesSolver(solverType)
+	if err != nil {
+		printHelpAndExit(err)
+	}
+
+	tideDatums, err := tidedatums.GetDatumsForLatLan(provider, solverType, lat, lon, epoch, solverOptions...)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%-10s %s (%s - %s, %s)\n", "Epoch", tideDatums.Epoch.Name, tideDatums.Epoch.Start.Format(time.RFC3339), tideDatums.Epoch.End.Format(time.RFC3339), tideDatums.Epoch.Step)
 	fmt.Printf("%-10s %10.4fcm\n", "LAT", tideDatums.LAT)
+	fmt.Printf("%-10s %10.4fcm\n", "MLWS", tideDatums.MLWS)
+	fmt.Printf("%-10s %10.4fcm\n", "MLW", tideDatums.MLW)
+	fmt.Printf("%-10s %10.4fcm\n", "MLWN", tideDatums.MLWN)
 	fmt.Printf("%-10s %10.4fcm\n", "MSL", tideDatums.MSL)
+	fmt.Printf("%-10s %10.4fcm\n", "MHWN", tideDatums.MHWN)
+	fmt.Printf("%-10s %10.4fcm\n", "MHW", tideDatums.MHW)
+	fmt.Printf("%-10s %10.4fcm\n", "MHWS", tideDatums.MHWS)
 	fmt.Printf("%-10s %10.4fcm\n", "HAT", tideDatums.HAT)
 	fmt.Printf("\n")
-	fmt.Printf("%-25s %-11s %-11s\n", "date", "height (cm)", "tide (cm)")
-	for {
-		tideHeight, err := solverFunc(constituentDb, lat, lon, currentTime)
+
+	if highLow {
+		events, err := tideextrema.FindExtrema(provider, solverType, lat, lon, startTimeUTC, endTimeUTC, solverOptions...)
 		if err != nil {
 			panic(err)
 		}
+		fmt.Printf("%-25s %-10s %-11s %-11s\n", "date", "event", "height (cm)", "tide (cm)")
+		for _, event := range events {
+			tideHeightLAT := event.Height - (float64(tideDatums.LAT - tideDatums.MSL))
+
+			fmt.Printf("%-25s %-10s %11.4f %11.4f\n", event.Time.Local().Format(time.RFC3339), event.Type, tideHeightLAT, event.Height)
+		}
+		return
+	}
 
-		tideHeightLAT := tideHeight - (float64(tideDatums.LAT - tideDatums.MSL))
+	tidePredictions, err := seriesSolverFunc(provider, lat, lon, startTimeUTC, endTimeUTC, stepDuration, solverOptions...)
+	if err != nil {
+		panic(err)
+	}
 
-		fmt.Printf("%-25s %11.4f %11.4f\n", currentTime.Local().Format(time.RFC3339), tideHeightLAT, tideHeight)
+	fmt.Printf("%-25s %-11s %-11s %-12s\n", "date", "height (cm)", "tide (cm)", "rate (cm/h)")
+	for _, tidePrediction := range tidePredictions {
+		tideHeightLAT := tidePrediction.Height - (float64(tideDatums.LAT - tideDatums.MSL))
 
-		currentTime = currentTime.Add(stepDuration)
-		if endTimeUTC.Sub(currentTime) < 0 {
-			return
+		fmt.Printf("%-25s %11.4f %11.4f %12.4f\n", tidePrediction.Time.Local().Format(time.RFC3339), tideHeightLAT, tidePrediction.Height, tidePrediction.Rate)
+		if breakdown {
+			printBreakdown(tidePrediction)
 		}
 	}
 
 }
 
+func printBreakdown(tidePrediction prediction.TidePrediction) {
+	fmt.Printf("    %-8s %-9s %12s %11s %8s %9s %13s %11s\n", "const", "source", "amplitude", "phase", "f", "u", "argument", "height")
+	for _, contribution := range tidePrediction.Constituents {
+		source := "db"
+		if contribution.Inferred {
+			source = "inferred"
+		} else if contribution.Missing {
+			source = "missing"
+		}
+		fmt.Printf("    %-8s %-9s %10.4fcm %10.4f° %8.4f %8.4f° %12.4f° %9.4fcm\n", contribution.Constituent, source, contribution.Amplitude, contribution.Phase, contribution.F, contribution.U, contribution.Argument, contribution.Height)
+	}
+	fmt.Printf("    %-8s %-9s %67.4fcm\n", "LPEQ", "", tidePrediction.LongPeriodEquilibrium)
+	fmt.Printf("\n")
+}
+
+func parseCustomEpoch(startString string, endString string, stepString string) (tidedatums.DatumEpoch, error) {
+	if startString == "" || endString == "" {
+		return tidedatums.DatumEpoch{}, errors.New("custom epoch needs a start and end time")
+	}
+	start, err := time.Parse(time.RFC3339, startString)
+	if err != nil {
+		return tidedatums.DatumEpoch{}, err
+	}
+	end, err := time.Parse(time.RFC3339, endString)
+	if err != nil {
+		return tidedatums.DatumEpoch{}, err
+	}
+	step, err := time.ParseDuration(stepString)
+	if err != nil {
+		return tidedatums.DatumEpoch{}, err
+	}
+	return tidedatums.DatumEpoch{
+		Name:  "custom",
+		Start: start.UTC(),
+		End:   end.UTC(),
+		Step:  step,
+	}, nil
+}
+
 func printHelpAndExit(err error) {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 	}
-	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], " [OPTIONS] \"lat,lon\"")
+	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], " [OPTIONS] \"lat,lon\" | -stationdb STATIONDB -station ID")
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "\n%s", supportedSolvers)
+	fmt.Fprintf(os.Stderr, "\n%s", supportedLongPeriodTerms)
+	fmt.Fprintf(os.Stderr, "\n%s", supportedEpochs)
+	fmt.Fprintf(os.Stderr, "\n%s", supportedInterpolations)
 	if err != nil {
 		os.Exit(-1)
 	} else {
@@ -5,25 +5,63 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
 
 	"github.com/mzeiher/perth3-go/pkg/loader"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
 	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
 )
 
 const supportedFormats = "Supported Formats:\n" +
 	"dtu16ascii - ascii representation of DTU16 files (.fort30)\n" +
 	"             all tide constituents should be concatenated before loading\n" +
-	"             cat q1.d o1.d p1.d s1.d k1.d n2.d m2.d s2.d k2.d m4.d > fort.30\n"
+	"             cat q1.d o1.d p1.d s1.d k1.d n2.d m2.d s2.d k2.d m4.d > fort.30\n" +
+	"tpxo       - TPXO elevation files from OSU, either OTIS binary (h_*.out) or NetCDF (h_*.nc)\n" +
+	"             the complex elevations hRe/hIm are converted to amplitude and phase\n" +
+	"fes        - FES2014/FES2022 NetCDF files with one file per constituent (e.g. m2.nc)\n" +
+	"             INPUT is a directory or a glob pattern, e.g. \"./fes2014/ocean_tide/*.nc\"\n" +
+	"got        - ascii grids of the GOT4.x/GOT5 models (.d), amplitude and phase in one or separate files\n" +
+	"             INPUT is a file, a directory or a glob pattern, e.g. \"./got4.10c/grids_oceantide/*.d\"\n" +
+	"adcirc     - harmonic constants of ADCIRC at the nodes of its triangular mesh (fort.53), the mesh is\n" +
+	"             read from fort.14 in the same directory, INPUT is the fort.53 file or the directory\n" +
+	"tidedb     - an existing constituent database, e.g. to extract a region with -bbox or to convert the database format\n" +
+	"\n" +
+	"gzip (.gz), bzip2 (.bz2) and zip archives with a single file are decompressed while reading,\n" +
+	"compressed NetCDF files are decompressed into memory (no temporary file), the decompressed file must fit into memory\n" +
+	"\n" +
+	"Database Formats:\n" +
+	"binary     - chunked binary file, no dependencies (default)\n" +
+	"netcdf     - NetCDF-4 file, default for OUTPUT files ending with .nc if the build supports NetCDF\n" +
+	"\n" +
+	"With -merge all INPUTs must be constituent databases, they are nested into a single binary database in the\n" +
+	"order of increasing priority, e.g. a global model followed by regional models:\n" +
+	"             createconstituentdb -merge -blend 0.5 ./dtu16.db ./northsea.db ./nested.db\n"
+
+// input format for existing constituent databases
+const FORMAT_TIDEDB = "tidedb"
 
 // this command line utility creates a lookup database for the sin and cos components of
 // the provided constituents.
 //
-// currently only the ASCII format used by the DTU-10/16 model is supported.
+// supported are the ASCII format used by the DTU-10/16 model, the TPXO elevation files,
+// the FES atlas and the ascii grids of the GOT models.
 func main() {
 
 	var format string
-	flag.StringVar(&format, "format", "dtu16ascii", "format of input file")
+	flag.StringVar(&format, "format", "", "format of input file (optional, detected from the content if empty)")
+
+	var dbFormat string
+	flag.StringVar(&dbFormat, "dbformat", "", "format of the output database, binary or netcdf (optional, by the file extension if empty)")
+
+	var bbox string
+	flag.StringVar(&bbox, "bbox", "", "only copy the grid points within minLat,minLon,maxLat,maxLon (optional), minLon > maxLon crosses the antimeridian")
+
+	var merge bool
+	flag.BoolVar(&merge, "merge", false, "nest several constituent databases into one, the last INPUT has the highest priority")
+
+	var blendWidth float64
+	flag.Float64Var(&blendWidth, "blend", 0, "width in degree of the border in which merged databases are blended with the databases below (optional)")
 
 	var help bool
 	flag.BoolVar(&help, "help", false, "print help")
@@ -34,11 +72,17 @@ func main() {
 		printHelpAndExit(nil)
 	}
 
-	if format == "" {
-		printHelpAndExit(errors.New("format option missing"))
-	}
-	if format != "dtu16ascii" {
-		printHelpAndExit(errors.New("invalid format"))
+	if merge {
+		if flag.NArg() < 3 {
+			printHelpAndExit(errors.New("merge needs at least two INPUT files and an OUTPUT file"))
+		}
+		if bbox != "" {
+			printHelpAndExit(errors.New("bbox can not be combined with merge, extract the regions first"))
+		}
+		if dbFormat != "" && tidedatadb.DbFormat(dbFormat) != tidedatadb.FORMAT_BINARY {
+			printHelpAndExit(tidedatadb.ErrNestedDbNotSupported)
+		}
+		mergeTideDbs(flag.Args()[:flag.NArg()-1], flag.Arg(flag.NArg()-1), float32(blendWidth))
 		return
 	}
 
@@ -49,17 +93,58 @@ func main() {
 		printHelpAndExit(errors.New("must provide an INPUT and OUTPUT file"))
 	}
 
+	var boundingBox *tidedatadb.BoundingBox
+	if bbox != "" {
+		parsedBox, err := tidedatadb.ParseBoundingBox(bbox)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		boundingBox = &parsedBox
+	}
+
+	if format == "" && tidedatadb.IsTideDataDb(inFile) {
+		format = FORMAT_TIDEDB
+		fmt.Printf("detected format %s\n", format)
+	} else if format == "" {
+		detectedFormat, err := loader.DetectFormat(inFile)
+		if err != nil {
+			printHelpAndExit(fmt.Errorf("%w, use the format option", err))
+		}
+		format = detectedFormat
+		fmt.Printf("detected format %s\n", format)
+	}
+
+	if format == FORMAT_TIDEDB {
+		tideDbReader, err := tidedatadb.OpenTideDataDb(inFile, tidedatadb.MODE_READONLY)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		defer tideDbReader.Close()
+		tideDbWriter := openOutput(outFile, dbFormat)
+		defer tideDbWriter.Close()
+
+		if boundingBox != nil {
+			err = tideDbReader.CopySubset(tideDbWriter, *boundingBox)
+		} else {
+			err = tideDbReader.CopyTo(tideDbWriter)
+		}
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		return
+	}
+
 	constituentReader, err := loader.GetLoader(format, inFile)
 	if err != nil {
 		printHelpAndExit(err)
 	}
 	defer constituentReader.Close()
-	tideDbWriter, err := tidedatadb.OpenTideDataDb(outFile, 0)
-	if err != nil {
-		printHelpAndExit(err)
-	}
+	tideDbWriter := openOutput(outFile, dbFormat)
 	defer tideDbWriter.Close()
 
+	// the constituents of a model share the dimensions and the coordinates of their grid
+	var dimensions tidedatadb.Dimensions
+	var previousData *constituentdata.TideConstituentData
 	for {
 		tideDataAmp, err := constituentReader.GetNextConstituentData()
 		if err != nil && errors.Is(err, io.EOF) {
@@ -82,23 +167,29 @@ func main() {
 			tideDataPhase.LatitudeMin != tideDataAmp.LatitudeMin ||
 			tideDataPhase.LatitudeMax != tideDataAmp.LatitudeMax ||
 			tideDataPhase.LongitudeMin != tideDataAmp.LongitudeMin ||
-			tideDataPhase.LongitudeMax != tideDataAmp.LongitudeMax {
+			tideDataPhase.LongitudeMax != tideDataAmp.LongitudeMax ||
+			!equalCoordinates(tideDataPhase.Latitudes, tideDataAmp.Latitudes) ||
+			!equalCoordinates(tideDataPhase.Longitudes, tideDataAmp.Longitudes) ||
+			!equalTriangles(tideDataPhase.Triangles, tideDataAmp.Triangles) {
 			printHelpAndExit(fmt.Errorf("dimensions of amplitude and phase data are not compatible"))
 		}
 
-		constituentEntry, err := tideDbWriter.CreateNewConstituentData(tidedatadb.Dimensions{
-			MinLat:        tideDataAmp.LatitudeMin,
-			MaxLat:        tideDataAmp.LatitudeMax,
-			MinLon:        tideDataAmp.LongitudeMin,
-			MaxLon:        tideDataAmp.LongitudeMax,
-			ResolutionLat: (tideDataAmp.LatitudeMax - tideDataAmp.LatitudeMin) / float32(tideDataAmp.SizeY-1),
-			ResolutionLon: (tideDataAmp.LongitudeMax - tideDataAmp.LongitudeMin) / float32(tideDataAmp.SizeX-1),
-			GridXSize:     uint64(tideDataAmp.SizeX),
-			GridYSize:     uint64(tideDataAmp.SizeY),
-		}, tidedatadb.ConstituentInfo{
+		// with a bounding box the constituent is written to memory first and only the subset is copied
+		targetDb := tideDbWriter
+		if boundingBox != nil {
+			targetDb = tidedatadb.NewMemoryTideDataDb()
+		}
+		dimensions, err = gridDimensions(tideDataAmp, previousData, dimensions)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		previousData = tideDataAmp
+		constituentEntry, err := targetDb.CreateNewConstituentData(dimensions, tidedatadb.ConstituentInfo{
 			Constituent:   tideDataAmp.Constituent,
 			AmplitudeUnit: tidedatadb.UNIT_CM,
 			PhaseUnit:     tidedatadb.UNIT_DEGREE,
+			HasFillValue:  true,
+			FillValue:     tideDataAmp.UndefValue,
 		})
 		if err != nil {
 			printHelpAndExit(err)
@@ -108,21 +199,129 @@ func main() {
 
 		for y := 0; y < tideDataAmp.SizeY; y++ {
 			for x := 0; x < tideDataAmp.SizeX; x++ {
-				err = constituentEntry.WriteDataXY([]float32{tideDataAmp.Data[y][x], tideDataPhase.Data[y][x]}, uint64(x), uint64(y))
+				amplitudePhase := []float32{tideDataAmp.Data[y][x], tideDataPhase.Data[y][x]}
+				// a grid point is undefined if either amplitude or phase is undefined
+				if amplitudePhase[0] == tideDataAmp.UndefValue || amplitudePhase[1] == tideDataPhase.UndefValue {
+					amplitudePhase = []float32{tideDataAmp.UndefValue, tideDataAmp.UndefValue}
+				}
+				// the nodes of a mesh are stored in a grid
+				targetX, targetY := uint64(x), uint64(y)
+				if dimensions.IsMesh() {
+					targetX, targetY = dimensions.NodeXY(uint64(x))
+				}
+				err = constituentEntry.WriteDataXY(amplitudePhase, targetX, targetY)
 				if err != nil {
 					printHelpAndExit(err)
 				}
 			}
 		}
+		if boundingBox != nil {
+			if _, err := constituentEntry.CopySubset(tideDbWriter, *boundingBox); err != nil {
+				printHelpAndExit(err)
+			}
+		}
+	}
+
+}
+
+// nests the dbs into a single db, the priority of the dbs increases with their position
+func mergeTideDbs(inFiles []string, outFile string, blendWidth float32) {
+	layers := []tidedatadb.Layer{}
+	for i, inFile := range inFiles {
+		if !tidedatadb.IsTideDataDb(inFile) {
+			printHelpAndExit(fmt.Errorf("%s is no constituent database, create it first", inFile))
+		}
+		tideDbReader, err := tidedatadb.OpenTideDataDb(inFile, tidedatadb.MODE_READONLY)
+		if err != nil {
+			printHelpAndExit(err)
+		}
+		defer tideDbReader.Close()
+		layer := tidedatadb.Layer{Db: tideDbReader}
+		layer.Priority = i
+		if i > 0 {
+			layer.BlendWidth = blendWidth
+		}
+		layers = append(layers, layer)
+	}
+	if err := tidedatadb.WriteNestedTideDataDb(outFile, layers...); err != nil {
+		printHelpAndExit(err)
+	}
+}
+
+// returns the dimensions of the loaded grid, the dimensions of the previous grid are reused if it has the same coordinates
+func gridDimensions(tideData *constituentdata.TideConstituentData, previousData *constituentdata.TideConstituentData, previous tidedatadb.Dimensions) (tidedatadb.Dimensions, error) {
+	if tideData.Latitudes == nil {
+		return tidedatadb.Dimensions{
+			MinLat:        tideData.LatitudeMin,
+			MaxLat:        tideData.LatitudeMax,
+			MinLon:        tideData.LongitudeMin,
+			MaxLon:        tideData.LongitudeMax,
+			ResolutionLat: (tideData.LatitudeMax - tideData.LatitudeMin) / float32(tideData.SizeY-1),
+			ResolutionLon: (tideData.LongitudeMax - tideData.LongitudeMin) / float32(tideData.SizeX-1),
+			GridXSize:     uint64(tideData.SizeX),
+			GridYSize:     uint64(tideData.SizeY),
+		}, nil
+	}
+	if previousData != nil && previousData.SizeX == tideData.SizeX && previousData.SizeY == tideData.SizeY &&
+		previousData.Curvilinear == tideData.Curvilinear &&
+		equalCoordinates(previousData.Latitudes, tideData.Latitudes) &&
+		equalCoordinates(previousData.Longitudes, tideData.Longitudes) &&
+		equalTriangles(previousData.Triangles, tideData.Triangles) {
+		return previous, nil
+	}
+	if tideData.Triangles != nil {
+		return tidedatadb.NewMeshDimensions(tideData.Latitudes, tideData.Longitudes, tideData.Triangles)
+	} else if tideData.Curvilinear {
+		return tidedatadb.NewCurvilinearDimensions(uint64(tideData.SizeX), uint64(tideData.SizeY), tideData.Latitudes, tideData.Longitudes)
+	}
+	return tidedatadb.NewRectilinearDimensions(tideData.Latitudes, tideData.Longitudes)
+}
+
+func equalCoordinates(a []float32, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		// NaN marks grid points without coordinates
+		if a[i] != b[i] && !(math.IsNaN(float64(a[i])) && math.IsNaN(float64(b[i]))) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalTriangles(a []uint32, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
 	}
+	return true
+}
 
+// opens the output db, an existing db is extended unless the format is given
+func openOutput(outFile string, dbFormat string) *tidedatadb.TideDataDB {
+	var tideDbWriter *tidedatadb.TideDataDB
+	var err error
+	if dbFormat == "" {
+		tideDbWriter, err = tidedatadb.OpenTideDataDb(outFile, tidedatadb.MODE_READWRITE)
+	} else {
+		tideDbWriter, err = tidedatadb.CreateTideDataDb(outFile, tidedatadb.DbFormat(dbFormat))
+	}
+	if err != nil {
+		printHelpAndExit(err)
+	}
+	return tideDbWriter
 }
 
 func printHelpAndExit(err error) {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 	}
-	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], " [OPTIONS] INPUT OUTPUT")
+	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], " [OPTIONS] INPUT OUTPUT | -merge [OPTIONS] INPUT... OUTPUT")
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "\n%s", supportedFormats)
 	if err != nil {
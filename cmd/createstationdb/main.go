@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mzeiher/perth3-go/pkg/loader"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+const supportedFormats = "Supported Formats:\n" +
+	"noaajson - NOAA CO-OPS harmonic constituents (harcon.json) or a station list with expand=harcon\n" +
+	"noaacsv  - csv export of the NOAA CO-OPS harmonic constituents with the columns Name, Amplitude and Phase\n" +
+	"           optional \"# Station ID:\", \"# Station Name:\", \"# Latitude:\", \"# Longitude:\" lines before the header\n" +
+	"iho      - tidal constituent table with STATION, NAME, LAT, LON and UNITS keywords followed by\n" +
+	"           \"CONSTITUENT AMPLITUDE PHASE\" lines, multiple stations per file\n"
+
+// this command line utility adds the harmonic constants of tide stations to a station database,
+// an existing database is extended.
+func main() {
+
+	var format string
+	flag.StringVar(&format, "format", "noaajson", "format of input file")
+
+	var help bool
+	flag.BoolVar(&help, "help", false, "print help")
+
+	flag.Parse()
+
+	if help {
+		printHelpAndExit(nil)
+	}
+
+	inFile := flag.Arg(0)
+	outFile := flag.Arg(1)
+
+	if inFile == "" || outFile == "" {
+		printHelpAndExit(errors.New("must provide an INPUT and OUTPUT file"))
+	}
+
+	stations, err := loader.LoadStations(format, inFile)
+	if err != nil {
+		printHelpAndExit(err)
+	}
+
+	stationDb, err := stationdb.OpenStationDb(outFile, stationdb.MODE_READWRITE)
+	if err != nil {
+		printHelpAndExit(err)
+	}
+
+	for _, station := range stations {
+		if err := stationDb.AddStation(station); err != nil {
+			printHelpAndExit(fmt.Errorf("station %s: %w", station.ID, err))
+		}
+		fmt.Printf("added station %s %s with %d constituents\n", station.ID, station.Name, len(station.Constituents))
+	}
+
+	if err := stationDb.Close(); err != nil {
+		printHelpAndExit(err)
+	}
+}
+
+func printHelpAndExit(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], " [OPTIONS] INPUT OUTPUT")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s", supportedFormats)
+	if err != nil {
+		os.Exit(-1)
+	} else {
+		os.Exit(0)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+This package contains the fixtures shared by the tests of several packages, e.g. input files of the
+loaders and constituent grids for the solvers.
+*/
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+)
+
+// constituents of the perth3 solver in the order of its harmonic constants
+var SolverConstituents = []constituents.Constituent{constituents.C_Q1, constituents.C_O1, constituents.C_P1, constituents.C_K1, constituents.C_N2, constituents.C_M2, constituents.C_S2, constituents.C_K2, constituents.C_S1, constituents.C_M4}
+
+// writes content to the file name in dir and returns its path
+func WriteFile(t testing.TB, dir string, name string, content []byte) string {
+	t.Helper()
+	filePath := filepath.Join(dir, name)
+	if err := os.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+// creates the grids of all SolverConstituents with the amplitude (cm) and phase (degree) returned by value
+// for the constituent at index in SolverConstituents and the grid point x/y
+func CreateConstituentGrids(t testing.TB, tideDataDb *tidedatadb.TideDataDB, dimensions tidedatadb.Dimensions, value func(index int, x uint64, y uint64) (float32, float32)) {
+	t.Helper()
+	for index, constituent := range SolverConstituents {
+		constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, tidedatadb.ConstituentInfo{
+			Constituent:   constituent,
+			AmplitudeUnit: tidedatadb.UNIT_CM,
+			PhaseUnit:     tidedatadb.UNIT_DEGREE,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := uint64(0); y < dimensions.GridYSize; y++ {
+			for x := uint64(0); x < dimensions.GridXSize; x++ {
+				amplitude, phase := value(index, x, y)
+				if err := constituentData.WriteDataXY([]float32{amplitude, phase}, x, y); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
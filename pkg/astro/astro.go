@@ -48,7 +48,7 @@ func ComputeAstronomicalMeanLongitudesInDegree(utcTime time.Time) MeanLongitudes
 	meanLongitudes.L_s = (((-1.53388e-8*et+1.855835e-6)*et-1.5786e-3)*et+481267.88123421)*et + 218.3164477
 
 	// mean elongation of moon (p.338)
-	D := (((-8.8445-9*et+1.83195e-6)*et-1.8819e-3)*et+445267.1114034)*et + 297.8501921
+	D := (((-8.8445e-9*et+1.83195e-6)*et-1.8819e-3)*et+445267.1114034)*et + 297.8501921
 
 	// mean longitude of sun
 	meanLongitudes.L_h = meanLongitudes.L_s - D
@@ -0,0 +1,61 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// mean length of a lunation in days
+const SYNODIC_MONTH = 29.530588853
+
+type MoonPhase string
+
+const (
+	NewMoon        MoonPhase = "NewMoon"        // 0-1	   New Moon
+	WaxingCrescent MoonPhase = "WaxingCrescent" // 2-6	   Waxing Crescent
+	FirstQuarter   MoonPhase = "FirstQuarter"   // 7-8	   First Quarter
+	WaxingGibbous  MoonPhase = "WaxingGibbous"  // 9-13   Waxing Gibbous
+	FullMoon       MoonPhase = "FullMoon"       // 14-15	Full Moon
+	WaningGibbous  MoonPhase = "WaningGibbous"  // 16-20	Waning Gibbous
+	ThirdQuarter   MoonPhase = "ThirdQuarter"   // 21-22	Third Quarter
+	WaningCrescent MoonPhase = "WaningCrescent" // 23-27	Waning Crescent
+)
+
+// returns the mean elongation of the moon from the sun in degree [0,360),
+// 0 is new moon, 90 first quarter, 180 full moon and 270 third quarter
+func GetMoonPhaseAngle(utcTime time.Time) float64 {
+	meanLongitudes := ComputeAstronomicalMeanLongitudesInDegree(utcTime)
+	elongation := math.Mod(meanLongitudes.L_s-meanLongitudes.L_h, 360)
+	if elongation < 0 {
+		elongation = elongation + 360
+	}
+	return elongation
+}
+
+// returns the (mean) age of the moon in days since the last new moon
+func GetMoonAge(utcTime time.Time) float64 {
+	return GetMoonPhaseAngle(utcTime) / 360 * SYNODIC_MONTH
+}
+
+func GetMoonPhase(utcTime time.Time) MoonPhase {
+	age := int(GetMoonAge(utcTime))
+	switch {
+	case age <= 1:
+		return NewMoon
+	case age <= 6:
+		return WaxingCrescent
+	case age <= 8:
+		return FirstQuarter
+	case age <= 13:
+		return WaxingGibbous
+	case age <= 15:
+		return FullMoon
+	case age <= 20:
+		return WaningGibbous
+	case age <= 22:
+		return ThirdQuarter
+	case age <= 27:
+		return WaningCrescent
+	}
+	return NewMoon
+}
@@ -0,0 +1,34 @@
+package astro_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/astro"
+)
+
+func TestGetMoonPhaseAngle(t *testing.T) {
+	// the mean elongation differs from the true elongation by a few degrees
+	const tolerance = 10.0
+	testCases := []struct {
+		time          time.Time
+		expectedAngle float64
+		expectedPhase astro.MoonPhase
+	}{
+		{time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC), 0, astro.NewMoon},
+		{time.Date(2023, 1, 6, 23, 8, 0, 0, time.UTC), 180, astro.FullMoon},
+		{time.Date(2023, 1, 15, 2, 10, 0, 0, time.UTC), 270, astro.ThirdQuarter},
+		{time.Date(2023, 1, 21, 20, 53, 0, 0, time.UTC), 0, astro.NewMoon},
+	}
+	for _, testCase := range testCases {
+		angle := astro.GetMoonPhaseAngle(testCase.time)
+		diff := math.Mod(math.Abs(angle-testCase.expectedAngle), 360)
+		if math.Min(diff, 360-diff) > tolerance {
+			t.Errorf("%s: expected moon phase angle %f, got %f", testCase.time, testCase.expectedAngle, angle)
+		}
+		if phase := astro.GetMoonPhase(testCase.time); phase != testCase.expectedPhase {
+			t.Errorf("%s: expected moon phase %s, got %s", testCase.time, testCase.expectedPhase, phase)
+		}
+	}
+}
@@ -55,6 +55,11 @@ const (
 	C_PI1     Constituent = 103
 	C_PHI1    Constituent = 104
 	C_THETA1  Constituent = 105
+	C_EPS2    Constituent = 106
+	C_MKS2    Constituent = 107
+	C_MSQM    Constituent = 108
+	C_MTM     Constituent = 109
+	C_N4      Constituent = 110
 	// special cases
 	C_UNKNOWN Constituent = 99999
 )
@@ -149,6 +154,16 @@ func (c Constituent) String() string {
 		return "PHI1"
 	case C_THETA1:
 		return "THETA1"
+	case C_EPS2:
+		return "EPS2"
+	case C_MKS2:
+		return "MKS2"
+	case C_MSQM:
+		return "MSQM"
+	case C_MTM:
+		return "MTM"
+	case C_N4:
+		return "N4"
 	}
 	return "UNKNOWN"
 }
@@ -244,6 +259,16 @@ func FromString(constituent string) (Constituent, error) {
 		return C_PHI1, nil
 	case "THETA1":
 		return C_THETA1, nil
+	case "EPS2":
+		return C_EPS2, nil
+	case "MKS2":
+		return C_MKS2, nil
+	case "MSQM":
+		return C_MSQM, nil
+	case "MTM":
+		return C_MTM, nil
+	case "N4":
+		return C_N4, nil
 	}
 	return C_UNKNOWN, ErrConstituentNotFound
 }
@@ -294,5 +319,10 @@ func GetAllConstituents() []Constituent {
 		C_CHI1,
 		C_PI1,
 		C_PHI1,
-		C_THETA1}
+		C_THETA1,
+		C_EPS2,
+		C_MKS2,
+		C_MSQM,
+		C_MTM,
+		C_N4}
 }
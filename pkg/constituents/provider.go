@@ -0,0 +1,17 @@
+package constituents
+
+// source of the harmonic constants at a location, e.g. a gridded tide data db, the constants of a
+// tide station or an in-memory table. Amplitudes are in cm, phases are greenwich phase lags in degree
+type HarmonicConstantsProvider interface {
+	// returns the wanted constituents available at lat/lon (all constituents if none are wanted),
+	// constituents without data at the location are omitted
+	ConstituentsAt(lat float32, lon float32, wanted ...Constituent) ([]ConstituentDatum, error)
+}
+
+// returns the constituents a provider looks up for the wanted constituents of ConstituentsAt
+func LookupConstituents(wanted []Constituent) []Constituent {
+	if len(wanted) == 0 {
+		return GetAllConstituents()
+	}
+	return wanted
+}
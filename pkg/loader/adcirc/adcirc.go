@@ -0,0 +1,393 @@
+package adcirc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+var (
+	ErrMeshNotFound         = errors.New("ADCIRC mesh (fort.14) not found next to the harmonic constants")
+	ErrNoKnownConstituents  = errors.New("no known constituent in the harmonic constants")
+	ErrInvalidHarmonicsFile = errors.New("invalid ADCIRC harmonic constants file")
+	ErrInvalidMeshFile      = errors.New("invalid ADCIRC mesh file")
+	ErrNodesDifferFromMesh  = errors.New("nodes of the harmonic constants differ from the mesh")
+)
+
+// extensions of the ADCIRC harmonic constants at the nodes (fort.53) and the mesh (fort.14)
+const HARMONICS_EXTENSION = ".53"
+const MESH_EXTENSION = ".14"
+
+const UNDEF_VALUE float32 = 99999
+
+// the number of frequencies in the header of a harmonic constants file is at most this large
+const MAX_FREQUENCIES = 1000
+
+type adcircLoader struct {
+	latitudes  []float32
+	longitudes []float32
+	triangles  []uint32
+
+	constituents []constituents.Constituent
+	// amplitude in cm and phase in degree of each constituent at the nodes
+	amplitudes [][]float32
+	phases     [][]float32
+	current    int
+}
+
+// creates a loader for the harmonic constants of ADCIRC at the nodes of its triangular mesh. filePath is
+// the harmonic analysis output (fort.53) or a directory with it, the mesh is read from the file with the
+// same name and the extension .14 (fort.14) in the same directory. The node coordinates of the mesh must
+// be longitude and latitude in degree, the elevation amplitudes are expected in meter. Constituents not
+// known by this library (e.g. STEADY) are skipped, compressed files (e.g. fort.53.gz) are decompressed
+// while reading
+func CreateADCIRCLoader(filePath string) (constituentdata.ConstituentDataLoader, error) {
+	info, err := os.Stat(filePath)
+	if err == nil && info.IsDir() {
+		filePath, err = findFile(filepath.Join(filePath, "fort"+HARMONICS_EXTENSION))
+		if err != nil {
+			return nil, err
+		}
+	}
+	meshPath, err := findFile(strings.TrimSuffix(constituentdata.StripCompressionExtension(filePath), HARMONICS_EXTENSION) + MESH_EXTENSION)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMeshNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	loader := &adcircLoader{}
+	nodeIndex, err := loader.readMesh(meshPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", meshPath, err)
+	}
+	if err := loader.readHarmonics(filePath, nodeIndex); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return loader, nil
+}
+
+// returns filePath or its compressed variant (e.g. fort.14.gz)
+func findFile(filePath string) (string, error) {
+	matches, err := filepath.Glob(filePath + "*")
+	if err != nil {
+		return "", err
+	}
+	for _, match := range matches {
+		if constituentdata.StripCompressionExtension(match) == filePath {
+			return match, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", filePath, os.ErrNotExist)
+}
+
+// detects a harmonic constants file by its header, the number of frequencies followed by a line
+// for each frequency (frequency, nodal factor, equilibrium argument and name) and the number of nodes
+func Detect(reader io.ReadSeeker) bool {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	scanner := newLineScanner(io.LimitReader(reader, constituentdata.DETECT_HEADER_SIZE))
+	if _, err := parseHeader(scanner); err != nil {
+		return false
+	}
+	_, err := scanner.nextInt()
+	return err == nil
+}
+
+func (a *adcircLoader) Close() error {
+	return nil
+}
+
+func (a *adcircLoader) GetNextConstituentData() (*constituentdata.TideConstituentData, error) {
+	if a.current >= 2*len(a.constituents) {
+		return nil, io.EOF
+	}
+	index := a.current / 2
+	data := &constituentdata.TideConstituentData{
+		Constituent: a.constituents[index],
+		Type:        constituentdata.AMPLITUDE,
+		SizeX:       len(a.latitudes),
+		SizeY:       1,
+		Data:        [][]float32{a.amplitudes[index]},
+		UndefValue:  UNDEF_VALUE,
+		Latitudes:   a.latitudes,
+		Longitudes:  a.longitudes,
+		Curvilinear: true,
+		Triangles:   a.triangles,
+	}
+	if a.current%2 == 1 {
+		data.Type = constituentdata.PHASE
+		data.Data = [][]float32{a.phases[index]}
+	}
+	data.LatitudeMin, data.LatitudeMax = extent(a.latitudes)
+	data.LongitudeMin, data.LongitudeMax = extent(a.longitudes)
+	a.current = a.current + 1
+	return data, nil
+}
+
+func extent(values []float32) (float32, float32) {
+	minimum, maximum := values[0], values[0]
+	for _, value := range values {
+		if value < minimum {
+			minimum = value
+		}
+		if value > maximum {
+			maximum = value
+		}
+	}
+	return minimum, maximum
+}
+
+// reads the nodes and triangles of a fort.14 mesh, the title is followed by the number of elements and
+// nodes, a line per node (number, x, y, depth) and a line per element (number, 3, the three node numbers).
+// Returns the index of each node number
+func (a *adcircLoader) readMesh(meshPath string) (map[int]int, error) {
+	file, err := constituentdata.OpenFile(meshPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := newLineScanner(file)
+
+	if _, err := scanner.nextLine(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMeshFile, err)
+	}
+	sizes, err := scanner.nextFields(2)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMeshFile, err)
+	}
+	numberElements, errElements := strconv.Atoi(sizes[0])
+	numberNodes, errNodes := strconv.Atoi(sizes[1])
+	if errElements != nil || errNodes != nil || numberElements < 1 || numberNodes < 3 {
+		return nil, fmt.Errorf("%w: number of elements and nodes expected", ErrInvalidMeshFile)
+	}
+
+	nodeIndex := make(map[int]int, numberNodes)
+	a.latitudes = make([]float32, numberNodes)
+	a.longitudes = make([]float32, numberNodes)
+	for i := 0; i < numberNodes; i++ {
+		fields, err := scanner.nextFields(3)
+		if err != nil {
+			return nil, fmt.Errorf("%w: node %d: %s", ErrInvalidMeshFile, i+1, err)
+		}
+		number, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: node %d: %s", ErrInvalidMeshFile, i+1, err)
+		}
+		lon, errLon := strconv.ParseFloat(fields[1], 64)
+		lat, errLat := strconv.ParseFloat(fields[2], 64)
+		if errLon != nil || errLat != nil || math.Abs(lat) > 90 {
+			return nil, fmt.Errorf("%w: node %d: longitude and latitude in degree expected", ErrInvalidMeshFile, number)
+		}
+		nodeIndex[number] = i
+		a.latitudes[i] = float32(lat)
+		a.longitudes[i] = float32(lon)
+	}
+
+	a.triangles = make([]uint32, 0, 3*numberElements)
+	for i := 0; i < numberElements; i++ {
+		fields, err := scanner.nextFields(5)
+		if err != nil {
+			return nil, fmt.Errorf("%w: element %d: %s", ErrInvalidMeshFile, i+1, err)
+		}
+		if fields[1] != "3" {
+			return nil, fmt.Errorf("%w: element %s is not a triangle", ErrInvalidMeshFile, fields[0])
+		}
+		for _, field := range fields[2:5] {
+			number, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("%w: element %s: %s", ErrInvalidMeshFile, fields[0], err)
+			}
+			index, ok := nodeIndex[number]
+			if !ok {
+				return nil, fmt.Errorf("%w: element %s has the unknown node %d", ErrInvalidMeshFile, fields[0], number)
+			}
+			a.triangles = append(a.triangles, uint32(index))
+		}
+	}
+	return nodeIndex, nil
+}
+
+// reads the amplitude and phase of the known constituents at the nodes from a fort.53 file
+func (a *adcircLoader) readHarmonics(filePath string, nodeIndex map[int]int) error {
+	file, err := constituentdata.OpenFile(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := newLineScanner(file)
+
+	names, err := parseHeader(scanner)
+	if err != nil {
+		return err
+	}
+	numberNodes, err := scanner.nextInt()
+	if err != nil {
+		return fmt.Errorf("%w: number of nodes expected", ErrInvalidHarmonicsFile)
+	}
+	if numberNodes != len(a.latitudes) {
+		return fmt.Errorf("%w: %d nodes, the mesh has %d", ErrNodesDifferFromMesh, numberNodes, len(a.latitudes))
+	}
+
+	// position of each known constituent in the frequencies of the file
+	frequencies := []int{}
+	for i, name := range names {
+		constituent, err := constituents.FromString(name)
+		if err != nil {
+			continue
+		}
+		frequencies = append(frequencies, i)
+		a.constituents = append(a.constituents, constituent)
+		a.amplitudes = append(a.amplitudes, make([]float32, numberNodes))
+		a.phases = append(a.phases, make([]float32, numberNodes))
+	}
+	if len(a.constituents) == 0 {
+		return ErrNoKnownConstituents
+	}
+
+	// the node number is followed by amplitude and phase of each frequency, one line per frequency
+	// or all on the line of the node depending on the ADCIRC version
+	values := make([]float64, 2*len(names))
+	for i := 0; i < numberNodes; i++ {
+		number, err := scanner.nextWordInt()
+		if err != nil {
+			return fmt.Errorf("%w: node %d: %s", ErrInvalidHarmonicsFile, i+1, err)
+		}
+		index, ok := nodeIndex[number]
+		if !ok {
+			return fmt.Errorf("%w: unknown node %d", ErrNodesDifferFromMesh, number)
+		}
+		for k := range values {
+			word, err := scanner.nextWord()
+			if err != nil {
+				return fmt.Errorf("%w: node %d: %s", ErrInvalidHarmonicsFile, number, err)
+			}
+			values[k], err = strconv.ParseFloat(strings.Replace(strings.ToUpper(word), "D", "E", 1), 64)
+			if err != nil {
+				return fmt.Errorf("%w: node %d: %s", ErrInvalidHarmonicsFile, number, err)
+			}
+		}
+		for k, frequency := range frequencies {
+			amplitude, phase := values[2*frequency], values[2*frequency+1]
+			if math.IsNaN(amplitude) || math.IsNaN(phase) {
+				a.amplitudes[k][index], a.phases[k][index] = UNDEF_VALUE, UNDEF_VALUE
+				continue
+			}
+			a.amplitudes[k][index] = float32(amplitude * 100)
+			a.phases[k][index] = float32(phase)
+		}
+	}
+	return nil
+}
+
+// parses the frequency header of a harmonic constants file and returns the names of the frequencies
+func parseHeader(scanner *lineScanner) ([]string, error) {
+	numberFrequencies, err := scanner.nextInt()
+	if err != nil || numberFrequencies < 1 || numberFrequencies > MAX_FREQUENCIES {
+		return nil, fmt.Errorf("%w: number of frequencies expected", ErrInvalidHarmonicsFile)
+	}
+	names := make([]string, numberFrequencies)
+	for i := range names {
+		fields, err := scanner.nextFields(3)
+		if err != nil {
+			return nil, fmt.Errorf("%w: frequency %d: %s", ErrInvalidHarmonicsFile, i+1, err)
+		}
+		for _, field := range fields[:3] {
+			if _, err := strconv.ParseFloat(field, 64); err != nil {
+				return nil, fmt.Errorf("%w: frequency %d: %s", ErrInvalidHarmonicsFile, i+1, err)
+			}
+		}
+		// older versions write the name on the next line
+		if len(fields) == 3 {
+			if fields, err = scanner.nextFields(1); err != nil {
+				return nil, fmt.Errorf("%w: frequency %d: name expected", ErrInvalidHarmonicsFile, i+1)
+			}
+			names[i] = fields[0]
+		} else {
+			names[i] = fields[3]
+		}
+	}
+	return names, nil
+}
+
+// reads a file line by line or word by word
+type lineScanner struct {
+	reader *bufio.Reader
+	// remaining words of the current line when reading words
+	words []string
+}
+
+func newLineScanner(reader io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReader(reader)}
+}
+
+func (l *lineScanner) nextLine() (string, error) {
+	l.words = nil
+	line, err := l.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return line, nil
+}
+
+// returns the fields of the next non empty line, at least minimum fields
+func (l *lineScanner) nextFields(minimum int) ([]string, error) {
+	for {
+		line, err := l.nextLine()
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < minimum {
+			return nil, fmt.Errorf("expected %d values, got %d", minimum, len(fields))
+		}
+		return fields, nil
+	}
+}
+
+// returns the first integer of the next non empty line
+func (l *lineScanner) nextInt() (int, error) {
+	fields, err := l.nextFields(1)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(fields[0])
+}
+
+// returns the next word across lines
+func (l *lineScanner) nextWord() (string, error) {
+	for len(l.words) == 0 {
+		line, err := l.nextLine()
+		if err != nil {
+			return "", err
+		}
+		l.words = strings.Fields(line)
+	}
+	word := l.words[0]
+	l.words = l.words[1:]
+	return word, nil
+}
+
+func (l *lineScanner) nextWordInt() (int, error) {
+	word, err := l.nextWord()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(word)
+}
@@ -0,0 +1,147 @@
+package adcirc_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/adcirc"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+// two triangles over a square, the node numbers are not in order
+const meshFile = `test mesh
+2 4
+10 4.0 50.0 12.5
+20 5.0 50.0 13.0
+30 5.0 51.0 10.0
+40 4.0 51.0 11.0
+1 3 10 20 30
+2 3 10 30 40
+`
+
+// amplitude in meter and phase of the frequency at the node
+func nodeValues(frequency int, node int) (float64, float64) {
+	return float64(frequency+1) * 0.01 * float64(node), float64(10*frequency + node)
+}
+
+// writes the harmonic constants of STEADY, M2 and K1, one line per frequency or all on the line of the node
+func harmonicsFile(singleLine bool) string {
+	names := []string{"STEADY", "M2", "K1"}
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("%d\n", len(names)))
+	for _, name := range names {
+		builder.WriteString(fmt.Sprintf("  0.1405189025E-03  1.0000000  0.0000000 %s\n", name))
+	}
+	builder.WriteString("4\n")
+	for _, node := range []int{40, 10, 30, 20} {
+		builder.WriteString(fmt.Sprintf("%d", node))
+		for frequency := range names {
+			amplitude, phase := nodeValues(frequency, node)
+			if singleLine {
+				builder.WriteString(fmt.Sprintf(" %e %f", amplitude, phase))
+			} else {
+				builder.WriteString(fmt.Sprintf("\n  %16.10E %12.4f", amplitude, phase))
+			}
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func assertMeshData(t *testing.T, data *constituentdata.TideConstituentData, constituent constituents.Constituent, valueType constituentdata.ConstituentValueType, frequency int) {
+	if data.Constituent != constituent || data.Type != valueType {
+		t.Fatalf("expected %s %s, got %s %s", constituent, valueType, data.Constituent, data.Type)
+	}
+	if data.SizeX != 4 || data.SizeY != 1 || !data.Curvilinear || len(data.Latitudes) != 4 {
+		t.Fatalf("unexpected mesh %dx%d with %d nodes", data.SizeX, data.SizeY, len(data.Latitudes))
+	}
+	if data.LatitudeMin != 50 || data.LatitudeMax != 51 || data.LongitudeMin != 4 || data.LongitudeMax != 5 {
+		t.Errorf("unexpected mesh limits lat %f-%f lon %f-%f", data.LatitudeMin, data.LatitudeMax, data.LongitudeMin, data.LongitudeMax)
+	}
+	expectedTriangles := []uint32{0, 1, 2, 0, 2, 3}
+	for i, node := range expectedTriangles {
+		if data.Triangles[i] != node {
+			t.Fatalf("expected triangles %v, got %v", expectedTriangles, data.Triangles)
+		}
+	}
+	for i, node := range []int{10, 20, 30, 40} {
+		amplitude, phase := nodeValues(frequency, node)
+		expected := float32(phase)
+		if valueType == constituentdata.AMPLITUDE {
+			// in cm
+			expected = float32(amplitude * 100)
+		}
+		if diff := data.Data[0][i] - expected; diff > 1e-3 || diff < -1e-3 {
+			t.Errorf("node %d: expected %f, got %f", node, expected, data.Data[0][i])
+		}
+	}
+}
+
+func TestADCIRCLoader(t *testing.T) {
+	for _, singleLine := range []bool{false, true} {
+		dir := t.TempDir()
+		testutil.WriteFile(t, dir, "fort.14", []byte(meshFile))
+		testutil.WriteFile(t, dir, "fort.53", []byte(harmonicsFile(singleLine)))
+
+		// the file or the directory
+		for _, input := range []string{filepath.Join(dir, "fort.53"), dir} {
+			loader, err := adcirc.CreateADCIRCLoader(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// STEADY is not a tidal constituent and skipped
+			for _, expected := range []struct {
+				constituent constituents.Constituent
+				frequency   int
+			}{{constituents.C_M2, 1}, {constituents.C_K1, 2}} {
+				for _, valueType := range []constituentdata.ConstituentValueType{constituentdata.AMPLITUDE, constituentdata.PHASE} {
+					data, err := loader.GetNextConstituentData()
+					if err != nil {
+						t.Fatal(err)
+					}
+					assertMeshData(t, data, expected.constituent, valueType, expected.frequency)
+				}
+			}
+			if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+				t.Errorf("expected io.EOF, got %v", err)
+			}
+			loader.Close()
+		}
+	}
+}
+
+func TestADCIRCLoaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	harmonicsPath := testutil.WriteFile(t, dir, "fort.53", []byte(harmonicsFile(false)))
+	if _, err := adcirc.CreateADCIRCLoader(harmonicsPath); !errors.Is(err, adcirc.ErrMeshNotFound) {
+		t.Errorf("expected ErrMeshNotFound, got %v", err)
+	}
+
+	// a node of the harmonic constants is missing in the mesh
+	testutil.WriteFile(t, dir, "fort.14", []byte(strings.Replace(meshFile, "40 4.0 51.0", "41 4.0 51.0", 1)))
+	if _, err := adcirc.CreateADCIRCLoader(harmonicsPath); !errors.Is(err, adcirc.ErrInvalidMeshFile) {
+		t.Errorf("expected ErrInvalidMeshFile, got %v", err)
+	}
+	testutil.WriteFile(t, dir, "fort.14", []byte(strings.Replace(strings.Replace(meshFile, "40 4.0 51.0", "41 4.0 51.0", 1), "10 30 40", "10 30 41", 1)))
+	if _, err := adcirc.CreateADCIRCLoader(harmonicsPath); !errors.Is(err, adcirc.ErrNodesDifferFromMesh) {
+		t.Errorf("expected ErrNodesDifferFromMesh, got %v", err)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if !adcirc.Detect(bytes.NewReader([]byte(harmonicsFile(false)))) {
+		t.Errorf("expected harmonic constants to be detected")
+	}
+	for _, content := range []string{meshFile, "M2 amplitude\n 2 2\n", "1\n0.1 1.0\n"} {
+		if adcirc.Detect(bytes.NewReader([]byte(content))) {
+			t.Errorf("%q: unexpected detection", content)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package constituentdata
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrZipMembers = errors.New("zip archive must contain exactly one file")
+
+type Compression string
+
+const (
+	COMPRESSION_NONE  Compression = "none"
+	COMPRESSION_GZIP  Compression = "gzip"
+	COMPRESSION_BZIP2 Compression = "bzip2"
+	COMPRESSION_ZIP   Compression = "zip"
+)
+
+// file name extensions of the supported compressions, removed by StripCompressionExtension
+var compressionExtensions = []string{".gz", ".gzip", ".bz2", ".zip"}
+
+// returns the compression of a file by its magic bytes
+func DetectCompression(header []byte) Compression {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return COMPRESSION_GZIP
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return COMPRESSION_BZIP2
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return COMPRESSION_ZIP
+	}
+	return COMPRESSION_NONE
+}
+
+// returns the file name without the extension of a compression, e.g. m2.nc for m2.nc.gz
+func StripCompressionExtension(filePath string) string {
+	for _, extension := range compressionExtensions {
+		if strings.HasSuffix(strings.ToLower(filePath), extension) {
+			return filePath[:len(filePath)-len(extension)]
+		}
+	}
+	return filePath
+}
+
+// returns true if the file name matches extension with or without a compression extension
+func HasExtension(filePath string, extension string) bool {
+	return strings.EqualFold(filepath.Ext(StripCompressionExtension(filePath)), extension)
+}
+
+type decompressedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedFile) Close() error {
+	var closeErr error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+// opens a file for reading, gzip, bzip2 and single file zip archives are decompressed transparently
+// while reading, the compression is detected by the magic bytes of the file
+func OpenFile(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(file)
+	magic, err := reader.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, err
+	}
+
+	switch DetectCompression(magic) {
+	case COMPRESSION_GZIP:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &decompressedFile{Reader: gzipReader, closers: []io.Closer{gzipReader, file}}, nil
+	case COMPRESSION_BZIP2:
+		return &decompressedFile{Reader: bzip2.NewReader(reader), closers: []io.Closer{file}}, nil
+	case COMPRESSION_ZIP:
+		file.Close()
+		return openZipMember(filePath)
+	}
+	return &decompressedFile{Reader: reader, closers: []io.Closer{file}}, nil
+}
+
+func openZipMember(filePath string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	members := []*zip.File{}
+	for _, member := range archive.File {
+		if !member.FileInfo().IsDir() {
+			members = append(members, member)
+		}
+	}
+	if len(members) != 1 {
+		archive.Close()
+		return nil, fmt.Errorf("%w, found %d files", ErrZipMembers, len(members))
+	}
+	memberReader, err := members[0].Open()
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return &decompressedFile{Reader: memberReader, closers: []io.Closer{memberReader, archive}}, nil
+}
@@ -0,0 +1,96 @@
+package constituentdata_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+const content = "hello compressed world\n"
+
+// bzip2 compressed content, the standard library has no bzip2 writer
+const bzip2Content = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x8a\x37\x73\x8f\x00\x00\x04\xd1\x80\x00\x10\x40\x00\x0e\x46\xd8\x80\x20\x00\x31\x00\xd0\x01\x4f\x40\x69\x3d\x23\x90\xf7\x48\xa2\x22\x48\xcd\x5b\x3e\xb5\xf1\x77\x24\x53\x85\x09\x08\xa3\x77\x38\xf0"
+
+func gzipContent(t *testing.T) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func zipContent(t *testing.T, names ...string) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for _, name := range names {
+		member, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := member.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestOpenFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        []byte
+		compression constituentdata.Compression
+	}{
+		{"plain.txt", []byte(content), constituentdata.COMPRESSION_NONE},
+		{"file.gz", gzipContent(t), constituentdata.COMPRESSION_GZIP},
+		{"file.bz2", []byte(bzip2Content), constituentdata.COMPRESSION_BZIP2},
+		{"file.zip", zipContent(t, "fort.30"), constituentdata.COMPRESSION_ZIP},
+		// the compression is detected by the magic bytes and not the extension
+		{"no_extension", gzipContent(t), constituentdata.COMPRESSION_GZIP},
+	}
+	for _, testCase := range testCases {
+		if compression := constituentdata.DetectCompression(testCase.data); compression != testCase.compression {
+			t.Errorf("%s: expected compression %s, got %s", testCase.name, testCase.compression, compression)
+		}
+		reader, err := constituentdata.OpenFile(testutil.WriteFile(t, t.TempDir(), testCase.name, testCase.data))
+		if err != nil {
+			t.Fatalf("%s: %s", testCase.name, err)
+		}
+		decompressed, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", testCase.name, err)
+		}
+		if string(decompressed) != content {
+			t.Errorf("%s: expected %q, got %q", testCase.name, content, decompressed)
+		}
+	}
+}
+
+func TestOpenZipWithMultipleFiles(t *testing.T) {
+	_, err := constituentdata.OpenFile(testutil.WriteFile(t, t.TempDir(), "multi.zip", zipContent(t, "m2.d", "s2.d")))
+	if !errors.Is(err, constituentdata.ErrZipMembers) {
+		t.Fatalf("expected ErrZipMembers, got %v", err)
+	}
+}
+
+func TestCompressionExtension(t *testing.T) {
+	if name := constituentdata.StripCompressionExtension("dir/m2.nc.GZ"); name != "dir/m2.nc" {
+		t.Errorf("expected dir/m2.nc, got %s", name)
+	}
+	if !constituentdata.HasExtension("m2.d.bz2", ".d") || !constituentdata.HasExtension("m2.d", ".d") || constituentdata.HasExtension("m2.nc.zip", ".d") {
+		t.Error("unexpected result of HasExtension")
+	}
+}
@@ -1,7 +1,9 @@
 package constituentdata
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/mzeiher/perth3-go/pkg/constituents"
 )
@@ -24,6 +26,15 @@ type TideConstituentData struct {
 	LongitudeMax float32
 	Data         [][]float32
 	UndefValue   float32
+	// coordinates of grids without a regular spacing, nil for regular grids. Rectilinear grids have the
+	// SizeY latitudes of the rows and the SizeX longitudes of the columns, curvilinear grids the
+	// coordinates of every grid point with the index y*SizeX+x
+	Latitudes   []float32
+	Longitudes  []float32
+	Curvilinear bool
+	// node indices of the triangles of unstructured meshes, the SizeX nodes (SizeY is 1) are in
+	// Data[0] and their coordinates in Latitudes and Longitudes
+	Triangles []uint32
 }
 
 type ConstituentDataLoader interface {
@@ -34,3 +45,18 @@ type ConstituentDataLoader interface {
 }
 
 type CreateLoaderFunction func(filePath string) (ConstituentDataLoader, error)
+
+// returns the factor to convert an amplitude in the given unit (e.g. the units attribute of a NetCDF variable) to cm
+func AmplitudeFactorToCm(unit string) (float32, error) {
+	switch strings.ToLower(strings.Trim(unit, "\x00 ")) {
+	case "m", "meter", "meters", "metre", "metres":
+		return 100, nil
+	case "cm", "centimeter", "centimeters":
+		return 1, nil
+	case "mm", "millimeter", "millimeters":
+		return 0.1, nil
+	case "ft", "foot", "feet":
+		return 30.48, nil
+	}
+	return 0, fmt.Errorf("unknown amplitude unit %s", unit)
+}
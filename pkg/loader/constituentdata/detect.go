@@ -0,0 +1,77 @@
+package constituentdata
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// returned by the NetCDF based loaders if the build has no NetCDF support (no cgo or build tag nonetcdf)
+var ErrNetcdfNotSupported = errors.New("reading NetCDF files requires cgo and libnetcdf, not supported by this build")
+
+// number of bytes at the start of a file searched by the format detection
+const DETECT_HEADER_SIZE = 1 << 20
+
+// returns true if the content of reader can be read by the loader, the position of reader is undefined afterwards
+type DetectFunction func(reader io.ReadSeeker) bool
+
+// reads up to size bytes from the start of reader
+func ReadHeaderBytes(reader io.ReadSeeker, size int) ([]byte, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, size)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// returns true if reader starts with the signature of a classic NetCDF file ("CDF") or a NetCDF-4 (HDF5) file
+func HasNetcdfSignature(reader io.ReadSeeker) bool {
+	signature, err := ReadHeaderBytes(reader, 4)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(signature, []byte("CDF")) || bytes.HasPrefix(signature, []byte("\x89HDF"))
+}
+
+// returns true if all names (e.g. NetCDF variable names) appear in the first DETECT_HEADER_SIZE bytes of reader
+func HeaderContains(reader io.ReadSeeker, names ...string) bool {
+	header, err := ReadHeaderBytes(reader, DETECT_HEADER_SIZE)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if !bytes.Contains(header, []byte(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// in-memory start of a file, Name returns the file name without compression extension
+type headerReader struct {
+	*bytes.Reader
+	name string
+}
+
+func (h *headerReader) Name() string {
+	return h.name
+}
+
+// returns the first DETECT_HEADER_SIZE bytes of a (compressed) file for the format detection
+func OpenHeaderReader(filePath string) (io.ReadSeeker, error) {
+	file, err := OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	header := make([]byte, DETECT_HEADER_SIZE)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return &headerReader{Reader: bytes.NewReader(header[:n]), name: StripCompressionExtension(filePath)}, nil
+}
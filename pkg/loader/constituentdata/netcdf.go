@@ -0,0 +1,56 @@
+//go:build cgo && !nonetcdf
+
+package constituentdata
+
+import (
+	"os"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/fhs/go-netcdf/netcdf/ncmem"
+)
+
+// a NetCDF file opened for reading by OpenNetcdfFile
+type NetcdfFile struct {
+	netcdf.Dataset
+	close func() error
+}
+
+func (n *NetcdfFile) Close() error {
+	return n.close()
+}
+
+// opens a NetCDF file for reading. NetCDF needs random access to the whole file, so compressed files
+// are decompressed into memory (nc_open_memio) instead of a temporary file, the decompressed file must fit into memory
+func OpenNetcdfFile(filePath string) (*NetcdfFile, error) {
+	header, err := readFileHeader(filePath, 4)
+	if err != nil {
+		return nil, err
+	}
+	if DetectCompression(header) == COMPRESSION_NONE {
+		dataset, err := netcdf.OpenFile(filePath, netcdf.NOWRITE)
+		if err != nil {
+			return nil, err
+		}
+		return &NetcdfFile{Dataset: dataset, close: dataset.Close}, nil
+	}
+
+	reader, err := OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	dataset, err := ncmem.OpenReader(StripCompressionExtension(filePath), netcdf.NOWRITE, 0, reader)
+	if err != nil {
+		return nil, err
+	}
+	return &NetcdfFile{Dataset: dataset.Dataset, close: dataset.Close}, nil
+}
+
+func readFileHeader(filePath string, size int) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadHeaderBytes(file, size)
+}
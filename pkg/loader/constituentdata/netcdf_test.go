@@ -0,0 +1,81 @@
+//go:build cgo && !nonetcdf
+
+package constituentdata_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+func createNetcdfFile(t *testing.T, filePath string, values []float32) {
+	file, err := netcdf.CreateFile(filePath, netcdf.CLOBBER|netcdf.NETCDF4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dim, _ := file.AddDim("x", uint64(len(values)))
+	variable, _ := file.AddVar("amplitude", netcdf.FLOAT, []netcdf.Dim{dim})
+	file.EndDef()
+	if err := variable.WriteFloat32s(values); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertNetcdfValues(t *testing.T, filePath string, expected []float32) {
+	file, err := constituentdata.OpenNetcdfFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	variable, err := file.Var("amplitude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]float32, len(expected))
+	if err := variable.ReadFloat32s(values); err != nil {
+		t.Fatal(err)
+	}
+	for index := range expected {
+		if values[index] != expected[index] {
+			t.Errorf("value %d: expected %f, got %f", index, expected[index], values[index])
+		}
+	}
+}
+
+func TestOpenNetcdfFile(t *testing.T) {
+	values := []float32{1.5, 2.5, 3.5}
+	filePath := filepath.Join(t.TempDir(), "m2.nc")
+	createNetcdfFile(t, filePath, values)
+	assertNetcdfValues(t, filePath, values)
+}
+
+func TestOpenCompressedNetcdfFile(t *testing.T) {
+	values := []float32{1.5, 2.5, 3.5}
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "m2.nc")
+	createNetcdfFile(t, filePath, values)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	writer.Write(data)
+	writer.Close()
+	compressedPath := testutil.WriteFile(t, dir, "m2.nc.gz", buffer.Bytes())
+	// the compressed file is read from memory, not from the uncompressed file
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	assertNetcdfValues(t, compressedPath, values)
+}
@@ -3,7 +3,7 @@ package dtu16ascii
 import (
 	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
 
@@ -14,27 +14,28 @@ import (
 type asciiTideFile struct {
 	constituentdata.ConstituentDataLoader
 	reader *bufio.Reader
-	file   *os.File
+	file   io.Closer
 }
 
-type asciiTideHeader struct {
-	longitudeMin float32
-	longitudeMax float32
-	latitudeMin  float32
-	latitudeMax  float32
+// header of a grid block, shared by the ascii formats derived from the GOT layout
+type GridHeader struct {
+	LongitudeMin float32
+	LongitudeMax float32
+	LatitudeMin  float32
+	LatitudeMax  float32
 
-	constituentType constituentdata.ConstituentValueType
-	constituent     constituents.Constituent
+	ConstituentType constituentdata.ConstituentValueType
+	Constituent     constituents.Constituent
 
-	undefValue     float32
-	entriesPerLine int
+	UndefValue     float32
+	EntriesPerLine int
 
-	gridX int
-	gridY int
+	GridX int
+	GridY int
 }
 
 func CreateDTU16Loader(filePath string) (constituentdata.ConstituentDataLoader, error) {
-	file, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
+	file, err := constituentdata.OpenFile(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +47,16 @@ func CreateDTU16Loader(filePath string) (constituentdata.ConstituentDataLoader,
 	}, nil
 }
 
+// detects the ascii format of the DTU models by parsing the header of the first grid block
+func Detect(reader io.ReadSeeker) bool {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	tideFile := &asciiTideFile{reader: bufio.NewReader(io.LimitReader(reader, constituentdata.DETECT_HEADER_SIZE))}
+	header, err := tideFile.ParseHeader()
+	return err == nil && header.GridX > 0 && header.GridY > 0
+}
+
 func (a *asciiTideFile) Close() error {
 	return a.file.Close()
 }
@@ -57,28 +68,34 @@ func (a *asciiTideFile) GetNextConstituentData() (*constituentdata.TideConstitue
 		return nil, err
 	}
 
+	return ReadGridData(a.reader, header)
+}
+
+// streams the values of a grid block described by header, the values are read row by row from
+// the south-west corner regardless of the number of values per line
+func ReadGridData(reader *bufio.Reader, header GridHeader) (*constituentdata.TideConstituentData, error) {
 	gridData := &constituentdata.TideConstituentData{
-		Constituent:  header.constituent,
-		Type:         header.constituentType,
-		LatitudeMin:  header.latitudeMin,
-		LatitudeMax:  header.latitudeMax,
-		LongitudeMin: header.longitudeMin,
-		LongitudeMax: header.longitudeMax,
+		Constituent:  header.Constituent,
+		Type:         header.ConstituentType,
+		LatitudeMin:  header.LatitudeMin,
+		LatitudeMax:  header.LatitudeMax,
+		LongitudeMin: header.LongitudeMin,
+		LongitudeMax: header.LongitudeMax,
 
-		SizeX: header.gridX,
-		SizeY: header.gridY,
+		SizeX: header.GridX,
+		SizeY: header.GridY,
 
-		UndefValue: header.undefValue,
-		Data:       make([][]float32, header.gridY),
+		UndefValue: header.UndefValue,
+		Data:       make([][]float32, header.GridY),
 	}
 
-	numberEntries := header.gridX * header.gridY
+	numberEntries := header.GridX * header.GridY
 	currentY := 0
 	currentEntry := 0
-	gridData.Data[currentY] = make([]float32, header.gridX)
+	gridData.Data[currentY] = make([]float32, header.GridX)
 	for {
 
-		line, err := a.reader.ReadString('\n')
+		line, err := reader.ReadString('\n')
 		entries := strings.Fields(line)
 		if err != nil {
 			return nil, err
@@ -91,16 +108,16 @@ func (a *asciiTideFile) GetNextConstituentData() (*constituentdata.TideConstitue
 			if currentEntry > numberEntries {
 				return nil, fmt.Errorf("too many entries in table")
 			}
-			if currentEntry >= header.gridX*(currentY+1) {
+			if currentEntry >= header.GridX*(currentY+1) {
 				currentY = currentY + 1
-				gridData.Data[currentY] = make([]float32, header.gridX)
+				gridData.Data[currentY] = make([]float32, header.GridX)
 			}
 
 			entryParsed, err := strconv.ParseFloat(entry, 32)
 			if err != nil {
 				return nil, err
 			}
-			xPos := ((currentY * header.gridX) - currentEntry) * -1
+			xPos := ((currentY * header.GridX) - currentEntry) * -1
 			gridData.Data[currentY][xPos] = float32(entryParsed)
 
 			if currentEntry == numberEntries-1 && index == len(entries)-1 {
@@ -116,8 +133,8 @@ func (a *asciiTideFile) GetNextConstituentData() (*constituentdata.TideConstitue
 
 }
 
-func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
-	asciiHeader := asciiTideHeader{}
+func (a *asciiTideFile) ParseHeader() (GridHeader, error) {
+	asciiHeader := GridHeader{}
 
 	// try to read the title
 	title, err := a.reader.ReadString('\n')
@@ -125,16 +142,20 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 		return asciiHeader, err
 	}
 
-	constituent, err := constituents.FromString(strings.Fields(title)[0])
+	titleFields := strings.Fields(title)
+	if len(titleFields) == 0 {
+		return asciiHeader, fmt.Errorf("missing constituent in title")
+	}
+	constituent, err := constituents.FromString(titleFields[0])
 	if err != nil {
 		return asciiHeader, fmt.Errorf("unknown constituent in title %s", title)
 	}
-	asciiHeader.constituent = constituent
+	asciiHeader.Constituent = constituent
 
 	if strings.Contains(strings.ToLower(title), "amplitude") {
-		asciiHeader.constituentType = constituentdata.AMPLITUDE
+		asciiHeader.ConstituentType = constituentdata.AMPLITUDE
 	} else if strings.Contains(strings.ToLower(title), "phase") {
-		asciiHeader.constituentType = constituentdata.PHASE
+		asciiHeader.ConstituentType = constituentdata.PHASE
 	}
 
 	// try to get type in second line
@@ -143,11 +164,11 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 		return asciiHeader, err
 	}
 	if strings.Contains(strings.ToLower(description), "amplitude") {
-		asciiHeader.constituentType = constituentdata.AMPLITUDE
+		asciiHeader.ConstituentType = constituentdata.AMPLITUDE
 	} else if strings.Contains(strings.ToLower(description), "phase") {
-		asciiHeader.constituentType = constituentdata.PHASE
+		asciiHeader.ConstituentType = constituentdata.PHASE
 	}
-	if asciiHeader.constituentType == "" {
+	if asciiHeader.ConstituentType == "" {
 		return asciiHeader, fmt.Errorf("constituent type not found")
 	}
 
@@ -157,7 +178,7 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 		return asciiHeader, err
 	}
 
-	_, err = fmt.Sscanf(gridSize, "%d %d", &asciiHeader.gridY, &asciiHeader.gridX)
+	_, err = fmt.Sscanf(gridSize, "%d %d", &asciiHeader.GridY, &asciiHeader.GridX)
 	if err != nil {
 		return asciiHeader, err
 	}
@@ -168,7 +189,7 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 		return asciiHeader, err
 	}
 
-	_, err = fmt.Sscanf(latMinMax, "%f %f", &asciiHeader.latitudeMin, &asciiHeader.latitudeMax)
+	_, err = fmt.Sscanf(latMinMax, "%f %f", &asciiHeader.LatitudeMin, &asciiHeader.LatitudeMax)
 	if err != nil {
 		return asciiHeader, err
 	}
@@ -179,7 +200,7 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 		return asciiHeader, err
 	}
 
-	_, err = fmt.Sscanf(lonMinMax, "%f %f", &asciiHeader.longitudeMin, &asciiHeader.longitudeMax)
+	_, err = fmt.Sscanf(lonMinMax, "%f %f", &asciiHeader.LongitudeMin, &asciiHeader.LongitudeMax)
 	if err != nil {
 		return asciiHeader, err
 	}
@@ -189,7 +210,7 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 	if err != nil {
 		return asciiHeader, err
 	}
-	_, err = fmt.Sscanf(undefValue, "%f", &asciiHeader.undefValue)
+	_, err = fmt.Sscanf(undefValue, "%f", &asciiHeader.UndefValue)
 	if err != nil {
 		return asciiHeader, err
 	}
@@ -199,7 +220,7 @@ func (a *asciiTideFile) ParseHeader() (asciiTideHeader, error) {
 	if err != nil {
 		return asciiHeader, err
 	}
-	_, err = fmt.Sscanf(entriesPerLine, "(%d", &asciiHeader.entriesPerLine)
+	_, err = fmt.Sscanf(entriesPerLine, "(%d", &asciiHeader.EntriesPerLine)
 	if err != nil {
 		return asciiHeader, err
 	}
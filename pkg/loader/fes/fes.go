@@ -0,0 +1,148 @@
+package fes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+var ErrNoFilesFound = errors.New("no FES files found")
+
+// value of grid points without data, the fill values of the files are replaced by it
+const UNDEF_VALUE float32 = 999
+
+const (
+	VAR_AMPLITUDE   = "amplitude"
+	VAR_PHASE       = "phase"
+	VAR_LATITUDE    = "lat"
+	VAR_LONGITUDE   = "lon"
+	ATTR_UNITS      = "units"
+	ATTR_FILL_VALUE = "_FillValue"
+	ATTR_MISSING    = "missing_value"
+	ATTR_SCALE      = "scale_factor"
+	ATTR_OFFSET     = "add_offset"
+	FILE_EXTENSION  = ".nc"
+	// FES amplitudes are in cm and phases in degree if the files have no units
+	DEFAULT_AMPLITUDE_UNIT = "cm"
+	DEFAULT_PHASE_UNIT     = "degrees"
+)
+
+// FES names which differ from the names known by constituents.FromString
+var constituentAliases = map[string]constituents.Constituent{
+	"LA2":     constituents.C_LAM2,
+	"LAMBDA2": constituents.C_LAM2,
+}
+
+type fesFiles struct {
+	constituentdata.ConstituentDataLoader
+	files        []string
+	current      int
+	pendingPhase *constituentdata.TideConstituentData
+}
+
+// creates a loader for the FES2014/FES2022 atlas with one NetCDF file per constituent (e.g. m2.nc or
+// M2_fes2022.nc), filePath is either a directory, a glob pattern (e.g. "./fes2014/*.nc") or a single file
+func CreateFESLoader(filePath string) (constituentdata.ConstituentDataLoader, error) {
+	files := []string{}
+	info, err := os.Stat(filePath)
+	if err == nil && info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(filePath, "*"))
+		files = filterExtension(files, FILE_EXTENSION)
+	} else {
+		files, err = filepath.Glob(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFilesFound
+	}
+	sort.Strings(files)
+	return &fesFiles{files: files}, nil
+}
+
+// returns the files with the extension, compressed or uncompressed
+func filterExtension(files []string, extension string) []string {
+	filtered := []string{}
+	for _, file := range files {
+		if constituentdata.HasExtension(file, extension) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// detects a FES NetCDF file with amplitude and phase grids
+func Detect(reader io.ReadSeeker) bool {
+	return constituentdata.HasNetcdfSignature(reader) && constituentdata.HeaderContains(reader, VAR_AMPLITUDE, VAR_PHASE, VAR_LATITUDE, VAR_LONGITUDE)
+}
+
+func (f *fesFiles) Close() error {
+	return nil
+}
+
+// returns the amplitude (cm) and phase (degree) of each file, amplitude first
+func (f *fesFiles) GetNextConstituentData() (*constituentdata.TideConstituentData, error) {
+	if f.pendingPhase != nil {
+		phase := f.pendingPhase
+		f.pendingPhase = nil
+		return phase, nil
+	}
+	if f.current >= len(f.files) {
+		return nil, io.EOF
+	}
+	file := f.files[f.current]
+	f.current = f.current + 1
+
+	amplitude, phase, err := readFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	f.pendingPhase = phase
+	return amplitude, nil
+}
+
+// the constituent is the first part of the file name, e.g. m2.nc, m2.nc.gz or M2_fes2022.nc
+func constituentFromFileName(filePath string) (constituents.Constituent, error) {
+	filePath = constituentdata.StripCompressionExtension(filePath)
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	name = strings.ToUpper(strings.Split(name, "_")[0])
+	if constituent, ok := constituentAliases[name]; ok {
+		return constituent, nil
+	}
+	constituent, err := constituents.FromString(name)
+	if err != nil {
+		return constituents.C_UNKNOWN, fmt.Errorf("unknown constituent %s", name)
+	}
+	return constituent, nil
+}
+
+func phaseFactorToDegree(unit string) (float32, error) {
+	switch strings.ToLower(strings.Trim(unit, "\x00 ")) {
+	case "degree", "degrees", "deg":
+		return 1, nil
+	case "radian", "radians", "rad":
+		return 180 / math.Pi, nil
+	}
+	return 0, fmt.Errorf("unknown phase unit %s", unit)
+}
+
+func scaleValue(value float32, fillValues []float32, scale float32, offset float32) float32 {
+	if math.IsNaN(float64(value)) {
+		return UNDEF_VALUE
+	}
+	for _, fillValue := range fillValues {
+		if value == fillValue {
+			return UNDEF_VALUE
+		}
+	}
+	return value*scale + offset
+}
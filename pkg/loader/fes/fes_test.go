@@ -0,0 +1,164 @@
+//go:build cgo && !nonetcdf
+
+package fes_test
+
+import (
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/loader/fes"
+)
+
+const fillValue float32 = 1.8446744e+19
+
+// amplitude of the test grid is lat+100 (cm) and the phase is the longitude (degree),
+// the grid point at lat 0, lon 90 is land
+func testValues(lat float32, lon float32) (float32, float32) {
+	if lat == 0 && lon == 90 {
+		return fillValue, fillValue
+	}
+	return lat + 100, lon
+}
+
+// writes a global 30 degree grid with longitudes 0..360 (360 duplicated) like the FES atlas
+func writeFesFile(t *testing.T, filePath string, amplitudeUnit string) {
+	file, err := netcdf.CreateFile(filePath, netcdf.CLOBBER|netcdf.NETCDF4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latitudes := []float32{-60, -30, 0, 30, 60}
+	longitudes := []float32{}
+	for lon := float32(0); lon <= 360; lon += 30 {
+		longitudes = append(longitudes, lon)
+	}
+	dimLat, _ := file.AddDim("lat", uint64(len(latitudes)))
+	dimLon, _ := file.AddDim("lon", uint64(len(longitudes)))
+	lat, _ := file.AddVar("lat", netcdf.FLOAT, []netcdf.Dim{dimLat})
+	lon, _ := file.AddVar("lon", netcdf.FLOAT, []netcdf.Dim{dimLon})
+	amplitude, _ := file.AddVar("amplitude", netcdf.FLOAT, []netcdf.Dim{dimLat, dimLon})
+	phase, _ := file.AddVar("phase", netcdf.FLOAT, []netcdf.Dim{dimLat, dimLon})
+	for _, variable := range []netcdf.Var{amplitude, phase} {
+		if err := variable.Attr("_FillValue").WriteFloat32s([]float32{fillValue}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	amplitude.Attr("units").WriteBytes([]byte(amplitudeUnit))
+	phase.Attr("units").WriteBytes([]byte("degrees"))
+	file.EndDef()
+
+	lat.WriteFloat32s(latitudes)
+	lon.WriteFloat32s(longitudes)
+	amplitudes := []float32{}
+	phases := []float32{}
+	for _, latitude := range latitudes {
+		for _, longitude := range longitudes {
+			a, p := testValues(latitude, float32(math.Mod(float64(longitude), 360)))
+			if amplitudeUnit == "m" && a != fillValue {
+				a = a / 100
+			}
+			amplitudes = append(amplitudes, a)
+			phases = append(phases, p)
+		}
+	}
+	if err := amplitude.WriteFloat32s(amplitudes); err != nil {
+		t.Fatal(err)
+	}
+	if err := phase.WriteFloat32s(phases); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+}
+
+func assertFesData(t *testing.T, amplitude *constituentdata.TideConstituentData, phase *constituentdata.TideConstituentData, constituent constituents.Constituent) {
+	if amplitude.Constituent != constituent || phase.Constituent != constituent {
+		t.Fatalf("expected %s, got %s and %s", constituent, amplitude.Constituent, phase.Constituent)
+	}
+	if amplitude.Type != constituentdata.AMPLITUDE || phase.Type != constituentdata.PHASE {
+		t.Fatalf("expected amplitude and phase, got %s and %s", amplitude.Type, phase.Type)
+	}
+	// the duplicated 360 column is dropped and the grid is rotated to -180..150
+	if amplitude.SizeX != 12 || amplitude.SizeY != 5 {
+		t.Fatalf("expected grid 12x5, got %dx%d", amplitude.SizeX, amplitude.SizeY)
+	}
+	if amplitude.LongitudeMin != -180 || amplitude.LongitudeMax != 150 || amplitude.LatitudeMin != -60 || amplitude.LatitudeMax != 60 {
+		t.Fatalf("unexpected grid limits lat %f-%f lon %f-%f", amplitude.LatitudeMin, amplitude.LatitudeMax, amplitude.LongitudeMin, amplitude.LongitudeMax)
+	}
+	for y := 0; y < amplitude.SizeY; y++ {
+		for x := 0; x < amplitude.SizeX; x++ {
+			lat := float32(-60 + y*30)
+			lon := float32(-180 + x*30)
+			if lon < 0 {
+				lon = lon + 360
+			}
+			expectedAmplitude, expectedPhase := testValues(lat, lon)
+			if expectedAmplitude == fillValue {
+				expectedAmplitude, expectedPhase = fes.UNDEF_VALUE, fes.UNDEF_VALUE
+			}
+			if math.Abs(float64(amplitude.Data[y][x]-expectedAmplitude)) > 1e-3 || phase.Data[y][x] != expectedPhase {
+				t.Errorf("%d,%d: expected %f/%f, got %f/%f", x, y, expectedAmplitude, expectedPhase, amplitude.Data[y][x], phase.Data[y][x])
+			}
+		}
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	directory := t.TempDir()
+	writeFesFile(t, filepath.Join(directory, "m2.nc"), "cm")
+	writeFesFile(t, filepath.Join(directory, "la2.nc"), "cm")
+	writeFesFile(t, filepath.Join(directory, "S2_fes2022.nc"), "m")
+
+	loader, err := fes.CreateFESLoader(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	// files are loaded in lexical order
+	for _, constituent := range []constituents.Constituent{constituents.C_S2, constituents.C_LAM2, constituents.C_M2} {
+		amplitude, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		phase, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertFesData(t, amplitude, phase, constituent)
+	}
+	if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestLoadGlob(t *testing.T) {
+	directory := t.TempDir()
+	writeFesFile(t, filepath.Join(directory, "m2.nc"), "cm")
+	writeFesFile(t, filepath.Join(directory, "k1.nc"), "cm")
+
+	loader, err := fes.CreateFESLoader(filepath.Join(directory, "m*.nc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	amplitude, err := loader.GetNextConstituentData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amplitude.Constituent != constituents.C_M2 {
+		t.Errorf("expected M2, got %s", amplitude.Constituent)
+	}
+	loader.GetNextConstituentData()
+	if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	if _, err := fes.CreateFESLoader(filepath.Join(directory, "x*.nc")); err != fes.ErrNoFilesFound {
+		t.Errorf("expected ErrNoFilesFound, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package fes
+
+// maps the grid of a FES file to a grid with Data[0][0] at the south-west corner, global grids
+// in the 0-360 longitude convention are rotated to -180..180 and a duplicated 360 degree column is dropped
+type gridLayout struct {
+	sizeX        int
+	sizeY        int
+	latitudeMin  float32
+	latitudeMax  float32
+	longitudeMin float32
+	longitudeMax float32
+
+	flipY bool
+	// column of the file for each column of the layout
+	columns []int
+}
+
+func newGridLayout(latitudes []float32, longitudes []float32) gridLayout {
+	layout := gridLayout{
+		sizeY:       len(latitudes),
+		latitudeMin: latitudes[0],
+		latitudeMax: latitudes[len(latitudes)-1],
+	}
+	if layout.latitudeMin > layout.latitudeMax {
+		layout.flipY = true
+		layout.latitudeMin, layout.latitudeMax = layout.latitudeMax, layout.latitudeMin
+	}
+
+	sizeX := len(longitudes)
+	resolution := (longitudes[sizeX-1] - longitudes[0]) / float32(sizeX-1)
+	if longitudes[sizeX-1]-longitudes[0] >= 360-resolution/2 {
+		sizeX = sizeX - 1
+	}
+	global := longitudes[sizeX-1]-longitudes[0]+resolution >= 360-resolution/2
+
+	firstColumn := 0
+	shift := float32(0)
+	if global && longitudes[0] >= 0 && longitudes[sizeX-1] > 180 {
+		for firstColumn < sizeX && longitudes[firstColumn] < 180 {
+			firstColumn = firstColumn + 1
+		}
+		shift = -360
+	} else if !global && longitudes[0] >= 180 {
+		shift = -360
+	}
+
+	layout.sizeX = sizeX
+	layout.columns = make([]int, sizeX)
+	for x := 0; x < sizeX; x++ {
+		layout.columns[x] = (firstColumn + x) % sizeX
+	}
+	layout.longitudeMin = longitudes[firstColumn] + shift
+	layout.longitudeMax = layout.longitudeMin + float32(sizeX-1)*resolution
+	return layout
+}
+
+// rearranges the values of a file ([lat][lon]) and multiplies all defined values with factor
+func (g gridLayout) apply(values [][]float32, factor float32) [][]float32 {
+	data := make([][]float32, g.sizeY)
+	for y := 0; y < g.sizeY; y++ {
+		sourceY := y
+		if g.flipY {
+			sourceY = g.sizeY - 1 - y
+		}
+		data[y] = make([]float32, g.sizeX)
+		for x, sourceX := range g.columns {
+			value := values[sourceY][sourceX]
+			if value != UNDEF_VALUE {
+				value = value * factor
+			}
+			data[y][x] = value
+		}
+	}
+	return data
+}
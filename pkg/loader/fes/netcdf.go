@@ -0,0 +1,148 @@
+//go:build cgo && !nonetcdf
+
+package fes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+func readFile(filePath string) (*constituentdata.TideConstituentData, *constituentdata.TideConstituentData, error) {
+	constituent, err := constituentFromFileName(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// compressed files are decompressed into memory
+	file, err := constituentdata.OpenNetcdfFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	latitudes, err := readCoordinates(file, VAR_LATITUDE)
+	if err != nil {
+		return nil, nil, err
+	}
+	longitudes, err := readCoordinates(file, VAR_LONGITUDE)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(latitudes) < 2 || len(longitudes) < 2 {
+		return nil, nil, fmt.Errorf("grid too small")
+	}
+
+	amplitudes, err := readGrid(file, VAR_AMPLITUDE, len(latitudes), len(longitudes))
+	if err != nil {
+		return nil, nil, err
+	}
+	phases, err := readGrid(file, VAR_PHASE, len(latitudes), len(longitudes))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amplitudeVariable, _ := file.Var(VAR_AMPLITUDE)
+	amplitudeFactor, err := unitFactor(&amplitudeVariable, DEFAULT_AMPLITUDE_UNIT, constituentdata.AmplitudeFactorToCm)
+	if err != nil {
+		return nil, nil, err
+	}
+	phaseVariable, _ := file.Var(VAR_PHASE)
+	phaseFactor, err := unitFactor(&phaseVariable, DEFAULT_PHASE_UNIT, phaseFactorToDegree)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layout := newGridLayout(latitudes, longitudes)
+	newData := func(valueType constituentdata.ConstituentValueType, values [][]float32, factor float32) *constituentdata.TideConstituentData {
+		return &constituentdata.TideConstituentData{
+			Constituent:  constituent,
+			Type:         valueType,
+			SizeX:        layout.sizeX,
+			SizeY:        layout.sizeY,
+			LatitudeMin:  layout.latitudeMin,
+			LatitudeMax:  layout.latitudeMax,
+			LongitudeMin: layout.longitudeMin,
+			LongitudeMax: layout.longitudeMax,
+			UndefValue:   UNDEF_VALUE,
+			Data:         layout.apply(values, factor),
+		}
+	}
+	return newData(constituentdata.AMPLITUDE, amplitudes, amplitudeFactor), newData(constituentdata.PHASE, phases, phaseFactor), nil
+}
+
+func unitFactor(variable *netcdf.Var, defaultUnit string, factor func(string) (float32, error)) (float32, error) {
+	unit, err := utils.NetcdfGetStringFromAttribute(ATTR_UNITS, variable)
+	if errors.Is(err, utils.ErrNetcdfAttributeNotFound) {
+		unit = defaultUnit
+	} else if err != nil {
+		return 0, err
+	}
+	return factor(unit)
+}
+
+func readCoordinates(file *constituentdata.NetcdfFile, name string) ([]float32, error) {
+	variable, err := file.Var(name)
+	if err != nil {
+		return nil, err
+	}
+	return utils.NetcdfReadFloat32s(&variable)
+}
+
+// reads a (lat, lon) or (lon, lat) variable into [lat][lon], undefined values are replaced by UNDEF_VALUE
+// and scale_factor/add_offset are applied to all other values
+func readGrid(file *constituentdata.NetcdfFile, name string, sizeLat int, sizeLon int) ([][]float32, error) {
+	variable, err := file.Var(name)
+	if err != nil {
+		return nil, err
+	}
+	dimensions, err := utils.NetcdfGetDimensionNames(&variable)
+	if err != nil {
+		return nil, err
+	}
+	if len(dimensions) != 2 {
+		return nil, fmt.Errorf("%s must have two dimensions", name)
+	}
+	latitudeFirst := dimensions[0] == VAR_LATITUDE
+
+	values, err := utils.NetcdfReadFloat32s(&variable)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != sizeLat*sizeLon {
+		return nil, fmt.Errorf("%s does not match the size of the coordinates", name)
+	}
+
+	fillValues := []float32{}
+	for _, attribute := range []string{ATTR_FILL_VALUE, ATTR_MISSING} {
+		fillValue, err := utils.NetcdfGetFloat64FromAttribute(attribute, &variable)
+		if err == nil {
+			fillValues = append(fillValues, float32(fillValue))
+		} else if !errors.Is(err, utils.ErrNetcdfAttributeNotFound) {
+			return nil, err
+		}
+	}
+	scale, offset := float32(1), float32(0)
+	if value, err := utils.NetcdfGetFloat64FromAttribute(ATTR_SCALE, &variable); err == nil {
+		scale = float32(value)
+	}
+	if value, err := utils.NetcdfGetFloat64FromAttribute(ATTR_OFFSET, &variable); err == nil {
+		offset = float32(value)
+	}
+
+	grid := make([][]float32, sizeLat)
+	for y := 0; y < sizeLat; y++ {
+		grid[y] = make([]float32, sizeLon)
+		for x := 0; x < sizeLon; x++ {
+			index := x*sizeLat + y
+			if latitudeFirst {
+				index = y*sizeLon + x
+			}
+			grid[y][x] = scaleValue(values[index], fillValues, scale, offset)
+		}
+	}
+	return grid, nil
+}
@@ -0,0 +1,9 @@
+//go:build !cgo || nonetcdf
+
+package fes
+
+import "github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+
+func readFile(filePath string) (*constituentdata.TideConstituentData, *constituentdata.TideConstituentData, error) {
+	return nil, nil, constituentdata.ErrNetcdfNotSupported
+}
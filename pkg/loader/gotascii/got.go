@@ -0,0 +1,294 @@
+package gotascii
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/loader/dtu16ascii"
+)
+
+var ErrNoFilesFound = errors.New("no GOT files found")
+
+const FILE_EXTENSION = ".d"
+
+// the number of description lines between the title and the grid size differs between the
+// GOT releases, more lines than this are treated as a broken header
+const MAX_DESCRIPTION_LINES = 4
+
+type gotFile struct {
+	path        string
+	constituent constituents.Constituent
+	// type of the first grid block in the file
+	constituentType constituentdata.ConstituentValueType
+}
+
+type gotFiles struct {
+	constituentdata.ConstituentDataLoader
+	files   []gotFile
+	current int
+	file    io.ReadCloser
+	reader  *bufio.Reader
+}
+
+// creates a loader for the ascii grids of Ray's GOT4.x/GOT5 models. GOT4.x stores the amplitude
+// and phase grid of a constituent one after the other in a single file (e.g. m2.d), GOT5 uses
+// separate files for amplitude and phase. filePath is a file, a directory with .d files or a glob pattern,
+// the files are ordered so that the amplitude of each constituent is followed by its phase.
+// compressed files (e.g. m2.d.gz) are decompressed while reading
+func CreateGOTLoader(filePath string) (constituentdata.ConstituentDataLoader, error) {
+	paths := []string{}
+	info, err := os.Stat(filePath)
+	if err == nil && info.IsDir() {
+		paths, err = globWithExtension(filepath.Join(filePath, "*"), FILE_EXTENSION)
+	} else {
+		paths, err = filepath.Glob(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, ErrNoFilesFound
+	}
+
+	files := make([]gotFile, 0, len(paths))
+	for _, path := range paths {
+		header, err := readFirstHeader(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		files = append(files, gotFile{path: path, constituent: header.Constituent, constituentType: header.ConstituentType})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].constituent != files[j].constituent {
+			return files[i].constituent.String() < files[j].constituent.String()
+		}
+		return files[i].constituentType == constituentdata.AMPLITUDE && files[j].constituentType != constituentdata.AMPLITUDE
+	})
+
+	return &gotFiles{files: files}, nil
+}
+
+// returns the files matching pattern with the extension, compressed or uncompressed
+func globWithExtension(pattern string, extension string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, match := range matches {
+		if constituentdata.HasExtension(match, extension) {
+			paths = append(paths, match)
+		}
+	}
+	return paths, nil
+}
+
+func readFirstHeader(path string) (dtu16ascii.GridHeader, error) {
+	file, err := constituentdata.OpenFile(path)
+	if err != nil {
+		return dtu16ascii.GridHeader{}, err
+	}
+	defer file.Close()
+	return ParseHeader(bufio.NewReader(file), constituentdata.StripCompressionExtension(path))
+}
+
+// detects a GOT ascii grid by parsing the header of the first grid block, if reader is a file
+// its name is used for the constituent and type like in the loader
+func Detect(reader io.ReadSeeker) bool {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	filePath := ""
+	if file, ok := reader.(interface{ Name() string }); ok {
+		filePath = file.Name()
+	}
+	header, err := ParseHeader(bufio.NewReader(io.LimitReader(reader, constituentdata.DETECT_HEADER_SIZE)), filePath)
+	return err == nil && header.GridX > 0 && header.GridY > 0
+}
+
+func (g *gotFiles) Close() error {
+	if g.file != nil {
+		err := g.file.Close()
+		g.file = nil
+		return err
+	}
+	return nil
+}
+
+func (g *gotFiles) GetNextConstituentData() (*constituentdata.TideConstituentData, error) {
+	for {
+		if g.file == nil {
+			if g.current >= len(g.files) {
+				return nil, io.EOF
+			}
+			file, err := constituentdata.OpenFile(g.files[g.current].path)
+			if err != nil {
+				return nil, err
+			}
+			g.file = file
+			g.reader = bufio.NewReader(file)
+		}
+
+		header, err := ParseHeader(g.reader, constituentdata.StripCompressionExtension(g.files[g.current].path))
+		if errors.Is(err, io.EOF) {
+			// no more grids in the file, continue with the next one
+			g.Close()
+			g.current = g.current + 1
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", g.files[g.current].path, err)
+		}
+		return dtu16ascii.ReadGridData(g.reader, header)
+	}
+}
+
+// parses the header of a GOT grid block, returns io.EOF if there is no further block.
+//
+// the block starts with a title holding the constituent and the type of the grid, followed by
+// description lines, the grid size (nlat nlon), the latitude and longitude limits, the undefined
+// values (amplitude and phase mask, the first one is used) and the fortran format of the rows.
+// if the title does not name the constituent or type, the file name is used (e.g. m2_amp.d)
+func ParseHeader(reader *bufio.Reader, filePath string) (dtu16ascii.GridHeader, error) {
+	header := dtu16ascii.GridHeader{}
+
+	title, err := readNonEmptyLine(reader)
+	if err != nil {
+		return header, err
+	}
+
+	descriptionLines := []string{title}
+	var gridSize []int
+	for gridSize == nil {
+		line, err := readLine(reader)
+		if err != nil {
+			return header, unexpectedEOF(err)
+		}
+		if gridSize = parseInts(line); gridSize != nil {
+			break
+		}
+		descriptionLines = append(descriptionLines, line)
+		if len(descriptionLines) > MAX_DESCRIPTION_LINES+1 {
+			return header, fmt.Errorf("grid size not found in header")
+		}
+	}
+	header.GridY = gridSize[0]
+	header.GridX = gridSize[1]
+
+	header.Constituent, err = constituentFromHeader(title, filePath)
+	if err != nil {
+		return header, err
+	}
+	header.ConstituentType = typeFromHeader(append(descriptionLines, filepath.Base(filePath)))
+	if header.ConstituentType == "" {
+		return header, fmt.Errorf("constituent type not found")
+	}
+
+	latMinMax, err := readLine(reader)
+	if err != nil {
+		return header, unexpectedEOF(err)
+	}
+	if _, err := fmt.Sscanf(latMinMax, "%f %f", &header.LatitudeMin, &header.LatitudeMax); err != nil {
+		return header, err
+	}
+	lonMinMax, err := readLine(reader)
+	if err != nil {
+		return header, unexpectedEOF(err)
+	}
+	if _, err := fmt.Sscanf(lonMinMax, "%f %f", &header.LongitudeMin, &header.LongitudeMax); err != nil {
+		return header, err
+	}
+	undefValue, err := readLine(reader)
+	if err != nil {
+		return header, unexpectedEOF(err)
+	}
+	if _, err := fmt.Sscanf(undefValue, "%f", &header.UndefValue); err != nil {
+		return header, err
+	}
+	entriesPerLine, err := readLine(reader)
+	if err != nil {
+		return header, unexpectedEOF(err)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(entriesPerLine), "(%d", &header.EntriesPerLine); err != nil {
+		return header, err
+	}
+	return header, nil
+}
+
+func constituentFromHeader(title string, filePath string) (constituents.Constituent, error) {
+	for _, field := range strings.Fields(title) {
+		if constituent, err := constituents.FromString(field); err == nil {
+			return constituent, nil
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	name = strings.Split(name, "_")[0]
+	if constituent, err := constituents.FromString(name); err == nil {
+		return constituent, nil
+	}
+	return constituents.C_UNKNOWN, fmt.Errorf("unknown constituent in title %s", title)
+}
+
+func typeFromHeader(lines []string) constituentdata.ConstituentValueType {
+	for _, line := range lines {
+		line = strings.ToLower(line)
+		if strings.Contains(line, "amplitude") || strings.Contains(line, "_amp") {
+			return constituentdata.AMPLITUDE
+		} else if strings.Contains(line, "phase") || strings.Contains(line, "_pha") {
+			return constituentdata.PHASE
+		}
+	}
+	return ""
+}
+
+// returns the two integers of a line or nil
+func parseInts(line string) []int {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return nil
+	}
+	values := make([]int, 2)
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil
+		}
+		values[i] = value
+	}
+	return values
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return line, nil
+}
+
+func readNonEmptyLine(reader *bufio.Reader) (string, error) {
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(line) != "" {
+			return line, nil
+		}
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
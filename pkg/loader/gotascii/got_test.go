@@ -0,0 +1,129 @@
+package gotascii_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/loader/gotascii"
+)
+
+const sizeX, sizeY = 13, 3
+
+// writes a grid block in the GOT layout, each row starts on a new line with 11 values per line
+func gotBlock(title string, value func(x int, y int) float32) string {
+	builder := strings.Builder{}
+	builder.WriteString(title + "\n")
+	builder.WriteString("                             GOT test   \n")
+	builder.WriteString(fmt.Sprintf("%6d%6d\n", sizeY, sizeX))
+	builder.WriteString("  50.00000  51.00000\n")
+	builder.WriteString("   0.00000   6.00000\n")
+	builder.WriteString(" 99999.000 99999.000\n")
+	builder.WriteString("(11f7.2)\n")
+	for y := 0; y < sizeY; y++ {
+		for x := 0; x < sizeX; x++ {
+			builder.WriteString(fmt.Sprintf("%7.2f", value(x, y)))
+			if x%11 == 10 || x == sizeX-1 {
+				builder.WriteString("\n")
+			}
+		}
+	}
+	return builder.String()
+}
+
+func amplitudeValue(x int, y int) float32 {
+	if x == 0 && y == 0 {
+		return 99999
+	}
+	return float32(x + y*sizeX)
+}
+
+func phaseValue(x int, y int) float32 {
+	if x == 0 && y == 0 {
+		return 99999
+	}
+	return float32(x*10) + 0.5
+}
+
+func assertGrid(t *testing.T, data *constituentdata.TideConstituentData, constituent constituents.Constituent, valueType constituentdata.ConstituentValueType, value func(x int, y int) float32) {
+	if data.Constituent != constituent || data.Type != valueType {
+		t.Fatalf("expected %s %s, got %s %s", constituent, valueType, data.Constituent, data.Type)
+	}
+	if data.SizeX != sizeX || data.SizeY != sizeY || data.UndefValue != 99999 {
+		t.Fatalf("unexpected grid %dx%d undef %f", data.SizeX, data.SizeY, data.UndefValue)
+	}
+	if data.LatitudeMin != 50 || data.LatitudeMax != 51 || data.LongitudeMin != 0 || data.LongitudeMax != 6 {
+		t.Fatalf("unexpected grid limits lat %f-%f lon %f-%f", data.LatitudeMin, data.LatitudeMax, data.LongitudeMin, data.LongitudeMax)
+	}
+	for y := 0; y < sizeY; y++ {
+		for x := 0; x < sizeX; x++ {
+			if data.Data[y][x] != value(x, y) {
+				t.Errorf("%d,%d: expected %f, got %f", x, y, value(x, y), data.Data[y][x])
+			}
+		}
+	}
+}
+
+func TestSingleFileWithAmplitudeAndPhase(t *testing.T) {
+	filePath := testutil.WriteFile(t, t.TempDir(), "m2.d", []byte(gotBlock("M2 tide  amplitude  (cm)", amplitudeValue)+gotBlock("M2 tide  phase  (Greenwich lags)", phaseValue)))
+
+	loader, err := gotascii.CreateGOTLoader(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	amplitude, err := loader.GetNextConstituentData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGrid(t, amplitude, constituents.C_M2, constituentdata.AMPLITUDE, amplitudeValue)
+	phase, err := loader.GetNextConstituentData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGrid(t, phase, constituents.C_M2, constituentdata.PHASE, phaseValue)
+	if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestSeparateFiles(t *testing.T) {
+	directory := t.TempDir()
+	// the phase file comes first in lexical order and the title has no constituent
+	testutil.WriteFile(t, directory, "k1_1.d", []byte(gotBlock("GOT5 phase", phaseValue)))
+	testutil.WriteFile(t, directory, "k1_2.d", []byte(gotBlock("GOT5 amplitude", amplitudeValue)))
+	testutil.WriteFile(t, directory, "o1.d", []byte(gotBlock("O1 tide  amplitude  (cm)", amplitudeValue)+gotBlock("O1 tide  phase  (Greenwich lags)", phaseValue)))
+
+	loader, err := gotascii.CreateGOTLoader(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	for _, constituent := range []constituents.Constituent{constituents.C_K1, constituents.C_O1} {
+		amplitude, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertGrid(t, amplitude, constituent, constituentdata.AMPLITUDE, amplitudeValue)
+		phase, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertGrid(t, phase, constituent, constituentdata.PHASE, phaseValue)
+	}
+	if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	if _, err := gotascii.CreateGOTLoader(filepath.Join(directory, "x*.d")); err != gotascii.ErrNoFilesFound {
+		t.Errorf("expected ErrNoFilesFound, got %v", err)
+	}
+}
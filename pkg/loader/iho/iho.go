@@ -0,0 +1,155 @@
+/*
+Loader for tidal constituent tables of stations in the layout of the IHO tidal constituent bank, e.g.
+
+	# comment
+	STATION 9414290
+	NAME    San Francisco
+	LAT     37.8063
+	LON     -122.4659
+	UNITS   m
+	M2   0.580  200.1
+	S2   0.134  206.3
+	K1   0.370  105.9
+
+each STATION keyword starts a new station, constituent lines hold the name, the amplitude and
+the greenwich phase lag in degree, further columns are ignored. The amplitude unit defaults to meters.
+*/
+package iho
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+var (
+	ErrNoStationsFound = errors.New("no stations found")
+	ErrNoStation       = errors.New("constituent before the first STATION keyword")
+)
+
+const (
+	KEYWORD_STATION = "STATION"
+	KEYWORD_NAME    = "NAME"
+	KEYWORD_LAT     = "LAT"
+	KEYWORD_LON     = "LON"
+	KEYWORD_UNITS   = "UNITS"
+
+	DEFAULT_AMPLITUDE_UNIT = "m"
+)
+
+// names used in IHO and admiralty tables which differ from the names of the constituents package
+var constituentAliases = map[string]constituents.Constituent{
+	"RHO1": constituents.C_RHO,
+	"SIG1": constituents.C_SIGMA1,
+	"LDA2": constituents.C_LAM2,
+}
+
+func LoadIHOStations(filePath string) ([]stationdb.Station, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stations := []stationdb.Station{}
+	var current *stationdb.Station
+	amplitudeFactor, err := constituentdata.AmplitudeFactorToCm(DEFAULT_AMPLITUDE_UNIT)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber = lineNumber + 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		keyword := strings.ToUpper(fields[0])
+		value := strings.TrimSpace(line[len(fields[0]):])
+
+		if keyword == KEYWORD_STATION {
+			if current != nil {
+				stations = append(stations, *current)
+			}
+			current = &stationdb.Station{ID: value}
+			amplitudeFactor, _ = constituentdata.AmplitudeFactorToCm(DEFAULT_AMPLITUDE_UNIT)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%w in line %d", ErrNoStation, lineNumber)
+		}
+
+		switch keyword {
+		case KEYWORD_NAME:
+			current.Name = value
+		case KEYWORD_LAT:
+			current.Lat, err = parseFloat32(value)
+		case KEYWORD_LON:
+			current.Lon, err = parseFloat32(value)
+		case KEYWORD_UNITS:
+			amplitudeFactor, err = constituentdata.AmplitudeFactorToCm(value)
+		default:
+			err = parseConstituent(current, fields, amplitudeFactor)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		stations = append(stations, *current)
+	}
+	if len(stations) == 0 {
+		return nil, ErrNoStationsFound
+	}
+	return stations, nil
+}
+
+func parseConstituent(station *stationdb.Station, fields []string, amplitudeFactor float32) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("expected constituent, amplitude and phase, got %s", strings.Join(fields, " "))
+	}
+	constituent, found := constituentAliases[strings.ToUpper(fields[0])]
+	if !found {
+		var err error
+		constituent, err = constituents.FromString(fields[0])
+		if err != nil {
+			constituent = constituents.C_UNKNOWN
+		}
+	}
+	// constituents not known to the solvers are skipped
+	if constituent == constituents.C_UNKNOWN {
+		return nil
+	}
+	amplitude, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return err
+	}
+	phase, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return err
+	}
+	station.Constituents = append(station.Constituents, constituents.ConstituentDatum{
+		Constituent: constituent,
+		Amplitude:   amplitude * float64(amplitudeFactor),
+		Phase:       phase,
+	})
+	return nil
+}
+
+func parseFloat32(value string) (float32, error) {
+	parsed, err := strconv.ParseFloat(value, 32)
+	return float32(parsed), err
+}
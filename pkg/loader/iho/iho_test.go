@@ -0,0 +1,74 @@
+package iho_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/iho"
+)
+
+const ihoTable = `# test stations
+STATION 9414290
+NAME    San Francisco
+LAT     37.8063
+LON     -122.4659
+M2   0.580  200.1
+K1   0.370  105.9
+LDA2 0.010  190.0
+XX9  1.000  1.0
+
+STATION 0001
+NAME    Test Harbour
+LAT     54.5
+LON     10.25
+UNITS   cm
+M2   12.5   300.0  extra column
+`
+
+func TestLoadIHOStations(t *testing.T) {
+	stations, err := iho.LoadIHOStations(testutil.WriteFile(t, t.TempDir(), "stations.txt", []byte(ihoTable)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 {
+		t.Fatalf("expected 2 stations, got %d", len(stations))
+	}
+
+	first := stations[0]
+	if first.ID != "9414290" || first.Name != "San Francisco" || first.Lat != 37.8063 || first.Lon != -122.4659 {
+		t.Errorf("unexpected station %+v", first)
+	}
+	if len(first.Constituents) != 3 {
+		t.Fatalf("expected 3 constituents, got %d", len(first.Constituents))
+	}
+	expected := []constituents.ConstituentDatum{
+		{Constituent: constituents.C_M2, Amplitude: 58, Phase: 200.1},
+		{Constituent: constituents.C_K1, Amplitude: 37, Phase: 105.9},
+		{Constituent: constituents.C_LAM2, Amplitude: 1, Phase: 190},
+	}
+	for index, datum := range first.Constituents {
+		if datum.Constituent != expected[index].Constituent || math.Abs(datum.Amplitude-expected[index].Amplitude) > 1e-9 || datum.Phase != expected[index].Phase {
+			t.Errorf("expected %+v, got %+v", expected[index], datum)
+		}
+	}
+
+	second := stations[1]
+	if second.ID != "0001" || second.Name != "Test Harbour" || len(second.Constituents) != 1 || second.Constituents[0].Amplitude != 12.5 {
+		t.Errorf("unexpected station %+v", second)
+	}
+}
+
+func TestLoadIHOStationsErrors(t *testing.T) {
+	if _, err := iho.LoadIHOStations(testutil.WriteFile(t, t.TempDir(), "stations.txt", []byte("# empty\n"))); !errors.Is(err, iho.ErrNoStationsFound) {
+		t.Errorf("expected ErrNoStationsFound, got %v", err)
+	}
+	if _, err := iho.LoadIHOStations(testutil.WriteFile(t, t.TempDir(), "stations.txt", []byte("M2 0.5 100\n"))); !errors.Is(err, iho.ErrNoStation) {
+		t.Errorf("expected ErrNoStation, got %v", err)
+	}
+	if _, err := iho.LoadIHOStations(testutil.WriteFile(t, t.TempDir(), "stations.txt", []byte("STATION 1\nUNITS furlong\n"))); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
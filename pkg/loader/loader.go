@@ -2,17 +2,50 @@ package loader
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
+	"sort"
 
+	"github.com/mzeiher/perth3-go/pkg/loader/adcirc"
 	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
 	"github.com/mzeiher/perth3-go/pkg/loader/dtu16ascii"
+	"github.com/mzeiher/perth3-go/pkg/loader/fes"
+	"github.com/mzeiher/perth3-go/pkg/loader/gotascii"
+	"github.com/mzeiher/perth3-go/pkg/loader/iho"
+	"github.com/mzeiher/perth3-go/pkg/loader/noaa"
+	"github.com/mzeiher/perth3-go/pkg/loader/tpxo"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
 )
 
-var ErrNoLoaderFound = errors.New("no loader found for selected format")
+var (
+	ErrNoLoaderFound     = errors.New("no loader found for selected format")
+	ErrFormatNotDetected = errors.New("format of input could not be detected")
+)
 
 var loader map[string]constituentdata.CreateLoaderFunction = make(map[string]constituentdata.CreateLoaderFunction)
 
+// detectors in the order in which the formats are tried, see registerDetector
+var detectors []formatDetector = []formatDetector{}
+var stationLoader map[string]stationdb.LoadStationsFunction = make(map[string]stationdb.LoadStationsFunction)
+
 func init() {
 	loader["dtu16ascii"] = dtu16ascii.CreateDTU16Loader
+	loader["tpxo"] = tpxo.CreateTPXOLoader
+	loader["fes"] = fes.CreateFESLoader
+	loader["got"] = gotascii.CreateGOTLoader
+	loader["adcirc"] = adcirc.CreateADCIRCLoader
+
+	// the more specific checks come first: the TPXO NetCDF files may also mention amplitude
+	// and phase and DTU16 headers are a stricter variant of the GOT headers
+	registerDetector("tpxo", tpxo.Detect)
+	registerDetector("fes", fes.Detect)
+	registerDetector("adcirc", adcirc.Detect)
+	registerDetector("dtu16ascii", dtu16ascii.Detect)
+	registerDetector("got", gotascii.Detect)
+
+	stationLoader["noaajson"] = noaa.LoadNOAAJSONStations
+	stationLoader["noaacsv"] = noaa.LoadNOAACSVStations
+	stationLoader["iho"] = iho.LoadIHOStations
 }
 
 func GetLoader(format string, filePath string) (constituentdata.ConstituentDataLoader, error) {
@@ -21,3 +54,73 @@ func GetLoader(format string, filePath string) (constituentdata.ConstituentDataL
 	}
 	return loader[format](filePath)
 }
+
+type formatDetector struct {
+	format string
+	detect constituentdata.DetectFunction
+}
+
+// formats are tried in the order of their registration
+func registerDetector(format string, detect constituentdata.DetectFunction) {
+	detectors = append(detectors, formatDetector{format: format, detect: detect})
+}
+
+// detects the format of the input by sniffing its content, filePath is a file, a directory or a glob
+// pattern like accepted by the loaders, in the latter cases the files are tried until one is detected
+func DetectFormat(filePath string) (string, error) {
+	files := []string{}
+	info, err := os.Stat(filePath)
+	if err == nil && info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(filePath, "*"))
+	} else if err == nil {
+		files = []string{filePath}
+	} else {
+		files, err = filepath.Glob(filePath)
+	}
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		format, err := detectFileFormat(file)
+		if err != nil {
+			return "", err
+		}
+		if format != "" {
+			return format, nil
+		}
+	}
+	return "", ErrFormatNotDetected
+}
+
+// returns the format of a single file or an empty string if no loader detects it
+func detectFileFormat(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil
+	}
+	// compressed files are detected by their decompressed content
+	header, err := constituentdata.OpenHeaderReader(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, detector := range detectors {
+		if detector.detect(header) {
+			return detector.format, nil
+		}
+	}
+	return "", nil
+}
+
+// loads the harmonic constants of all stations in the station file
+func LoadStations(format string, filePath string) ([]stationdb.Station, error) {
+	if stationLoader[format] == nil {
+		return nil, ErrNoLoaderFound
+	}
+	return stationLoader[format](filePath)
+}
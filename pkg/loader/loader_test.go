@@ -0,0 +1,170 @@
+package loader_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+const gridBlock = "   0.00000   1.00000\n   0.00000   1.00000\n 99999.000 99999.000\n(11f7.2)\n   1.00   2.00\n   3.00   4.00\n"
+
+const dtu16File = "M2 amplitude\n DTU16 test\n     2     2\n" + gridBlock
+
+const gotFile = "Ray GOT4.10c tide model\n M2 amplitude (cm)\n\n     2     2\n" + gridBlock
+
+const adcircFile = "1\n 0.1405189025E-03 1.0000000 0.0000000 M2\n3\n1\n 0.5 10.0\n2\n 0.5 10.0\n3\n 0.5 10.0\n"
+
+// writes a fortran unformatted big endian record
+func writeRecord(buffer *bytes.Buffer, record []byte) {
+	binary.Write(buffer, binary.BigEndian, uint32(len(record)))
+	buffer.Write(record)
+	binary.Write(buffer, binary.BigEndian, uint32(len(record)))
+}
+
+func otisFile() []byte {
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.BigEndian, []int32{2, 2, 1})
+	binary.Write(header, binary.BigEndian, []float32{0, 1, 0, 1})
+	header.WriteString("m2  ")
+	buffer := &bytes.Buffer{}
+	writeRecord(buffer, header.Bytes())
+	writeRecord(buffer, make([]byte, 2*2*8))
+	return buffer.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content []byte
+		format  string
+	}{
+		{"fort.30", []byte(dtu16File), "dtu16ascii"},
+		{"m2.d", []byte(gotFile), "got"},
+		{"h_tpxo.out", otisFile(), "tpxo"},
+		{"h_tpxo.nc", []byte("CDF\x01 nx ny nc hRe hIm lon_z lat_z amplitude phase"), "tpxo"},
+		{"m2_fes.nc", []byte("\x89HDF\r\n\x1a\n lat lon amplitude phase"), "fes"},
+		{"fort.53", []byte(adcircFile), "adcirc"},
+	}
+	for _, testCase := range testCases {
+		format, err := loader.DetectFormat(testutil.WriteFile(t, dir, testCase.name, testCase.content))
+		if err != nil {
+			t.Errorf("%s: %s", testCase.name, err)
+			continue
+		}
+		if format != testCase.format {
+			t.Errorf("%s: expected format %s, got %s", testCase.name, testCase.format, format)
+		}
+	}
+}
+
+func TestDetectFormatDirectory(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "README", []byte("GOT grids\n"))
+	testutil.WriteFile(t, dir, "m2.d", []byte(gotFile))
+
+	format, err := loader.DetectFormat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "got" {
+		t.Errorf("expected format got, got %s", format)
+	}
+
+	format, err = loader.DetectFormat(filepath.Join(dir, "*.d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "got" {
+		t.Errorf("expected format got, got %s", format)
+	}
+}
+
+func TestDetectFormatUnknown(t *testing.T) {
+	dir := t.TempDir()
+	for _, content := range [][]byte{[]byte("just some text\n"), []byte("CDF\x01 x y z"), {}} {
+		_, err := loader.DetectFormat(testutil.WriteFile(t, dir, "unknown", content))
+		if !errors.Is(err, loader.ErrFormatNotDetected) {
+			t.Errorf("%q: expected ErrFormatNotDetected, got %v", content, err)
+		}
+	}
+	if _, err := loader.DetectFormat(filepath.Join(dir, "*.missing")); !errors.Is(err, loader.ErrFormatNotDetected) {
+		t.Errorf("expected ErrFormatNotDetected for a glob without matches, got %v", err)
+	}
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func zipped(t *testing.T, name string, content []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	member, err := writer.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := member.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestLoadCompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content []byte
+		format  string
+	}{
+		{"fort.30.gz", gzipped(t, []byte(dtu16File)), "dtu16ascii"},
+		{"fort.30.zip", zipped(t, "fort.30", []byte(dtu16File)), "dtu16ascii"},
+		{"m2.d.gz", gzipped(t, []byte(gotFile)), "got"},
+		{"h_tpxo.out.gz", gzipped(t, otisFile()), "tpxo"},
+	}
+	for _, testCase := range testCases {
+		filePath := testutil.WriteFile(t, dir, testCase.name, testCase.content)
+		format, err := loader.DetectFormat(filePath)
+		if err != nil {
+			t.Errorf("%s: %s", testCase.name, err)
+			continue
+		}
+		if format != testCase.format {
+			t.Errorf("%s: expected format %s, got %s", testCase.name, testCase.format, format)
+			continue
+		}
+
+		constituentLoader, err := loader.GetLoader(format, filePath)
+		if err != nil {
+			t.Fatalf("%s: %s", testCase.name, err)
+		}
+		data, err := constituentLoader.GetNextConstituentData()
+		constituentLoader.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", testCase.name, err)
+		}
+		if data.Constituent != constituents.C_M2 || data.Type != constituentdata.AMPLITUDE || data.SizeX != 2 || data.SizeY != 2 {
+			t.Errorf("%s: unexpected grid %s %s %dx%d", testCase.name, data.Constituent, data.Type, data.SizeX, data.SizeY)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package noaa_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/noaa"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+const harconJson = `{
+  "units": "metric",
+  "HarmonicConstituents": [
+    {"number": 1, "name": "M2", "description": "Principal lunar semidiurnal constituent", "amplitude": 0.58, "phase_GMT": 200.1, "phase_local": 215.3, "speed": 28.984104},
+    {"number": 4, "name": "K1", "description": "Lunar diurnal constituent", "amplitude": 0.37, "phase_GMT": 105.9, "phase_local": 113.4, "speed": 15.041069},
+    {"number": 99, "name": "XX9", "description": "unknown", "amplitude": 1, "phase_GMT": 1, "phase_local": 1, "speed": 1}
+  ],
+  "self": "https://api.tidesandcurrents.noaa.gov/mdapi/prod/webapi/stations/9414290/harcon.json?units=metric"
+}`
+
+const stationsJson = `{
+  "count": 1,
+  "units": null,
+  "stations": [
+    {
+      "id": "8443970",
+      "name": "Boston",
+      "lat": 42.353931,
+      "lng": -71.050346,
+      "harmonicConstituents": {
+        "units": "english",
+        "HarmonicConstituents": [
+          {"number": 1, "name": "M2", "amplitude": 4.5, "phase_GMT": 110.0},
+          {"number": 2, "name": "S2", "amplitude": 0.7, "phase_GMT": 150.0}
+        ]
+      }
+    }
+  ]
+}`
+
+const harconCsv = `# Station ID: 9414290
+# Station Name: San Francisco
+# Latitude: 37.8063
+# Longitude: -122.4659
+Constituent #,Name,Amplitude (ft),Phase (local),Phase (GMT),Speed,Description
+1,M2,1.903,215.3,200.1,28.984104,"Principal lunar semidiurnal constituent"
+2,S2,0.440,222.0,206.3,30,"Principal solar semidiurnal constituent"
+37,XX9,1,1,1,1,unknown
+`
+
+func assertDatum(t *testing.T, station stationdb.Station, constituent constituents.Constituent, amplitude float64, phase float64) {
+	datum, err := station.GetConstituent(constituent)
+	if err != nil {
+		t.Fatalf("%s: %s", constituent, err)
+	}
+	if math.Abs(datum.Amplitude-amplitude) > 1e-4 || datum.Phase != phase {
+		t.Errorf("%s: expected %f/%f, got %f/%f", constituent, amplitude, phase, datum.Amplitude, datum.Phase)
+	}
+}
+
+func TestLoadHarconJson(t *testing.T) {
+	stations, err := noaa.LoadNOAAJSONStations(testutil.WriteFile(t, t.TempDir(), "harcon.json", []byte(harconJson)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 1 || stations[0].ID != "9414290" {
+		t.Fatalf("expected station 9414290, got %+v", stations)
+	}
+	if len(stations[0].Constituents) != 2 {
+		t.Errorf("expected unknown constituents to be skipped, got %d constituents", len(stations[0].Constituents))
+	}
+	assertDatum(t, stations[0], constituents.C_M2, 58, 200.1)
+	assertDatum(t, stations[0], constituents.C_K1, 37, 105.9)
+}
+
+func TestLoadStationsJson(t *testing.T) {
+	stations, err := noaa.LoadNOAAJSONStations(testutil.WriteFile(t, t.TempDir(), "stations.json", []byte(stationsJson)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 1 {
+		t.Fatalf("expected 1 station, got %d", len(stations))
+	}
+	station := stations[0]
+	if station.ID != "8443970" || station.Name != "Boston" || station.Lat != 42.353931 || station.Lon != -71.050346 {
+		t.Errorf("unexpected station %+v", station)
+	}
+	assertDatum(t, station, constituents.C_M2, 4.5*30.48, 110)
+	assertDatum(t, station, constituents.C_S2, 0.7*30.48, 150)
+}
+
+func TestLoadJsonWithoutConstituents(t *testing.T) {
+	_, err := noaa.LoadNOAAJSONStations(testutil.WriteFile(t, t.TempDir(), "empty.json", []byte(`{"units": "metric", "HarmonicConstituents": []}`)))
+	if !errors.Is(err, noaa.ErrNoConstituents) {
+		t.Fatalf("expected ErrNoConstituents, got %v", err)
+	}
+}
+
+func TestLoadHarconCsv(t *testing.T) {
+	stations, err := noaa.LoadNOAACSVStations(testutil.WriteFile(t, t.TempDir(), "harcon.csv", []byte(harconCsv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 1 {
+		t.Fatalf("expected 1 station, got %d", len(stations))
+	}
+	station := stations[0]
+	if station.ID != "9414290" || station.Name != "San Francisco" || station.Lat != 37.8063 || station.Lon != -122.4659 {
+		t.Errorf("unexpected station %+v", station)
+	}
+	if len(station.Constituents) != 2 {
+		t.Errorf("expected unknown constituents to be skipped, got %d constituents", len(station.Constituents))
+	}
+	// the greenwich phase is preferred over the local phase
+	assertDatum(t, station, constituents.C_M2, 1.903*30.48, 200.1)
+	assertDatum(t, station, constituents.C_S2, 0.44*30.48, 206.3)
+}
+
+func TestLoadCsvWithoutMetadata(t *testing.T) {
+	stations, err := noaa.LoadNOAACSVStations(testutil.WriteFile(t, t.TempDir(), "8443970_harcon.csv", []byte("Name,Amplitude,Phase\nM2,1.37,110\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stations[0].ID != "8443970" {
+		t.Errorf("expected station id from file name, got %s", stations[0].ID)
+	}
+	assertDatum(t, stations[0], constituents.C_M2, 137, 110)
+
+	_, err = noaa.LoadNOAACSVStations(testutil.WriteFile(t, t.TempDir(), "missing.csv", []byte("Name,Amplitude\nM2,1.37\n")))
+	if !errors.Is(err, noaa.ErrMissingColumn) {
+		t.Errorf("expected ErrMissingColumn, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package noaa
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+var ErrMissingColumn = errors.New("missing column in csv header")
+
+const (
+	COLUMN_NAME      = "name"
+	COLUMN_AMPLITUDE = "amplitude"
+	COLUMN_PHASE     = "phase"
+
+	METADATA_ID        = "station id"
+	METADATA_NAME      = "station name"
+	METADATA_LATITUDE  = "latitude"
+	METADATA_LONGITUDE = "longitude"
+	METADATA_UNITS     = "units"
+)
+
+// loads a csv export of the harmonic constituents of a station, e.g.
+//
+//	# Station ID: 9414290
+//	# Station Name: San Francisco
+//	# Latitude: 37.8063
+//	# Longitude: -122.4659
+//	Constituent #,Name,Amplitude (m),Phase (GMT),Speed,Description
+//	1,M2,0.58,200.1,28.984104,Principal lunar semidiurnal constituent
+//
+// columns are found by their header name, the amplitude unit is taken from the header (e.g. "Amplitude (ft)")
+// or the units metadata and defaults to meters. Without metadata the station id is taken from the file name.
+func LoadNOAACSVStations(filePath string) ([]stationdb.Station, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	station := stationdb.Station{ID: StationIdFromFileName(filePath)}
+	units := ""
+
+	// read metadata comments until the header
+	var header string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			header = line
+			break
+		}
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case METADATA_ID:
+			station.ID = value
+		case METADATA_NAME:
+			station.Name = value
+		case METADATA_LATITUDE:
+			station.Lat, err = parseFloat32(value)
+		case METADATA_LONGITUDE:
+			station.Lon, err = parseFloat32(value)
+		case METADATA_UNITS:
+			units = value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headerRecord, err := csv.NewReader(strings.NewReader(header)).Read()
+	if err != nil {
+		return nil, err
+	}
+	nameColumn, amplitudeColumn, phaseColumn, headerUnits, err := parseCSVHeader(headerRecord)
+	if err != nil {
+		return nil, err
+	}
+	if headerUnits != "" {
+		units = headerUnits
+	}
+	amplitudeFactor, err := AmplitudeFactorFromUnits(units)
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	csvReader.Comment = '#'
+	for {
+		record, err := csvReader.Read()
+		if err != nil && errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(record) <= nameColumn || len(record) <= amplitudeColumn || len(record) <= phaseColumn {
+			continue
+		}
+		constituent, err := constituents.FromString(strings.TrimSpace(record[nameColumn]))
+		if err != nil {
+			// constituents not known to the solvers are skipped
+			continue
+		}
+		amplitude, err := strconv.ParseFloat(strings.TrimSpace(record[amplitudeColumn]), 64)
+		if err != nil {
+			return nil, err
+		}
+		phase, err := strconv.ParseFloat(strings.TrimSpace(record[phaseColumn]), 64)
+		if err != nil {
+			return nil, err
+		}
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{
+			Constituent: constituent,
+			Amplitude:   amplitude * amplitudeFactor,
+			Phase:       phase,
+		})
+	}
+	if len(station.Constituents) == 0 {
+		return nil, ErrNoConstituents
+	}
+	return []stationdb.Station{station}, nil
+}
+
+// returns the column indices of name, amplitude and phase and the amplitude unit of the header,
+// a greenwich (GMT/UTC) phase column is preferred over a local phase column
+func parseCSVHeader(header []string) (int, int, int, string, error) {
+	nameColumn, amplitudeColumn, phaseColumn := -1, -1, -1
+	units := ""
+	greenwichPhase := false
+	for index, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch {
+		case column == COLUMN_NAME:
+			nameColumn = index
+		case strings.HasPrefix(column, COLUMN_AMPLITUDE):
+			amplitudeColumn = index
+			if start, end := strings.Index(column, "("), strings.Index(column, ")"); start >= 0 && end > start {
+				units = column[start+1 : end]
+			}
+		case strings.HasPrefix(column, COLUMN_PHASE):
+			isGreenwich := strings.Contains(column, "gmt") || strings.Contains(column, "utc") || strings.Contains(column, "greenwich")
+			if phaseColumn == -1 || (isGreenwich && !greenwichPhase) {
+				phaseColumn = index
+				greenwichPhase = isGreenwich
+			}
+		}
+	}
+	if nameColumn == -1 {
+		return 0, 0, 0, "", fmt.Errorf("%w: %s", ErrMissingColumn, COLUMN_NAME)
+	}
+	if amplitudeColumn == -1 {
+		return 0, 0, 0, "", fmt.Errorf("%w: %s", ErrMissingColumn, COLUMN_AMPLITUDE)
+	}
+	if phaseColumn == -1 {
+		return 0, 0, 0, "", fmt.Errorf("%w: %s", ErrMissingColumn, COLUMN_PHASE)
+	}
+	return nameColumn, amplitudeColumn, phaseColumn, units, nil
+}
+
+func parseFloat32(value string) (float32, error) {
+	parsed, err := strconv.ParseFloat(value, 32)
+	return float32(parsed), err
+}
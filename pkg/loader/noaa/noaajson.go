@@ -0,0 +1,151 @@
+package noaa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+var ErrNoConstituents = errors.New("no harmonic constituents found")
+
+const (
+	UNITS_METRIC  = "metric"
+	UNITS_ENGLISH = "english"
+)
+
+// harmonic constituents of a station as returned by the CO-OPS metadata api
+// (e.g. .../mdapi/prod/webapi/stations/9414290/harcon.json?units=metric)
+type harcon struct {
+	Units                string              `json:"units"`
+	HarmonicConstituents []harconConstituent `json:"HarmonicConstituents"`
+	Self                 string              `json:"self"`
+}
+
+type harconConstituent struct {
+	Name      string  `json:"name"`
+	Amplitude float64 `json:"amplitude"`
+	PhaseGMT  float64 `json:"phase_GMT"`
+}
+
+// station list of the CO-OPS metadata api with expanded harmonic constituents
+// (e.g. .../mdapi/prod/webapi/stations/9414290.json?expand=harcon)
+type stationList struct {
+	Stations []struct {
+		ID                   string  `json:"id"`
+		Name                 string  `json:"name"`
+		Lat                  float32 `json:"lat"`
+		Lng                  float32 `json:"lng"`
+		HarmonicConstituents *harcon `json:"harmonicConstituents"`
+	} `json:"stations"`
+}
+
+// loads the stations of a CO-OPS harcon.json file or of a station list with expanded harmonic constituents,
+// a harcon.json file has no position, the station id is taken from the self link or the file name
+func LoadNOAAJSONStations(filePath string) ([]stationdb.Station, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	list := stationList{}
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, err
+	}
+	if len(list.Stations) > 0 {
+		stations := make([]stationdb.Station, 0, len(list.Stations))
+		for _, entry := range list.Stations {
+			if entry.HarmonicConstituents == nil {
+				return nil, fmt.Errorf("%w for station %s", ErrNoConstituents, entry.ID)
+			}
+			station, err := parseHarcon(*entry.HarmonicConstituents)
+			if err != nil {
+				return nil, err
+			}
+			station.ID = entry.ID
+			station.Name = entry.Name
+			station.Lat = entry.Lat
+			station.Lon = entry.Lng
+			stations = append(stations, station)
+		}
+		return stations, nil
+	}
+
+	stationHarcon := harcon{}
+	if err := json.Unmarshal(content, &stationHarcon); err != nil {
+		return nil, err
+	}
+	station, err := parseHarcon(stationHarcon)
+	if err != nil {
+		return nil, err
+	}
+	station.ID = stationIdFromSelf(stationHarcon.Self)
+	if station.ID == "" {
+		station.ID = StationIdFromFileName(filePath)
+	}
+	return []stationdb.Station{station}, nil
+}
+
+func parseHarcon(stationHarcon harcon) (stationdb.Station, error) {
+	station := stationdb.Station{}
+	if len(stationHarcon.HarmonicConstituents) == 0 {
+		return station, ErrNoConstituents
+	}
+	amplitudeFactor, err := AmplitudeFactorFromUnits(stationHarcon.Units)
+	if err != nil {
+		return station, err
+	}
+	for _, entry := range stationHarcon.HarmonicConstituents {
+		constituent, err := constituents.FromString(entry.Name)
+		if err != nil {
+			// constituents not known to the solvers are skipped
+			continue
+		}
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{
+			Constituent: constituent,
+			Amplitude:   entry.Amplitude * amplitudeFactor,
+			Phase:       entry.PhaseGMT,
+		})
+	}
+	return station, nil
+}
+
+// returns the factor to convert amplitudes in the units of the CO-OPS api (metric, english)
+// or a plain length unit to cm, no units means meters
+func AmplitudeFactorFromUnits(units string) (float64, error) {
+	switch strings.ToLower(strings.TrimSpace(units)) {
+	case "", UNITS_METRIC:
+		return 100, nil
+	case UNITS_ENGLISH:
+		return 30.48, nil
+	}
+	factor, err := constituentdata.AmplitudeFactorToCm(units)
+	return float64(factor), err
+}
+
+// extracts the station id from a link like .../stations/9414290/harcon.json?units=metric
+func stationIdFromSelf(self string) string {
+	parts := strings.Split(strings.SplitN(self, "?", 2)[0], "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "stations" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// returns the file name up to the first dot or underscore, e.g. 9414290 for 9414290_harcon.csv
+func StationIdFromFileName(filePath string) string {
+	name := filepath.Base(filePath)
+	fields := strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '_' })
+	if len(fields) == 0 {
+		return name
+	}
+	return fields[0]
+}
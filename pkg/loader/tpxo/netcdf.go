@@ -0,0 +1,319 @@
+//go:build cgo && !nonetcdf
+
+package tpxo
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// reader for the NetCDF elevation files of the TPXO atlas (hRe/hIm over nx, ny) and the
+// older multi constituent files (hRe/hIm over nc, nx, ny)
+type netcdfReader struct {
+	file *constituentdata.NetcdfFile
+
+	realVariable netcdf.Var
+	imagVariable netcdf.Var
+	dimensions   []string
+	dimensionLen []uint64
+	// factor to convert the elevation to cm
+	unitFactor float32
+
+	sizeX uint64
+	sizeY uint64
+	// latitudes (south to north) and longitudes of the grid axes, for curvilinear grids
+	// the coordinates of every grid point (index y*sizeX+x)
+	latitudes   []float32
+	longitudes  []float32
+	curvilinear bool
+	// rows are flipped if the latitudes are stored from north to south
+	flipRows bool
+
+	constituentNames []string
+	current          int
+}
+
+func openNetcdfReader(filePath string) (*netcdfReader, error) {
+	file, err := constituentdata.OpenNetcdfFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	reader := &netcdfReader{file: file}
+	if err := reader.readHeader(); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (n *netcdfReader) Close() error {
+	return n.file.Close()
+}
+
+func (n *netcdfReader) readHeader() error {
+	var err error
+	n.realVariable, err = n.file.Var(VAR_REAL)
+	if err != nil {
+		return err
+	}
+	n.imagVariable, err = n.file.Var(VAR_IMAG)
+	if err != nil {
+		return err
+	}
+	n.dimensions, err = utils.NetcdfGetDimensionNames(&n.realVariable)
+	if err != nil {
+		return err
+	}
+	n.dimensionLen, err = n.realVariable.LenDims()
+	if err != nil {
+		return err
+	}
+	if n.dimensionIndex(DIM_X) < 0 || n.dimensionIndex(DIM_Y) < 0 {
+		return fmt.Errorf("%s must have the dimensions %s and %s", VAR_REAL, DIM_X, DIM_Y)
+	}
+
+	units, err := utils.NetcdfGetStringFromAttribute(ATTR_UNITS, &n.realVariable)
+	if err != nil && err != utils.ErrNetcdfAttributeNotFound {
+		return err
+	}
+	// the elevation scale differs between the TPXO releases, missing units are meter like in the OTIS files
+	if units == "" {
+		units = "m"
+	}
+	n.unitFactor, err = constituentdata.AmplitudeFactorToCm(units)
+	if err != nil {
+		return err
+	}
+
+	if err := n.readCoordinates(); err != nil {
+		return err
+	}
+	return n.readConstituentNames()
+}
+
+func (n *netcdfReader) readCoordinates() error {
+	n.sizeX = n.dimensionLen[n.dimensionIndex(DIM_X)]
+	n.sizeY = n.dimensionLen[n.dimensionIndex(DIM_Y)]
+	if n.sizeX < 2 || n.sizeY < 1 {
+		return fmt.Errorf("grid too small")
+	}
+	longitudes, err := n.readGridCoordinates(VAR_LONGITUDE)
+	if err != nil {
+		return err
+	}
+	latitudes, err := n.readGridCoordinates(VAR_LATITUDE)
+	if err != nil {
+		return err
+	}
+
+	// the coordinates of curvilinear grids vary along both dimensions
+	for y := uint64(0); y < n.sizeY && !n.curvilinear; y++ {
+		for x := uint64(0); x < n.sizeX; x++ {
+			if !sameCoordinate(latitudes[y*n.sizeX+x], latitudes[y*n.sizeX]) || !sameCoordinate(longitudes[y*n.sizeX+x], longitudes[x]) {
+				n.curvilinear = true
+				break
+			}
+		}
+	}
+	if n.curvilinear {
+		n.latitudes, n.longitudes = latitudes, longitudes
+		return nil
+	}
+
+	n.longitudes = longitudes[:n.sizeX]
+	if n.longitudes[n.sizeX-1] <= n.longitudes[0] {
+		return fmt.Errorf("longitudes must be increasing")
+	}
+	n.latitudes = make([]float32, n.sizeY)
+	n.flipRows = latitudes[(n.sizeY-1)*n.sizeX] < latitudes[0]
+	for y := uint64(0); y < n.sizeY; y++ {
+		sourceY := y
+		if n.flipRows {
+			sourceY = n.sizeY - 1 - y
+		}
+		n.latitudes[y] = latitudes[sourceY*n.sizeX]
+	}
+	return nil
+}
+
+func sameCoordinate(a float32, b float32) bool {
+	return math.Abs(float64(a-b)) <= 1e-5
+}
+
+// returns the minimum and maximum of the values without NaN
+func extent(values []float32) (float32, float32) {
+	minimum, maximum := float32(math.Inf(1)), float32(math.Inf(-1))
+	for _, value := range values {
+		if value < minimum {
+			minimum = value
+		}
+		if value > maximum {
+			maximum = value
+		}
+	}
+	return minimum, maximum
+}
+
+// reads the coordinates of every grid point from a 1d or 2d (nx, ny) variable, the values
+// are ordered row by row (index y*sizeX+x)
+func (n *netcdfReader) readGridCoordinates(name string) ([]float32, error) {
+	variable, err := n.file.Var(name)
+	if err != nil {
+		return nil, err
+	}
+	dimensions, err := utils.NetcdfGetDimensionNames(&variable)
+	if err != nil {
+		return nil, err
+	}
+	lenDims, err := variable.LenDims()
+	if err != nil {
+		return nil, err
+	}
+	values, err := utils.NetcdfReadFloat32s(&variable)
+	if err != nil {
+		return nil, err
+	}
+
+	// other dimensions than nx and ny are read at index 0
+	strideX, strideY := uint64(0), uint64(0)
+	stride := uint64(1)
+	for i := len(dimensions) - 1; i >= 0; i-- {
+		switch dimensions[i] {
+		case DIM_X:
+			strideX = stride
+		case DIM_Y:
+			strideY = stride
+		}
+		if (dimensions[i] == DIM_X && lenDims[i] != n.sizeX) || (dimensions[i] == DIM_Y && lenDims[i] != n.sizeY) {
+			return nil, fmt.Errorf("size of %s differs from the grid", name)
+		}
+		stride = stride * lenDims[i]
+	}
+	if strideX == 0 && strideY == 0 {
+		return nil, fmt.Errorf("%s has neither the dimension %s nor %s", name, DIM_X, DIM_Y)
+	}
+	gridValues := make([]float32, n.sizeX*n.sizeY)
+	for y := uint64(0); y < n.sizeY; y++ {
+		for x := uint64(0); x < n.sizeX; x++ {
+			gridValues[y*n.sizeX+x] = values[x*strideX+y*strideY]
+		}
+	}
+	return gridValues, nil
+}
+
+func (n *netcdfReader) readConstituentNames() error {
+	numberConstituents := uint64(1)
+	if index := n.dimensionIndex(DIM_CONSTITUENT); index >= 0 {
+		numberConstituents = n.dimensionLen[index]
+	}
+
+	variable, err := n.file.Var(VAR_CONSTITUENT)
+	if err != nil {
+		return err
+	}
+	length, err := variable.Len()
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, length)
+	if err := variable.ReadBytes(buffer); err != nil {
+		return err
+	}
+	nameLength := length / numberConstituents
+	for i := uint64(0); i < numberConstituents; i++ {
+		n.constituentNames = append(n.constituentNames, strings.Trim(string(buffer[i*nameLength:(i+1)*nameLength]), "\x00 "))
+	}
+	return nil
+}
+
+func (n *netcdfReader) dimensionIndex(name string) int {
+	for i, dimension := range n.dimensions {
+		if dimension == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *netcdfReader) nextGrid() (*complexGrid, error) {
+	if n.current >= len(n.constituentNames) {
+		return nil, io.EOF
+	}
+	name := n.constituentNames[n.current]
+	constituentIndex := uint64(n.current)
+	n.current = n.current + 1
+
+	constituent, err := constituents.FromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown constituent %s", name)
+	}
+
+	// read the slice of the constituent, the grid keeps the order of the dimensions
+	start := make([]uint64, len(n.dimensions))
+	count := make([]uint64, len(n.dimensions))
+	copy(count, n.dimensionLen)
+	if index := n.dimensionIndex(DIM_CONSTITUENT); index >= 0 {
+		start[index] = constituentIndex
+		count[index] = 1
+	}
+	realValues, err := utils.NetcdfReadFloat32Slice(&n.realVariable, start, count)
+	if err != nil {
+		return nil, err
+	}
+	imagValues, err := utils.NetcdfReadFloat32Slice(&n.imagVariable, start, count)
+	if err != nil {
+		return nil, err
+	}
+
+	strides := make([]uint64, len(count))
+	stride := uint64(1)
+	for i := len(count) - 1; i >= 0; i-- {
+		strides[i] = stride
+		stride = stride * count[i]
+	}
+	strideX := strides[n.dimensionIndex(DIM_X)]
+	strideY := strides[n.dimensionIndex(DIM_Y)]
+
+	sizeX := int(n.sizeX)
+	sizeY := int(n.sizeY)
+	// all grids share the coordinates
+	grid := &complexGrid{
+		constituent:  constituent,
+		sizeX:        sizeX,
+		sizeY:        sizeY,
+		latitudeMin:  n.latitudes[0],
+		latitudeMax:  n.latitudes[len(n.latitudes)-1],
+		longitudeMin: n.longitudes[0],
+		longitudeMax: n.longitudes[len(n.longitudes)-1],
+		latitudes:    n.latitudes,
+		longitudes:   n.longitudes,
+		curvilinear:  n.curvilinear,
+		real:         make([][]float32, sizeY),
+		imag:         make([][]float32, sizeY),
+	}
+	if n.curvilinear {
+		grid.latitudeMin, grid.latitudeMax = extent(n.latitudes)
+		grid.longitudeMin, grid.longitudeMax = extent(n.longitudes)
+	}
+	for y := 0; y < sizeY; y++ {
+		sourceY := uint64(y)
+		if n.flipRows {
+			sourceY = uint64(sizeY - 1 - y)
+		}
+		grid.real[y] = make([]float32, sizeX)
+		grid.imag[y] = make([]float32, sizeX)
+		for x := 0; x < sizeX; x++ {
+			index := uint64(x)*strideX + sourceY*strideY
+			grid.real[y][x] = realValues[index] * n.unitFactor
+			grid.imag[y][x] = imagValues[index] * n.unitFactor
+		}
+	}
+	return grid, nil
+}
@@ -0,0 +1,9 @@
+//go:build !cgo || nonetcdf
+
+package tpxo
+
+import "github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+
+func openNetcdfReader(filePath string) (complexGridReader, error) {
+	return nil, constituentdata.ErrNetcdfNotSupported
+}
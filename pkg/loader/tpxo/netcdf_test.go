@@ -0,0 +1,132 @@
+//go:build cgo && !nonetcdf
+
+package tpxo_test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/tpxo"
+)
+
+func TestNetcdfAtlas(t *testing.T) {
+	const sizeX, sizeY = 5, 4
+	filePath := filepath.Join(t.TempDir(), "h_m2_tpxo9_atlas.nc")
+	file, err := netcdf.CreateFile(filePath, netcdf.CLOBBER|netcdf.NETCDF4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dimX, _ := file.AddDim("nx", sizeX)
+	dimY, _ := file.AddDim("ny", sizeY)
+	dimName, _ := file.AddDim("nct", 4)
+	con, _ := file.AddVar("con", netcdf.CHAR, []netcdf.Dim{dimName})
+	lon, _ := file.AddVar("lon_z", netcdf.DOUBLE, []netcdf.Dim{dimX})
+	lat, _ := file.AddVar("lat_z", netcdf.DOUBLE, []netcdf.Dim{dimY})
+	hRe, _ := file.AddVar("hRe", netcdf.INT, []netcdf.Dim{dimX, dimY})
+	hIm, _ := file.AddVar("hIm", netcdf.INT, []netcdf.Dim{dimX, dimY})
+	if err := hRe.Attr("units").WriteBytes([]byte("millimeter")); err != nil {
+		t.Fatal(err)
+	}
+	file.EndDef()
+
+	if err := con.WriteBytes([]byte("m2  ")); err != nil {
+		t.Fatal(err)
+	}
+	lon.WriteFloat64s([]float64{350, 351, 352, 353, 354})
+	// stored from north to south
+	lat.WriteFloat64s([]float64{-30, -31, -32, -33})
+	reValues := make([]int32, sizeX*sizeY)
+	imValues := make([]int32, sizeX*sizeY)
+	for x := 0; x < sizeX; x++ {
+		for ySource := 0; ySource < sizeY; ySource++ {
+			re, im := testElevation(x, sizeY-1-ySource, sizeX, sizeY)
+			reValues[x*sizeY+ySource] = int32(math.Round(float64(re) * 1000))
+			imValues[x*sizeY+ySource] = int32(math.Round(float64(im) * 1000))
+		}
+	}
+	if err := hRe.WriteInt32s(reValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := hIm.WriteInt32s(imValues); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	loader, err := tpxo.CreateTPXOLoader(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	assertConstituentData(t, loader, []constituents.Constituent{constituents.C_M2}, sizeX, sizeY, -33, -30, 350, 354)
+}
+
+func TestNetcdfCurvilinear(t *testing.T) {
+	const sizeX, sizeY = 4, 4
+	filePath := filepath.Join(t.TempDir(), "h_m2_rotated.nc")
+	file, err := netcdf.CreateFile(filePath, netcdf.CLOBBER|netcdf.NETCDF4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dimX, _ := file.AddDim("nx", sizeX)
+	dimY, _ := file.AddDim("ny", sizeY)
+	dimName, _ := file.AddDim("nct", 4)
+	con, _ := file.AddVar("con", netcdf.CHAR, []netcdf.Dim{dimName})
+	lon, _ := file.AddVar("lon_z", netcdf.DOUBLE, []netcdf.Dim{dimX, dimY})
+	lat, _ := file.AddVar("lat_z", netcdf.DOUBLE, []netcdf.Dim{dimX, dimY})
+	hRe, _ := file.AddVar("hRe", netcdf.FLOAT, []netcdf.Dim{dimX, dimY})
+	hIm, _ := file.AddVar("hIm", netcdf.FLOAT, []netcdf.Dim{dimX, dimY})
+	file.EndDef()
+
+	if err := con.WriteBytes([]byte("m2  ")); err != nil {
+		t.Fatal(err)
+	}
+	// a rotated grid, the coordinates vary along both dimensions
+	lonValues := make([]float64, sizeX*sizeY)
+	latValues := make([]float64, sizeX*sizeY)
+	reValues := make([]float32, sizeX*sizeY)
+	imValues := make([]float32, sizeX*sizeY)
+	for x := 0; x < sizeX; x++ {
+		for y := 0; y < sizeY; y++ {
+			lonValues[x*sizeY+y] = 10 + float64(x) - 0.5*float64(y)
+			latValues[x*sizeY+y] = 50 + float64(y) + 0.5*float64(x)
+			re, im := testElevation(x, y, sizeX, sizeY)
+			reValues[x*sizeY+y] = re
+			imValues[x*sizeY+y] = im
+		}
+	}
+	lon.WriteFloat64s(lonValues)
+	lat.WriteFloat64s(latValues)
+	if err := hRe.WriteFloat32s(reValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := hIm.WriteFloat32s(imValues); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	loader, err := tpxo.CreateTPXOLoader(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	assertConstituentData(t, loader, []constituents.Constituent{constituents.C_M2}, sizeX, sizeY, 50, 54.5, 8.5, 13)
+
+	loader, err = tpxo.CreateTPXOLoader(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	amplitude, err := loader.GetNextConstituentData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !amplitude.Curvilinear || len(amplitude.Latitudes) != sizeX*sizeY || len(amplitude.Longitudes) != sizeX*sizeY {
+		t.Fatalf("expected the coordinates of a curvilinear grid, got %d latitudes", len(amplitude.Latitudes))
+	}
+	if amplitude.Latitudes[2*sizeX+3] != 53.5 || amplitude.Longitudes[2*sizeX+3] != 12 {
+		t.Errorf("unexpected coordinates at 3,2: %f,%f", amplitude.Latitudes[2*sizeX+3], amplitude.Longitudes[2*sizeX+3])
+	}
+}
@@ -0,0 +1,150 @@
+package tpxo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+// reader for the OTIS binary elevation files, big endian fortran unformatted records.
+// the header record holds nx, ny, nc, the latitude and longitude limits of the grid cells and
+// the constituent names, followed by one record of nx*ny complex values (meter) per constituent
+type otisReader struct {
+	file   io.Closer
+	reader *bufio.Reader
+
+	sizeX        int
+	sizeY        int
+	latitudeMin  float32
+	latitudeMax  float32
+	longitudeMin float32
+	longitudeMax float32
+
+	constituentNames []string
+	current          int
+}
+
+func openOtisReader(filePath string) (*otisReader, error) {
+	file, err := constituentdata.OpenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	otis := &otisReader{
+		file:   file,
+		reader: bufio.NewReader(file),
+	}
+	if err := otis.readHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return otis, nil
+}
+
+func (o *otisReader) Close() error {
+	return o.file.Close()
+}
+
+func (o *otisReader) readHeader() error {
+	record, err := o.readRecord()
+	if err != nil {
+		return err
+	}
+	if len(record) < 28 {
+		return fmt.Errorf("invalid OTIS header")
+	}
+	nx := int(int32(binary.BigEndian.Uint32(record[0:4])))
+	ny := int(int32(binary.BigEndian.Uint32(record[4:8])))
+	nc := int(int32(binary.BigEndian.Uint32(record[8:12])))
+	if nx <= 0 || ny <= 0 || nc <= 0 || len(record) < 28+nc*4 {
+		return fmt.Errorf("invalid OTIS header")
+	}
+	limits := make([]float32, 4)
+	for i := range limits {
+		limits[i] = math.Float32frombits(binary.BigEndian.Uint32(record[12+i*4 : 16+i*4]))
+	}
+
+	// the limits are the edges of the grid, the values are located at the cell centers
+	resolutionLat := (limits[1] - limits[0]) / float32(ny)
+	resolutionLon := (limits[3] - limits[2]) / float32(nx)
+	o.sizeX = nx
+	o.sizeY = ny
+	o.latitudeMin = limits[0] + resolutionLat/2
+	o.latitudeMax = limits[1] - resolutionLat/2
+	o.longitudeMin = limits[2] + resolutionLon/2
+	o.longitudeMax = limits[3] - resolutionLon/2
+
+	for i := 0; i < nc; i++ {
+		o.constituentNames = append(o.constituentNames, strings.TrimSpace(string(record[28+i*4:32+i*4])))
+	}
+	return nil
+}
+
+func (o *otisReader) nextGrid() (*complexGrid, error) {
+	if o.current >= len(o.constituentNames) {
+		return nil, io.EOF
+	}
+	name := o.constituentNames[o.current]
+	o.current = o.current + 1
+
+	constituent, err := constituents.FromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown constituent %s", name)
+	}
+
+	record, err := o.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	if len(record) != o.sizeX*o.sizeY*8 {
+		return nil, fmt.Errorf("invalid record size for constituent %s", name)
+	}
+
+	grid := &complexGrid{
+		constituent:  constituent,
+		sizeX:        o.sizeX,
+		sizeY:        o.sizeY,
+		latitudeMin:  o.latitudeMin,
+		latitudeMax:  o.latitudeMax,
+		longitudeMin: o.longitudeMin,
+		longitudeMax: o.longitudeMax,
+		real:         make([][]float32, o.sizeY),
+		imag:         make([][]float32, o.sizeY),
+	}
+	// values are stored column major, x varies fastest
+	for y := 0; y < o.sizeY; y++ {
+		grid.real[y] = make([]float32, o.sizeX)
+		grid.imag[y] = make([]float32, o.sizeX)
+		for x := 0; x < o.sizeX; x++ {
+			offset := (y*o.sizeX + x) * 8
+			grid.real[y][x] = math.Float32frombits(binary.BigEndian.Uint32(record[offset:offset+4])) * 100
+			grid.imag[y][x] = math.Float32frombits(binary.BigEndian.Uint32(record[offset+4:offset+8])) * 100
+		}
+	}
+	return grid, nil
+}
+
+// reads a fortran unformatted record enclosed by its length in bytes
+func (o *otisReader) readRecord() ([]byte, error) {
+	var length uint32
+	if err := binary.Read(o.reader, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	record := make([]byte, length)
+	if _, err := io.ReadFull(o.reader, record); err != nil {
+		return nil, err
+	}
+	var trailingLength uint32
+	if err := binary.Read(o.reader, binary.BigEndian, &trailingLength); err != nil {
+		return nil, err
+	}
+	if trailingLength != length {
+		return nil, fmt.Errorf("corrupt fortran record")
+	}
+	return record, nil
+}
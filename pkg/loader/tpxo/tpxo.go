@@ -0,0 +1,184 @@
+package tpxo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+)
+
+// value of grid points without ocean data, TPXO stores land as zero elevation
+const UNDEF_VALUE float32 = 999
+
+// variables and dimensions of the TPXO NetCDF files
+const (
+	DIM_CONSTITUENT = "nc"
+	DIM_X           = "nx"
+	DIM_Y           = "ny"
+	VAR_CONSTITUENT = "con"
+	VAR_LONGITUDE   = "lon_z"
+	VAR_LATITUDE    = "lat_z"
+	VAR_REAL        = "hRe"
+	VAR_IMAG        = "hIm"
+	ATTR_UNITS      = "units"
+)
+
+// upper bound of the constituents in the header of an OTIS file, used by the format detection
+const MAX_OTIS_CONSTITUENTS = 1024
+
+// complex elevation grid of a single constituent, Real[0][0] is the south-west corner
+type complexGrid struct {
+	constituent  constituents.Constituent
+	sizeX        int
+	sizeY        int
+	latitudeMin  float32
+	latitudeMax  float32
+	longitudeMin float32
+	longitudeMax float32
+	// coordinates of the grid points, see constituentdata.TideConstituentData
+	latitudes   []float32
+	longitudes  []float32
+	curvilinear bool
+	// real and imaginary part of the elevation in cm
+	real [][]float32
+	imag [][]float32
+}
+
+type complexGridReader interface {
+	io.Closer
+	// returns the next constituent grid or io.EOF
+	nextGrid() (*complexGrid, error)
+}
+
+type tpxoFile struct {
+	constituentdata.ConstituentDataLoader
+	reader       complexGridReader
+	pendingPhase *constituentdata.TideConstituentData
+}
+
+// creates a loader for TPXO elevation files, either the OTIS binary format (h_*.out)
+// or the NetCDF format of the TPXO atlas (h_*.nc), the format is detected by the file signature.
+// compressed OTIS files are decompressed while reading, compressed NetCDF files into memory
+func CreateTPXOLoader(filePath string) (constituentdata.ConstituentDataLoader, error) {
+	isNetcdf, err := isNetcdfFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var reader complexGridReader
+	if isNetcdf {
+		reader, err = openNetcdfReader(filePath)
+	} else {
+		reader, err = openOtisReader(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tpxoFile{reader: reader}, nil
+}
+
+func (t *tpxoFile) Close() error {
+	return t.reader.Close()
+}
+
+// returns the amplitude (cm) and phase (degree) of each constituent, amplitude first
+func (t *tpxoFile) GetNextConstituentData() (*constituentdata.TideConstituentData, error) {
+	if t.pendingPhase != nil {
+		phase := t.pendingPhase
+		t.pendingPhase = nil
+		return phase, nil
+	}
+
+	grid, err := t.reader.nextGrid()
+	if err != nil {
+		return nil, err
+	}
+	amplitude, phase := toAmplitudePhase(grid)
+	t.pendingPhase = phase
+	return amplitude, nil
+}
+
+// converts the complex elevation to amplitude and greenwich phase lag,
+// phase = atan2(-imag, real) as in the OSU tidal prediction software
+func toAmplitudePhase(grid *complexGrid) (*constituentdata.TideConstituentData, *constituentdata.TideConstituentData) {
+	newData := func(valueType constituentdata.ConstituentValueType) *constituentdata.TideConstituentData {
+		return &constituentdata.TideConstituentData{
+			Constituent:  grid.constituent,
+			Type:         valueType,
+			SizeX:        grid.sizeX,
+			SizeY:        grid.sizeY,
+			LatitudeMin:  grid.latitudeMin,
+			LatitudeMax:  grid.latitudeMax,
+			LongitudeMin: grid.longitudeMin,
+			LongitudeMax: grid.longitudeMax,
+			UndefValue:   UNDEF_VALUE,
+			Data:         make([][]float32, grid.sizeY),
+			Latitudes:    grid.latitudes,
+			Longitudes:   grid.longitudes,
+			Curvilinear:  grid.curvilinear,
+		}
+	}
+	amplitude := newData(constituentdata.AMPLITUDE)
+	phase := newData(constituentdata.PHASE)
+
+	for y := 0; y < grid.sizeY; y++ {
+		amplitude.Data[y] = make([]float32, grid.sizeX)
+		phase.Data[y] = make([]float32, grid.sizeX)
+		for x := 0; x < grid.sizeX; x++ {
+			re := float64(grid.real[y][x])
+			im := float64(grid.imag[y][x])
+			if (re == 0 && im == 0) || math.IsNaN(re) || math.IsNaN(im) {
+				amplitude.Data[y][x] = UNDEF_VALUE
+				phase.Data[y][x] = UNDEF_VALUE
+				continue
+			}
+			phaseLag := math.Atan2(-im, re) * (180 / math.Pi)
+			if phaseLag < 0 {
+				phaseLag = phaseLag + 360
+			}
+			amplitude.Data[y][x] = float32(math.Hypot(re, im))
+			phase.Data[y][x] = float32(phaseLag)
+		}
+	}
+	return amplitude, phase
+}
+
+func isNetcdfFile(filePath string) (bool, error) {
+	file, err := constituentdata.OpenFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	signature := make([]byte, 4)
+	n, err := io.ReadFull(file, signature)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return constituentdata.HasNetcdfSignature(bytes.NewReader(signature[:n])), nil
+}
+
+// detects TPXO elevation files, NetCDF files with the complex elevation variables or OTIS binary files with a valid header
+func Detect(reader io.ReadSeeker) bool {
+	if constituentdata.HasNetcdfSignature(reader) {
+		return constituentdata.HeaderContains(reader, VAR_REAL, VAR_IMAG)
+	}
+	// check the length of the header record before reading it, the first bytes of other files
+	// would lead to huge records
+	recordLength, err := constituentdata.ReadHeaderBytes(reader, 4)
+	if err != nil || len(recordLength) < 4 {
+		return false
+	}
+	length := binary.BigEndian.Uint32(recordLength)
+	if length < 28 || length > 28+4*MAX_OTIS_CONSTITUENTS {
+		return false
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	otis := &otisReader{reader: bufio.NewReader(io.LimitReader(reader, constituentdata.DETECT_HEADER_SIZE))}
+	return otis.readHeader() == nil
+}
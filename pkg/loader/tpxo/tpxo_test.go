@@ -0,0 +1,127 @@
+package tpxo_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/loader/constituentdata"
+	"github.com/mzeiher/perth3-go/pkg/loader/tpxo"
+)
+
+// complex elevation in meter with amplitude x+y+1 cm and phase lag 90*x degree,
+// the north-east corner is land
+func testElevation(x int, y int, sizeX int, sizeY int) (float32, float32) {
+	if x == sizeX-1 && y == sizeY-1 {
+		return 0, 0
+	}
+	amplitude := float64(x+y+1) / 100
+	phase := float64(90*x) * (math.Pi / 180)
+	return float32(amplitude * math.Cos(phase)), float32(-amplitude * math.Sin(phase))
+}
+
+func writeRecord(t *testing.T, file *os.File, data []byte) {
+	length := uint32(len(data))
+	if err := binary.Write(file, binary.BigEndian, length); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(file, binary.BigEndian, length); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendBigEndian(data []byte, values ...interface{}) []byte {
+	for _, value := range values {
+		switch v := value.(type) {
+		case int32:
+			data = binary.BigEndian.AppendUint32(data, uint32(v))
+		case float32:
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(v))
+		}
+	}
+	return data
+}
+
+func assertConstituentData(t *testing.T, loader constituentdata.ConstituentDataLoader, expected []constituents.Constituent, sizeX int, sizeY int, latMin float32, latMax float32, lonMin float32, lonMax float32) {
+	for _, constituent := range expected {
+		amplitude, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		phase, err := loader.GetNextConstituentData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if amplitude.Constituent != constituent || phase.Constituent != constituent {
+			t.Fatalf("expected %s, got %s and %s", constituent, amplitude.Constituent, phase.Constituent)
+		}
+		if amplitude.Type != constituentdata.AMPLITUDE || phase.Type != constituentdata.PHASE {
+			t.Fatalf("expected amplitude and phase, got %s and %s", amplitude.Type, phase.Type)
+		}
+		if amplitude.SizeX != sizeX || amplitude.SizeY != sizeY {
+			t.Fatalf("expected grid %dx%d, got %dx%d", sizeX, sizeY, amplitude.SizeX, amplitude.SizeY)
+		}
+		if math.Abs(float64(amplitude.LatitudeMin-latMin)) > 1e-4 || math.Abs(float64(amplitude.LatitudeMax-latMax)) > 1e-4 ||
+			math.Abs(float64(amplitude.LongitudeMin-lonMin)) > 1e-4 || math.Abs(float64(amplitude.LongitudeMax-lonMax)) > 1e-4 {
+			t.Fatalf("unexpected grid limits lat %f-%f lon %f-%f", amplitude.LatitudeMin, amplitude.LatitudeMax, amplitude.LongitudeMin, amplitude.LongitudeMax)
+		}
+		for y := 0; y < sizeY; y++ {
+			for x := 0; x < sizeX; x++ {
+				if x == sizeX-1 && y == sizeY-1 {
+					if amplitude.Data[y][x] != tpxo.UNDEF_VALUE || phase.Data[y][x] != tpxo.UNDEF_VALUE {
+						t.Errorf("expected undefined land value at %d,%d", x, y)
+					}
+					continue
+				}
+				if math.Abs(float64(amplitude.Data[y][x])-float64(x+y+1)) > 1e-3 {
+					t.Errorf("%d,%d: expected amplitude %d cm, got %f", x, y, x+y+1, amplitude.Data[y][x])
+				}
+				if math.Abs(float64(phase.Data[y][x])-float64(90*x%360)) > 1e-3 {
+					t.Errorf("%d,%d: expected phase %d, got %f", x, y, 90*x%360, phase.Data[y][x])
+				}
+			}
+		}
+	}
+	if _, err := loader.GetNextConstituentData(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestOtisBinary(t *testing.T) {
+	const sizeX, sizeY = 4, 3
+	filePath := filepath.Join(t.TempDir(), "h_test.out")
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := appendBigEndian(nil, int32(sizeX), int32(sizeY), int32(2), float32(50), float32(53), float32(0), float32(2))
+	header = append(header, []byte("m2  k1  ")...)
+	writeRecord(t, file, header)
+	for c := 0; c < 2; c++ {
+		record := []byte{}
+		for y := 0; y < sizeY; y++ {
+			for x := 0; x < sizeX; x++ {
+				re, im := testElevation(x, y, sizeX, sizeY)
+				record = appendBigEndian(record, re, im)
+			}
+		}
+		writeRecord(t, file, record)
+	}
+	file.Close()
+
+	loader, err := tpxo.CreateTPXOLoader(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	// the header holds the cell edges, the values are at the cell centers
+	assertConstituentData(t, loader, []constituents.Constituent{constituents.C_M2, constituents.C_K1}, sizeX, sizeY, 50.5, 52.5, 0.25, 1.75)
+}
@@ -1,47 +1,117 @@
 package lpeqomt
 
 import (
+	"errors"
 	"math"
 	"time"
 
 	"github.com/mzeiher/perth3-go/pkg/astro"
 )
 
+var ErrTermSetNotFound = errors.New("term set not found")
+
+// spectral line of the long period tide potential from the Cartwright-Tayler-Edden tables,
+// the argument is S*s + H*h + P*p + NPrime*N' + P1*p1 with N' = -N
+type LongPeriodTerm struct {
+	Name   string
+	S      int
+	H      int
+	P      int
+	NPrime int
+	P1     int
+	// amplitude of the potential in cm, sign as in the CTE tables
+	Amplitude float64
+}
+
+type TermSet string
+
+const (
+	// the 15 terms > 1mm used by perth3.f, amplitudes rounded to 0.01cm
+	TERMS_PERTH3 TermSet = "perth3"
+	// all long period terms > 0.1mm of the CTE tables with full precision
+	TERMS_CTE TermSet = "cte"
+)
+
+var availableTermSets = map[TermSet][]LongPeriodTerm{
+	TERMS_PERTH3: {
+		{Name: "Node", NPrime: 1, Amplitude: 2.79},
+		{Name: "Sa", H: 1, P1: -1, Amplitude: -0.49},
+		{Name: "Ssa", H: 2, Amplitude: -3.10},
+		{Name: "MSm", S: 1, H: -2, P: 1, Amplitude: -0.67},
+		{Name: "Mm", S: 1, P: -1, Amplitude: -3.52},
+		{Name: "Mm", S: 1, P: -1, NPrime: 1, Amplitude: 0.23},
+		{Name: "Mm", S: 1, P: -1, NPrime: -1, Amplitude: 0.23},
+		{Name: "Mf", S: 2, Amplitude: -6.66},
+		{Name: "Mf", S: 2, NPrime: 1, Amplitude: -2.76},
+		{Name: "Mf", S: 2, NPrime: 2, Amplitude: -0.26},
+		{Name: "MSf", S: 2, H: -2, Amplitude: -0.58},
+		{Name: "", S: 2, P: -2, Amplitude: -0.29},
+		{Name: "Mtm", S: 3, P: -1, Amplitude: -1.27},
+		{Name: "Mtm", S: 3, P: -1, NPrime: 1, Amplitude: -0.53},
+		{Name: "MStm", S: 3, H: -2, P: 1, Amplitude: -0.24},
+	},
+	TERMS_CTE: {
+		{Name: "Node", NPrime: 1, Amplitude: 2.793},
+		{Name: "Node", NPrime: 2, Amplitude: -0.027},
+		{Name: "Sa", H: 1, P1: -1, Amplitude: -0.492},
+		{Name: "Ssa", H: 2, Amplitude: -3.100},
+		{Name: "Ssa", H: 2, NPrime: 1, Amplitude: 0.077},
+		{Name: "Sta", H: 3, P1: -1, Amplitude: -0.181},
+		{Name: "MSm", S: 1, H: -2, P: 1, Amplitude: -0.673},
+		{Name: "Mm", S: 1, P: -1, NPrime: -1, Amplitude: 0.231},
+		{Name: "Mm", S: 1, P: -1, Amplitude: -3.518},
+		{Name: "Mm", S: 1, P: -1, NPrime: 1, Amplitude: 0.229},
+		{Name: "", S: 1, P: 1, Amplitude: 0.188},
+		{Name: "MSf", S: 2, H: -2, Amplitude: -0.583},
+		{Name: "", S: 2, P: -2, Amplitude: -0.288},
+		{Name: "Mf", S: 2, Amplitude: -6.663},
+		{Name: "Mf", S: 2, NPrime: 1, Amplitude: -2.762},
+		{Name: "Mf", S: 2, NPrime: 2, Amplitude: -0.258},
+		{Name: "MStm", S: 3, H: -2, P: 1, Amplitude: -0.242},
+		{Name: "Mtm", S: 3, P: -1, Amplitude: -1.276},
+		{Name: "Mtm", S: 3, P: -1, NPrime: 1, Amplitude: -0.529},
+		{Name: "MSqm", S: 4, H: -2, Amplitude: -0.204},
+		{Name: "Mqm", S: 4, P: -2, Amplitude: -0.169},
+	},
+}
+
+func GetTermSetFromString(termSet string) (TermSet, error) {
+	if _, ok := availableTermSets[TermSet(termSet)]; ok {
+		return TermSet(termSet), nil
+	}
+	return "", ErrTermSetNotFound
+}
+
+func GetTerms(termSet TermSet) ([]LongPeriodTerm, error) {
+	terms, ok := availableTermSets[termSet]
+	if !ok {
+		return nil, ErrTermSetNotFound
+	}
+	return terms, nil
+}
+
+// long period equilibrium tide in cm at lat (degree) with the terms used by perth3.f
 func CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc time.Time, lat float32) float64 {
+	return CalculateLongPeriodEquilibriumTide(timeUtc, lat, availableTermSets[TERMS_PERTH3])
+}
+
+// long period equilibrium tide in cm at lat (degree) summed over the given terms,
+// the nodal term is included but not the constant term
+func CalculateLongPeriodEquilibriumTide(timeUtc time.Time, lat float32, terms []LongPeriodTerm) float64 {
+	meanLongitudes := astro.ComputeAstronomicalMeanLongitudesInDegree(timeUtc)
+	nPrime := -meanLongitudes.L_N
+
+	zlp := 0.0
+	for _, term := range terms {
+		argument := float64(term.S)*meanLongitudes.L_s +
+			float64(term.H)*meanLongitudes.L_h +
+			float64(term.P)*meanLongitudes.L_p +
+			float64(term.NPrime)*nPrime +
+			float64(term.P1)*meanLongitudes.L_P1
+		zlp = zlp + term.Amplitude*math.Cos(argument*(math.Pi/180))
+	}
 
-	const PSOL = 283 * (math.Pi / 180)
-	shpnp := astro.ComputeAstronomicalMeanLongitudesInDegree(timeUtc)
-	// mjdInSeconds := datetime.UTCTimeToMJD(timeUtc) * 86400
-	// et := (mjdInSeconds - 4043174400) / 86400
-	// shpnp := astro.SHPNP{
-	// 	L_s: (math.Pi / 180) * math.Mod(290.21+et*13.1763965, 360),
-	// 	L_h: (math.Pi / 180) * math.Mod(280.12+et*0.9856473, 360),
-	// 	L_p: (math.Pi / 180) * math.Mod(274.35+et*0.1114041, 360),
-	// 	L_N: (math.Pi / 180) * math.Mod(343.51+et*0.0529539, 360),
-	// }
-
-	zlp := 2.79*math.Cos(shpnp.L_N) - 0.49*math.Cos(shpnp.L_h-PSOL) - 3.1*math.Cos(2*shpnp.L_h)
-	ph := shpnp.L_s
-
-	zlp = zlp -
-		0.67*math.Cos(ph-2*shpnp.L_h+shpnp.L_p) -
-		(3.52-0.46*math.Cos(shpnp.L_N))*math.Cos(ph-shpnp.L_p)
-
-	ph = ph + shpnp.L_s
-	zlp = zlp - 6.66*math.Cos(ph) -
-		2.76*math.Cos(ph+shpnp.L_N) -
-		0.26*math.Cos(ph+2*shpnp.L_N) -
-		0.58*math.Cos(ph-2*shpnp.L_h) -
-		0.29*math.Cos(ph-2*shpnp.L_p)
-
-	ph = ph + shpnp.L_s
-	zlp = zlp - 1.27*math.Cos(ph-shpnp.L_p) -
-		0.53*math.Cos(ph-shpnp.L_p+shpnp.L_N) -
-		0.24*math.Cos(ph-2*shpnp.L_h+shpnp.L_p)
-
-	s := math.Sin(0 * (math.Pi / 180))
-
-	lpeqomt := 0.437 * zlp * (1.5*s*s - 0.5)
-
-	return lpeqomt
+	// multiply by gamma_2 * sqrt(5/4 pi) * P20(lat)
+	s := math.Sin(float64(lat) * (math.Pi / 180))
+	return 0.437 * zlp * (1.5*s*s - 0.5)
 }
@@ -0,0 +1,162 @@
+package lpeqomt_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/datetime"
+	"github.com/mzeiher/perth3-go/pkg/lpeqomt"
+)
+
+// direct transcription of LPEQMT from perth3.f with its own linear mean longitudes
+func referenceLpeqmt(timeUtc time.Time, lat float64) float64 {
+	const RAD = math.Pi / 180
+	const PSOL = 283 * RAD
+	phc := [4]float64{290.21, 280.12, 274.35, 343.51}
+	dpd := [4]float64{13.1763965, 0.9856473, 0.1114041, 0.0529539}
+
+	td := (datetime.UTCTimeToMJD(timeUtc)*86400 - 4043174400) / 86400
+	shpn := [4]float64{}
+	for n := 0; n < 4; n++ {
+		shpn[n] = RAD * math.Mod(phc[n]+td*dpd[n], 360)
+	}
+
+	zlp := 2.79*math.Cos(shpn[3]) - 0.49*math.Cos(shpn[1]-PSOL) - 3.10*math.Cos(2*shpn[1])
+	ph := shpn[0]
+	zlp = zlp - 0.67*math.Cos(ph-2*shpn[1]+shpn[2]) - (3.52-0.46*math.Cos(shpn[3]))*math.Cos(ph-shpn[2])
+	ph = ph + shpn[0]
+	zlp = zlp - 6.66*math.Cos(ph) - 2.76*math.Cos(ph+shpn[3]) - 0.26*math.Cos(ph+2*shpn[3]) -
+		0.58*math.Cos(ph-2*shpn[1]) - 0.29*math.Cos(ph-2*shpn[2])
+	ph = ph + shpn[0]
+	zlp = zlp - 1.27*math.Cos(ph-shpn[2]) - 0.53*math.Cos(ph-shpn[2]+shpn[3]) - 0.24*math.Cos(ph-2*shpn[1]+shpn[2])
+
+	s := math.Sin(lat * RAD)
+	return 0.437 * zlp * (1.5*s*s - 0.5)
+}
+
+func TestMatchesPerth3(t *testing.T) {
+	start := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 365*30; day += 7 {
+		timeUtc := start.Add(time.Duration(day) * 24 * time.Hour)
+		for _, lat := range []float32{-80, -45, 0, 20, 54.2} {
+			expected := referenceLpeqmt(timeUtc, float64(lat))
+			actual := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, lat)
+			if math.Abs(expected-actual) > 0.02 {
+				t.Fatalf("%s lat %f: expected %f cm, got %f cm", timeUtc, lat, expected, actual)
+			}
+		}
+	}
+}
+
+func TestLatitudeDependence(t *testing.T) {
+	timeUtc := time.Date(2010, 6, 3, 12, 0, 0, 0, time.UTC)
+	equator := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, 0)
+	if math.Abs(equator) < 0.1 {
+		t.Fatalf("expected a long period tide at the equator, got %f cm", equator)
+	}
+	// P20 is -0.5 at the equator and 1 at the poles
+	for _, lat := range []float32{90, -90} {
+		pole := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, lat)
+		if math.Abs(pole+2*equator) > 1e-4 {
+			t.Errorf("lat %f: expected %f cm, got %f cm", lat, -2*equator, pole)
+		}
+	}
+	// zero of P20
+	nodalLat := float32(math.Asin(math.Sqrt(1.0/3)) * 180 / math.Pi)
+	if value := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, nodalLat); math.Abs(value) > 1e-4 {
+		t.Errorf("expected no long period tide at %f, got %f cm", nodalLat, value)
+	}
+}
+
+func TestTermSets(t *testing.T) {
+	perth3Terms, err := lpeqomt.GetTerms(lpeqomt.TERMS_PERTH3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cteTerms, err := lpeqomt.GetTerms(lpeqomt.TERMS_CTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lpeqomt.GetTermSetFromString("unknown"); err != lpeqomt.ErrTermSetNotFound {
+		t.Errorf("expected ErrTermSetNotFound, got %v", err)
+	}
+
+	// the additional terms of the full spectrum are all below 2.1mm (sum 0.86cm) before the P20 factor
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for hour := 0; hour < 24*365*2; hour += 5 {
+		timeUtc := start.Add(time.Duration(hour) * time.Hour)
+		perth3 := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, 0, perth3Terms)
+		cte := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, 0, cteTerms)
+		if math.Abs(perth3-cte) > 0.437*0.5*0.9 {
+			t.Fatalf("%s: perth3 %f cm and cte %f cm differ too much", timeUtc, perth3, cte)
+		}
+	}
+}
+
+// long period part of the Cartwright-Tayler-Edden tables (Cartwright and Edden 1973): Doodson
+// number (tau, s, h, p, N', p1 with 5 added to all but tau) and amplitude in m
+var cteTable = []struct {
+	doodson   string
+	amplitude float64
+}{
+	{"055.565", 0.02793}, {"055.575", -0.00027}, {"056.554", -0.00492}, {"057.555", -0.03100},
+	{"057.565", 0.00077}, {"058.554", -0.00181}, {"063.655", -0.00673}, {"065.445", 0.00231},
+	{"065.455", -0.03518}, {"065.465", 0.00229}, {"065.655", 0.00188}, {"073.555", -0.00583},
+	{"075.355", -0.00288}, {"075.555", -0.06663}, {"075.565", -0.02762}, {"075.575", -0.00258},
+	{"083.655", -0.00242}, {"085.455", -0.01276}, {"085.465", -0.00529}, {"093.555", -0.00204},
+	{"095.355", -0.00169},
+}
+
+func TestCteTerms(t *testing.T) {
+	terms, err := lpeqomt.GetTerms(lpeqomt.TERMS_CTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != len(cteTable) {
+		t.Fatalf("expected %d terms, got %d", len(cteTable), len(terms))
+	}
+
+	for _, line := range cteTable {
+		digits := strings.Replace(line.doodson, ".", "", 1)
+		arguments := [5]int{}
+		for i := range arguments {
+			arguments[i] = int(digits[i+1]-'0') - 5
+		}
+		found := false
+		for _, term := range terms {
+			if [5]int{term.S, term.H, term.P, term.NPrime, term.P1} != arguments {
+				continue
+			}
+			found = true
+			if math.Abs(term.Amplitude-line.amplitude*100) > 1e-9 {
+				t.Errorf("%s %s: expected amplitude %f cm, got %f cm", line.doodson, term.Name, line.amplitude*100, term.Amplitude)
+			}
+		}
+		if !found {
+			t.Errorf("%s: term missing", line.doodson)
+		}
+	}
+}
+
+func TestCteValues(t *testing.T) {
+	terms, err := lpeqomt.GetTerms(lpeqomt.TERMS_CTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []struct {
+		time  time.Time
+		lat   float32
+		value float64
+	}{
+		{time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), 0, -0.459595},
+		{time.Date(2015, 7, 14, 6, 30, 0, 0, time.UTC), 45, 0.469081},
+		{time.Date(2031, 11, 2, 18, 0, 0, 0, time.UTC), -70, 1.586450},
+	} {
+		value := lpeqomt.CalculateLongPeriodEquilibriumTide(expected.time, expected.lat, terms)
+		if math.Abs(value-expected.value) > 1e-5 {
+			t.Errorf("%s lat %f: expected %f cm, got %f cm", expected.time, expected.lat, expected.value, value)
+		}
+	}
+}
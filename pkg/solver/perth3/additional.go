@@ -0,0 +1,121 @@
+package perth3
+
+import (
+	"math"
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/astro"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+)
+
+// indices of the constituents the compound constituents are made of, same order as in perth3.f
+const (
+	indexK1 = 3
+	indexN2 = 4
+	indexM2 = 5
+	indexS2 = 6
+	indexK2 = 7
+	indexT2 = 25
+)
+
+// multiple of one of the 28 constituents of perth3.f
+type compoundTerm struct {
+	index    int
+	multiple float64
+}
+
+// constituent a provider may supply in addition to the 28 constituents of perth3.f, these constituents
+// are not inferred if missing. EPS2 and the other constituents not listed here are ignored
+type additionalConstituent struct {
+	constituent constituents.Constituent
+	// long period constituents are only used with WithLongPeriodConstituents
+	longPeriod bool
+	// compound constituents: argument, angular speed and u are the sums of the multiples
+	// of the terms, f is the product of f^|multiple|
+	terms []compoundTerm
+	// phase added to the argument in degree
+	phase float64
+	// long period constituents (no terms): argument as multiples of the mean longitudes
+	// s, h and p and the nodal corrections, nil if f = 1 and u = 0
+	s, h, p float64
+	nodal   func(sinn float64, cosn float64, sin2n float64, cos2n float64) (float64, float64)
+}
+
+// nodal corrections of Mm
+func nodalMm(sinn float64, cosn float64, sin2n float64, cos2n float64) (float64, float64) {
+	return 1.0 - 0.130*cosn, 0
+}
+
+// nodal corrections of Mf, also used for Mtm and MSqm
+func nodalMf(sinn float64, cosn float64, sin2n float64, cos2n float64) (float64, float64) {
+	return 1.043 + 0.414*cosn, -23.7*sinn + 2.7*sin2n
+}
+
+// with WithLongPeriodConstituents the supplied long period constituents replace their terms of the
+// long period equilibrium tide
+var additionalConstituents = []additionalConstituent{
+	{constituent: constituents.C_SA, longPeriod: true, h: 1},
+	{constituent: constituents.C_SSA, longPeriod: true, h: 2},
+	{constituent: constituents.C_MM, longPeriod: true, s: 1, p: -1, nodal: nodalMm},
+	{constituent: constituents.C_MF, longPeriod: true, s: 2, nodal: nodalMf},
+	{constituent: constituents.C_MTM, longPeriod: true, s: 3, p: -1, nodal: nodalMf},
+	{constituent: constituents.C_MSQM, longPeriod: true, s: 4, h: -2, nodal: nodalMf},
+	{constituent: constituents.C_MSF, longPeriod: true, terms: []compoundTerm{{indexS2, 1}, {indexM2, -1}}},
+	{constituent: constituents.C_2SM2, terms: []compoundTerm{{indexS2, 2}, {indexM2, -1}}},
+	{constituent: constituents.C_MKS2, terms: []compoundTerm{{indexM2, 1}, {indexK2, 1}, {indexS2, -1}}},
+	{constituent: constituents.C_R2, terms: []compoundTerm{{indexS2, 2}, {indexT2, -1}}, phase: 180},
+	{constituent: constituents.C_M3, terms: []compoundTerm{{indexM2, 1.5}}},
+	{constituent: constituents.C_MK3, terms: []compoundTerm{{indexM2, 1}, {indexK1, 1}}},
+	{constituent: constituents.C_2MK3, terms: []compoundTerm{{indexM2, 2}, {indexK1, -1}}},
+	{constituent: constituents.C_MN4, terms: []compoundTerm{{indexM2, 1}, {indexN2, 1}}},
+	{constituent: constituents.C_MS4, terms: []compoundTerm{{indexM2, 1}, {indexS2, 1}}},
+	{constituent: constituents.C_N4, terms: []compoundTerm{{indexN2, 2}}},
+	{constituent: constituents.C_S4, terms: []compoundTerm{{indexS2, 2}}},
+	{constituent: constituents.C_M6, terms: []compoundTerm{{indexM2, 3}}},
+	{constituent: constituents.C_S6, terms: []compoundTerm{{indexS2, 3}}},
+	{constituent: constituents.C_M8, terms: []compoundTerm{{indexM2, 4}}},
+}
+
+// harmonic constants of an additional constituent at a specific location
+type additionalHarmonic struct {
+	additionalConstituent
+	hcos float64
+	hsin float64
+}
+
+// returns the argument, f and u of the additional constituent, args, f and u are the
+// arguments and nodal corrections of the 28 constituents at timeUtc
+func (a additionalConstituent) arguments(timeUtc time.Time, args []float64, f []float64, u []float64) (float64, float64, float64) {
+	if a.terms == nil {
+		shpn := astro.ComputeAstronomicalMeanLongitudesInDegree(timeUtc)
+		argument := a.s*shpn.L_s + a.h*shpn.L_h + a.p*shpn.L_p + a.phase
+		if a.nodal == nil {
+			return argument, 1, 0
+		}
+		n := shpn.L_N * (math.Pi / 180)
+		fa, ua := a.nodal(math.Sin(n), math.Cos(n), math.Sin(2*n), math.Cos(2*n))
+		return argument, fa, ua
+	}
+
+	argument := a.phase
+	fa := 1.0
+	ua := 0.0
+	for _, term := range a.terms {
+		argument = argument + term.multiple*args[term.index]
+		fa = fa * math.Pow(f[term.index], math.Abs(term.multiple))
+		ua = ua + term.multiple*u[term.index]
+	}
+	return argument, fa, ua
+}
+
+// angular speed of the additional constituent in degree per hour
+func (a additionalConstituent) angularSpeed() float64 {
+	if a.terms == nil {
+		return a.s*RATE_S + a.h*RATE_H + a.p*RATE_P
+	}
+	speed := 0.0
+	for _, term := range a.terms {
+		speed = speed + term.multiple*angularSpeeds[term.index]
+	}
+	return speed
+}
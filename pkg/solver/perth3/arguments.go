@@ -51,3 +51,52 @@ func CalculateArguments(timeUtc time.Time) []float64 {
 	return arg
 
 }
+
+// mean rates of change of the astronomical mean longitudes in degree per hour
+const (
+	RATE_T  = 15.0        // mean solar hour angle
+	RATE_S  = 0.549016532 // mean longitude of moon
+	RATE_H  = 0.041068639 // mean longitude of sun
+	RATE_P  = 0.004641834 // mean longitude of lunar perigee
+	RATE_P1 = 0.000001961 // mean longitude of solar perigee
+)
+
+// returns the angular speed of the arguments [0..27] in degree per hour,
+// the order is the same as for CalculateArguments
+func CalculateAngularSpeeds() []float64 {
+	t1 := RATE_T
+	t2 := 2 * RATE_T
+
+	speed := make([]float64, 28)
+
+	speed[0] = t1 + RATE_H - 3*RATE_S + RATE_P      // Q1
+	speed[1] = t1 + RATE_H - 2*RATE_S               // O1
+	speed[2] = t1 - RATE_H                          // P1
+	speed[3] = t1 + RATE_H                          // K1
+	speed[4] = t2 + 2*RATE_H - 3*RATE_S + RATE_P    // N2
+	speed[5] = t2 + 2*RATE_H - 2*RATE_S             // M2
+	speed[6] = t2                                   // S2
+	speed[7] = t2 + 2*RATE_H                        // K2
+	speed[8] = t1 - 4*RATE_S + RATE_H + 2*RATE_P    // 2Q1
+	speed[9] = t1 - 4*RATE_S + 3*RATE_H             // sigma1
+	speed[10] = t1 - 3*RATE_S + 3*RATE_H - RATE_P   // rho1
+	speed[11] = t1 - RATE_S + RATE_H - RATE_P       // M1
+	speed[12] = t1 - RATE_S + RATE_H + RATE_P       // M1
+	speed[13] = t1 - RATE_S + 3*RATE_H - RATE_P     // chi1
+	speed[14] = t1 - 2*RATE_H + RATE_P1             // pi1
+	speed[15] = t1 + 3*RATE_H                       // phi1
+	speed[16] = t1 + RATE_S - RATE_H + RATE_P       // theta1
+	speed[17] = t1 + RATE_S + RATE_H - RATE_P       // J1
+	speed[18] = t1 + 2*RATE_S + RATE_H              // OO1
+	speed[19] = t2 - 4*RATE_S + 2*RATE_H + 2*RATE_P // 2N2
+	speed[20] = t2 - 4*RATE_S + 4*RATE_H            // mu2
+	speed[21] = t2 - 3*RATE_S + 4*RATE_H - RATE_P   // nu2
+	speed[22] = t2 - RATE_S + RATE_P                // lambda2
+	speed[23] = t2 - RATE_S + 2*RATE_H - RATE_P     // L2
+	speed[24] = t2 - RATE_S + 2*RATE_H + RATE_P     // L2
+	speed[25] = t2 - RATE_H + RATE_P1               // T2
+	speed[26] = t1                                  // S1 (Doodson's phase)
+	speed[27] = 2 * speed[5]                        // M4
+
+	return speed
+}
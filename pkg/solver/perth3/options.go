@@ -0,0 +1,37 @@
+package perth3
+
+import "github.com/mzeiher/perth3-go/pkg/lpeqomt"
+
+type options struct {
+	// terms of the long period equilibrium tide
+	longPeriodTerms lpeqomt.TermSet
+	// use the supplied long period constituents instead of their equilibrium terms
+	longPeriodConstituents bool
+}
+
+// option of the solver, the defaults match perth3.f
+type Option func(*options)
+
+// selects the terms of the long period equilibrium tide, lpeqomt.TERMS_PERTH3 by default
+func WithLongPeriodTerms(termSet lpeqomt.TermSet) Option {
+	return func(o *options) {
+		o.longPeriodTerms = termSet
+	}
+}
+
+// uses the long period constituents supplied by the provider (SA, SSA, MM, MF, MSF, MTM, MSQM) instead
+// of their terms of the long period equilibrium tide. By default they are ignored, the SA and SSA of tide
+// stations are mostly caused by the weather and the seasonal heating of the ocean, not by the tide potential
+func WithLongPeriodConstituents() Option {
+	return func(o *options) {
+		o.longPeriodConstituents = true
+	}
+}
+
+func getOptions(solverOptions []Option) options {
+	o := options{longPeriodTerms: lpeqomt.TERMS_PERTH3}
+	for _, option := range solverOptions {
+		option(&o)
+	}
+	return o
+}
@@ -1,13 +1,238 @@
 package perth3_test
 
 import (
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/astro"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/lpeqomt"
 	"github.com/mzeiher/perth3-go/pkg/solver/perth3"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
 	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
 )
 
+// creates a small constituent db with smoothly varying amplitudes and phases
+// for all constituents needed by the perth3 solver
+func createTestDb(t *testing.T) *tidedatadb.TideDataDB {
+	tideDataDb, err := tidedatadb.OpenTideDataDb(createTestDbFile(t), tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tideDataDb
+}
+
+func createTestDbFile(t testing.TB) string {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.CreateConstituentGrids(t, tideDataDb, tidedatadb.Dimensions{
+		MinLat:        30,
+		MaxLat:        40,
+		MinLon:        -10,
+		MaxLon:        0,
+		ResolutionLat: 1,
+		ResolutionLon: 1,
+		GridXSize:     11,
+		GridYSize:     11,
+	}, func(index int, x uint64, y uint64) (float32, float32) {
+		return float32(100/(index+1)) + float32(x+y), float32(index*30) + float32(x*2+y)
+	})
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestSolveSeriesMatchesSolve(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	step := 30 * time.Minute
+
+	heights, err := perth3.SolveSeries(tideDataDb, 37.010503, -8.962977, start, end, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heights) != 49 {
+		t.Fatalf("expected 49 heights, got %d", len(heights))
+	}
+	for index, height := range heights {
+		expected, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, start.Add(time.Duration(index)*step))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(expected-height) > 1e-9 {
+			t.Errorf("step %d: expected %f, got %f", index, expected, height)
+		}
+	}
+}
+
+func TestSolveLocationMatchesSolve(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	heightAt, err := perth3.SolveLocation(tideDataDb, 37.010503, -8.962977)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 17 * time.Minute, 5*time.Hour + 3*time.Second, 30 * 24 * time.Hour} {
+		expected, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, start.Add(offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height := heightAt(start.Add(offset)); height != expected {
+			t.Errorf("%s: expected %f, got %f", offset, expected, height)
+		}
+	}
+
+	if _, err := perth3.SolveLocation(constantProvider{}, 37, -9); !errors.Is(err, perth3.ErrNoConstituents) {
+		t.Errorf("expected ErrNoConstituents, got %v", err)
+	}
+}
+
+func TestSolveDetailedRate(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	predictions, err := perth3.SolveSeriesDetailed(tideDataDb, 37.010503, -8.962977, start, start.Add(25*time.Hour), 20*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tidePrediction := range predictions {
+		height, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, tidePrediction.Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(height-tidePrediction.Height) > 1e-9 {
+			t.Errorf("%s: expected height %f, got %f", tidePrediction.Time, height, tidePrediction.Height)
+		}
+
+		// compare with the numerical derivative
+		before, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, tidePrediction.Time.Add(-30*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, tidePrediction.Time.Add(30*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		numericalRate := (after - before) * 60
+		if math.Abs(numericalRate-tidePrediction.Rate) > 0.01 {
+			t.Errorf("%s: expected rate %f cm/h, got %f cm/h", tidePrediction.Time, numericalRate, tidePrediction.Rate)
+		}
+	}
+
+	detailed, err := perth3.SolveDetailed(tideDataDb, 37.010503, -8.962977, predictions[3].Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*detailed, predictions[3]) {
+		t.Errorf("expected %v, got %v", predictions[3], *detailed)
+	}
+}
+
+func TestSolveDetailedConstituents(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	tidePrediction, err := perth3.SolveDetailed(tideDataDb, 37.5, -8.5, time.Date(2023, 3, 1, 6, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tidePrediction.Constituents) != 28 {
+		t.Fatalf("expected 28 constituents, got %d", len(tidePrediction.Constituents))
+	}
+
+	sum := tidePrediction.LongPeriodEquilibrium
+	for index, contribution := range tidePrediction.Constituents {
+		sum = sum + contribution.Height
+		expectedInferred := index >= 8 && index <= 25
+		if contribution.Inferred != expectedInferred {
+			t.Errorf("%s: expected inferred %t, got %t", contribution.Constituent, expectedInferred, contribution.Inferred)
+		}
+		if contribution.Phase < 0 || contribution.Phase >= 360 || contribution.Argument < 0 || contribution.Argument >= 360 {
+			t.Errorf("%s: phase %f or argument %f out of range", contribution.Constituent, contribution.Phase, contribution.Argument)
+		}
+		expectedHeight := contribution.F * contribution.Amplitude * math.Cos((contribution.Argument+contribution.U-contribution.Phase)*math.Pi/180)
+		if math.Abs(expectedHeight-contribution.Height) > 1e-9 {
+			t.Errorf("%s: expected contribution %f, got %f", contribution.Constituent, expectedHeight, contribution.Height)
+		}
+	}
+	if math.Abs(sum-tidePrediction.Height) > 1e-9 {
+		t.Errorf("expected contributions to sum up to %f, got %f", tidePrediction.Height, sum)
+	}
+
+	// major constituents are read from the db
+	m2 := tidePrediction.Constituents[5]
+	if m2.Constituent != constituents.C_M2 {
+		t.Fatalf("expected M2 at index 5, got %s", m2.Constituent)
+	}
+	m2Data, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2Datum, err := m2Data.GetDataInterpolatedLatLon(37.5, -8.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(m2Datum.Amplitude-m2.Amplitude) > 1e-3 || math.Abs(m2Datum.Phase-m2.Phase) > 1e-3 {
+		t.Errorf("expected M2 amplitude %f phase %f, got %f %f", m2Datum.Amplitude, m2Datum.Phase, m2.Amplitude, m2.Phase)
+	}
+}
+
+func TestSolveUnrelatedConstituent(t *testing.T) {
+	tideDataDb, err := tidedatadb.OpenTideDataDb(createTestDbFile(t), tidedatadb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	// a constituent not used by the solver without ocean data at the location
+	if _, err := tideDataDb.CreateNewConstituentData(tidedatadb.Dimensions{
+		MinLat: 30, MaxLat: 40, MinLon: -10, MaxLon: 0, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 11, GridYSize: 11,
+	}, tidedatadb.ConstituentInfo{
+		Constituent:   constituents.C_EPS2,
+		AmplitudeUnit: tidedatadb.UNIT_CM,
+		PhaseUnit:     tidedatadb.UNIT_DEGREE,
+		HasFillValue:  true,
+		FillValue:     -1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tideDataDb.ConstituentsAt(37, -8); !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Fatalf("expected ErrNoOceanData, got %v", err)
+	}
+	if _, err := perth3.Solve(tideDataDb, 37, -8, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("expected the solver to ignore EPS2, got %v", err)
+	}
+}
+
+func TestSolveSeriesInvalidTimeRange(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := perth3.SolveSeries(tideDataDb, 37, -8, start, start.Add(-time.Hour), time.Minute); err != perth3.ErrInvalidTimeRange {
+		t.Errorf("expected ErrInvalidTimeRange for end before start, got %v", err)
+	}
+	if _, err := perth3.SolveSeries(tideDataDb, 37, -8, start, start.Add(time.Hour), 0); err != perth3.ErrInvalidTimeRange {
+		t.Errorf("expected ErrInvalidTimeRange for zero step, got %v", err)
+	}
+}
+
 func BenchmarkPerth3Solver(b *testing.B) {
 	tideDataDb, err := tidedatadb.OpenTideDataDb("../../../.data/dtu16.nc", tidedatadb.MODE_READONLY)
 	if err != nil {
@@ -21,3 +246,393 @@ func BenchmarkPerth3Solver(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkPerth3SolverInMemory(b *testing.B) {
+	tideDataDb, err := tidedatadb.LoadTideDataDb("../../../.data/dtu16.nc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	for n := 0; n < b.N; n++ {
+		_, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, time.Date(2023, 1, 1, 00, 00, 00, 00, time.UTC))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// compares the lookup of the harmonic constants from the file, the memory mapped file and memory
+func BenchmarkPerth3SolverStorage(b *testing.B) {
+	filePath := createTestDbFile(b)
+	for _, storage := range []struct {
+		name string
+		open func() (*tidedatadb.TideDataDB, error)
+	}{
+		{"file", func() (*tidedatadb.TideDataDB, error) {
+			return tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+		}},
+		{"mmap", func() (*tidedatadb.TideDataDB, error) {
+			return tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_MMAP)
+		}},
+		{"memory", func() (*tidedatadb.TideDataDB, error) { return tidedatadb.LoadTideDataDb(filePath) }},
+	} {
+		b.Run(storage.name, func(b *testing.B) {
+			tideDataDb, err := storage.open()
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer tideDataDb.Close()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				_, err := perth3.Solve(tideDataDb, 37.010503, -8.962977, time.Date(2023, 1, 1, 00, 00, 00, 00, time.UTC))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPerth3SeriesSolver(b *testing.B) {
+	tideDataDb, err := tidedatadb.OpenTideDataDb("../../../.data/dtu16.nc", tidedatadb.MODE_READONLY)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	start := time.Date(2023, 1, 1, 00, 00, 00, 00, time.UTC)
+	for n := 0; n < b.N; n++ {
+		_, err := perth3.SolveSeries(tideDataDb, 37.010503, -8.962977, start, start.Add(24*time.Hour), time.Minute)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSolveStationMatchesGrid(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	// grid node x=1, y=7 of the test db
+	solverConstituents := []constituents.Constituent{constituents.C_Q1, constituents.C_O1, constituents.C_P1, constituents.C_K1, constituents.C_N2, constituents.C_M2, constituents.C_S2, constituents.C_K2, constituents.C_S1, constituents.C_M4}
+	station := &stationdb.Station{ID: "test", Lat: 37, Lon: -9}
+	for index, constituent := range solverConstituents {
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{
+			Constituent: constituent,
+			Amplitude:   float64(100/(index+1) + 8),
+			Phase:       float64(index*30 + 9),
+		})
+	}
+	// constituents not used by the solver are ignored
+	station.Constituents = append(station.Constituents, constituents.ConstituentDatum{Constituent: constituents.C_EPS2, Amplitude: 10, Phase: 10})
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	step := time.Hour
+
+	heights, err := perth3.SolveSeries(station, station.Lat, station.Lon, start, end, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	predictions, err := perth3.SolveSeriesDetailed(station, station.Lat, station.Lon, start, end, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, height := range heights {
+		timeUtc := start.Add(time.Duration(index) * step)
+		expected, err := perth3.Solve(tideDataDb, 37, -9, timeUtc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(expected-height) > 1e-3 {
+			t.Errorf("step %d: expected %f, got %f", index, expected, height)
+		}
+		single, err := perth3.Solve(station, station.Lat, station.Lon, timeUtc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if single != height || math.Abs(predictions[index].Height-height) > 1e-9 {
+			t.Errorf("step %d: station series and single solver differ", index)
+		}
+	}
+}
+
+func TestSolveStationMissingConstituents(t *testing.T) {
+	timeUtc := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := perth3.Solve(&stationdb.Station{ID: "empty"}, 0, 0, timeUtc)
+	if !errors.Is(err, perth3.ErrNoConstituents) {
+		t.Fatalf("expected ErrNoConstituents, got %v", err)
+	}
+
+	// the missing major constituents are named
+	station := &stationdb.Station{ID: "m2", Constituents: []constituents.ConstituentDatum{{Constituent: constituents.C_M2, Amplitude: 100, Phase: 45}}}
+	_, err = perth3.SolveDetailed(station, station.Lat, station.Lon, timeUtc)
+	if !errors.Is(err, perth3.ErrMissingConstituents) || !strings.HasSuffix(err.Error(), ": S2, K1, O1, N2") {
+		t.Fatalf("expected ErrMissingConstituents for S2, K1, O1 and N2, got %v", err)
+	}
+
+	// without Q1, P1, K2, S1 and M4: Q1, P1 and K2 are inferred from O1, K1 and S2, S1 and M4 are missing
+	for _, constituent := range []constituents.Constituent{constituents.C_S2, constituents.C_K1, constituents.C_O1, constituents.C_N2} {
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{Constituent: constituent, Amplitude: 10, Phase: 90})
+	}
+	detailed, err := perth3.SolveDetailed(station, station.Lat, station.Lon, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, expected := range map[int]struct {
+		amplitude float64
+		inferred  bool
+		missing   bool
+	}{
+		0:  {1.91, true, false},
+		2:  {3.31, true, false},
+		5:  {100, false, false},
+		7:  {2.72, true, false},
+		26: {0, false, true},
+		27: {0, false, true},
+	} {
+		contribution := detailed.Constituents[index]
+		if math.Abs(contribution.Amplitude-expected.amplitude) > 1e-9 || contribution.Inferred != expected.inferred || contribution.Missing != expected.missing {
+			t.Errorf("%s: expected amplitude %f, inferred %t and missing %t, got %f, %t and %t", contribution.Constituent, expected.amplitude, expected.inferred, expected.missing, contribution.Amplitude, contribution.Inferred, contribution.Missing)
+		}
+		if expected.inferred && math.Abs(contribution.Phase-90) > 1e-9 {
+			t.Errorf("%s: expected the phase 90 of the constituent it is inferred from, got %f", contribution.Constituent, contribution.Phase)
+		}
+	}
+}
+
+// in-memory harmonic constants, the same at every location
+type constantProvider struct {
+	datums []constituents.ConstituentDatum
+	err    error
+}
+
+func (c constantProvider) ConstituentsAt(lat float32, lon float32, wanted ...constituents.Constituent) ([]constituents.ConstituentDatum, error) {
+	return c.datums, c.err
+}
+
+func TestSolveCustomProvider(t *testing.T) {
+	timeUtc := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	datums := majorConstituents()
+	station := &stationdb.Station{ID: "test", Lat: 37, Lon: -9, Constituents: datums}
+
+	expected, err := perth3.Solve(station, station.Lat, station.Lon, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	height, err := perth3.Solve(constantProvider{datums: datums}, 37, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != expected {
+		t.Errorf("expected %f, got %f", expected, height)
+	}
+
+	providerErr := errors.New("provider failed")
+	if _, err := perth3.Solve(constantProvider{err: providerErr}, 37, -9, timeUtc); !errors.Is(err, providerErr) {
+		t.Errorf("expected error of the provider, got %v", err)
+	}
+	if _, err := perth3.SolveSeries(constantProvider{}, 37, -9, timeUtc, timeUtc, time.Hour); !errors.Is(err, perth3.ErrNoConstituents) {
+		t.Errorf("expected ErrNoConstituents, got %v", err)
+	}
+}
+
+// harmonic constants of the required major constituents
+func majorConstituents() []constituents.ConstituentDatum {
+	return []constituents.ConstituentDatum{
+		{Constituent: constituents.C_M2, Amplitude: 100, Phase: 45},
+		{Constituent: constituents.C_S2, Amplitude: 40, Phase: 80},
+		{Constituent: constituents.C_N2, Amplitude: 20, Phase: 30},
+		{Constituent: constituents.C_K1, Amplitude: 30, Phase: 120},
+		{Constituent: constituents.C_O1, Amplitude: 25, Phase: 100},
+	}
+}
+
+func TestSolveSuppliedMinorConstituents(t *testing.T) {
+	timeUtc := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	datums := append(majorConstituents(),
+		constituents.ConstituentDatum{Constituent: constituents.C_2N2, Amplitude: 3, Phase: 10},
+		constituents.ConstituentDatum{Constituent: constituents.C_M1, Amplitude: 2, Phase: 20},
+		constituents.ConstituentDatum{Constituent: constituents.C_LAMBDA2, Amplitude: 1, Phase: 30},
+	)
+	detailed, err := perth3.SolveDetailed(constantProvider{datums: datums}, 37, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detailed.Constituents) != 28 {
+		t.Fatalf("expected 28 constituents, got %d", len(detailed.Constituents))
+	}
+
+	supplied := map[int]constituents.ConstituentDatum{
+		// a supplied M1 replaces both M1 terms of perth3.f
+		11: {Constituent: constituents.C_M1},
+		12: datums[6],
+		19: datums[5],
+		22: datums[7],
+	}
+	for index := 8; index <= 25; index++ {
+		contribution := detailed.Constituents[index]
+		datum, ok := supplied[index]
+		if contribution.Inferred == ok {
+			t.Errorf("%d %s: expected inferred %t, got %t", index, contribution.Constituent, !ok, contribution.Inferred)
+		}
+		if !ok {
+			continue
+		}
+		if math.Abs(contribution.Amplitude-datum.Amplitude) > 1e-9 || (datum.Amplitude > 0 && math.Abs(contribution.Phase-datum.Phase) > 1e-9) {
+			t.Errorf("%d %s: expected amplitude %f phase %f, got %f %f", index, contribution.Constituent, datum.Amplitude, datum.Phase, contribution.Amplitude, contribution.Phase)
+		}
+	}
+}
+
+func TestSolveAdditionalConstituents(t *testing.T) {
+	timeUtc := time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC)
+	var lat float32 = 37
+	without, err := perth3.SolveDetailed(constantProvider{datums: majorConstituents()}, lat, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MS4 = M2 + S2
+	ms4 := constituents.ConstituentDatum{Constituent: constituents.C_MS4, Amplitude: 5, Phase: 40}
+	// SA replaces the Sa term of the long period equilibrium tide
+	sa := constituents.ConstituentDatum{Constituent: constituents.C_SA, Amplitude: 8, Phase: 200}
+	detailed, err := perth3.SolveDetailed(constantProvider{datums: append(majorConstituents(), ms4, sa)}, lat, -9, timeUtc, perth3.WithLongPeriodConstituents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detailed.Constituents) != 30 {
+		t.Fatalf("expected 30 constituents, got %d", len(detailed.Constituents))
+	}
+
+	args := perth3.CalculateArguments(timeUtc)
+	f, u := perth3.CalculateNodalCorrections(timeUtc)
+	meanLongitudes := astro.ComputeAstronomicalMeanLongitudesInDegree(timeUtc)
+	expectedMs4 := ms4.Amplitude * f[5] * f[6] * math.Cos((args[5]+args[6]+u[5]+u[6]-ms4.Phase)*math.Pi/180)
+	expectedSa := sa.Amplitude * math.Cos((meanLongitudes.L_h-sa.Phase)*math.Pi/180)
+
+	terms, err := lpeqomt.GetTerms(lpeqomt.TERMS_PERTH3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saTerms := []lpeqomt.LongPeriodTerm{}
+	for _, term := range terms {
+		if term.Name == "Sa" {
+			saTerms = append(saTerms, term)
+		}
+	}
+	equilibriumSa := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, lat, saTerms)
+
+	for index, expected := range []struct {
+		constituent constituents.Constituent
+		height      float64
+	}{{constituents.C_SA, expectedSa}, {constituents.C_MS4, expectedMs4}} {
+		contribution := detailed.Constituents[28+index]
+		if contribution.Constituent != expected.constituent || contribution.Inferred || math.Abs(contribution.Height-expected.height) > 1e-6 {
+			t.Errorf("expected %s contribution %f, got %s %f", expected.constituent, expected.height, contribution.Constituent, contribution.Height)
+		}
+	}
+	if math.Abs(without.LongPeriodEquilibrium-equilibriumSa-detailed.LongPeriodEquilibrium) > 1e-9 {
+		t.Errorf("expected long period equilibrium %f without Sa, got %f", without.LongPeriodEquilibrium-equilibriumSa, detailed.LongPeriodEquilibrium)
+	}
+	expectedHeight := without.Height - equilibriumSa + expectedMs4 + expectedSa
+	if math.Abs(expectedHeight-detailed.Height) > 1e-6 {
+		t.Errorf("expected height %f, got %f", expectedHeight, detailed.Height)
+	}
+
+	height, err := perth3.Solve(constantProvider{datums: append(majorConstituents(), ms4, sa)}, lat, -9, timeUtc, perth3.WithLongPeriodConstituents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(height-detailed.Height) > 1e-9 {
+		t.Errorf("expected Solve and SolveDetailed to match, got %f and %f", height, detailed.Height)
+	}
+}
+
+func TestSolveLongPeriodTerms(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	timeUtc := time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC)
+	var lat float32 = 37
+	perth3Height, err := perth3.Solve(tideDataDb, lat, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cteHeight, err := perth3.Solve(tideDataDb, lat, -9, timeUtc, perth3.WithLongPeriodTerms(lpeqomt.TERMS_CTE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	perth3Terms, err := lpeqomt.GetTerms(lpeqomt.TERMS_PERTH3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cteTerms, err := lpeqomt.GetTerms(lpeqomt.TERMS_CTE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, lat, cteTerms) - lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, lat, perth3Terms)
+	if math.Abs(cteHeight-perth3Height-expected) > 1e-9 {
+		t.Errorf("expected the term sets to differ by %f cm, got %f cm", expected, cteHeight-perth3Height)
+	}
+
+	heightAt, err := perth3.SolveLocation(tideDataDb, lat, -9, perth3.WithLongPeriodTerms(lpeqomt.TERMS_CTE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height := heightAt(timeUtc); height != cteHeight {
+		t.Errorf("expected %f, got %f", cteHeight, height)
+	}
+
+	if _, err := perth3.Solve(tideDataDb, lat, -9, timeUtc, perth3.WithLongPeriodTerms("unknown")); !errors.Is(err, lpeqomt.ErrTermSetNotFound) {
+		t.Errorf("expected ErrTermSetNotFound, got %v", err)
+	}
+}
+
+func TestSolveLongPeriodConstituentsOptIn(t *testing.T) {
+	timeUtc := time.Date(2023, 1, 1, 6, 0, 0, 0, time.UTC)
+	var lat float32 = 37
+
+	// without long period constituents: the 28 constituents of perth3.f and the full equilibrium tide
+	for _, options := range [][]perth3.Option{nil, {perth3.WithLongPeriodConstituents()}} {
+		detailed, err := perth3.SolveDetailed(constantProvider{datums: majorConstituents()}, lat, -9, timeUtc, options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(detailed.Constituents) != 28 {
+			t.Fatalf("expected 28 constituents, got %d", len(detailed.Constituents))
+		}
+		expected := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, lat)
+		for _, contribution := range detailed.Constituents {
+			expected = expected + contribution.Height
+		}
+		if math.Abs(detailed.Height-expected) > 1e-9 || detailed.LongPeriodEquilibrium != lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, lat) {
+			t.Errorf("expected %f, got %f", expected, detailed.Height)
+		}
+	}
+
+	// supplied long period constituents are ignored by default
+	expected, err := perth3.Solve(constantProvider{datums: majorConstituents()}, lat, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datums := append(majorConstituents(),
+		constituents.ConstituentDatum{Constituent: constituents.C_SA, Amplitude: 8, Phase: 200},
+		constituents.ConstituentDatum{Constituent: constituents.C_SSA, Amplitude: 4, Phase: 100},
+	)
+	height, err := perth3.Solve(constantProvider{datums: datums}, lat, -9, timeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != expected {
+		t.Errorf("expected SA and SSA to be ignored, got %f instead of %f", height, expected)
+	}
+	height, err = perth3.Solve(constantProvider{datums: datums}, lat, -9, timeUtc, perth3.WithLongPeriodConstituents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height == expected {
+		t.Errorf("expected SA and SSA to be used with WithLongPeriodConstituents")
+	}
+}
@@ -1,49 +1,291 @@
 package perth3
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/mzeiher/perth3-go/pkg/constituents"
 	"github.com/mzeiher/perth3-go/pkg/lpeqomt"
-	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/solver/prediction"
 )
 
-func Solve(constituentDb *tidedatadb.TideDataDB, lat float32, lon float32, timeUtc time.Time) (float64, error) {
+var (
+	ErrInvalidTimeRange    = errors.New("end time must not be before start time and step must be positive")
+	ErrNoConstituents      = errors.New("none of the constituents used by the solver available at the location")
+	ErrMissingConstituents = errors.New("major constituents missing at the location")
+)
+
+// major constituents taken from the harmonic constants provider, the minor constituents are inferred
+// from these unless the provider supplies them
+var constituentsForSolver = []constituents.Constituent{constituents.C_Q1, constituents.C_O1, constituents.C_P1, constituents.C_K1, constituents.C_N2, constituents.C_M2, constituents.C_S2, constituents.C_K2, constituents.C_S1, constituents.C_M4}
+
+// constituents which must be available at the location, missing Q1, P1 and K2 are inferred
+// (see majorAdmittances), missing S1 and M4 are neglected
+var requiredConstituents = []constituents.Constituent{constituents.C_M2, constituents.C_S2, constituents.C_K1, constituents.C_O1, constituents.C_N2}
+
+// Q1, P1 and K2 (index 0, 2 and 7) are inferred from O1, K1 and S2 with the ratio of their
+// equilibrium amplitudes (Cartwright and Tayler) if they are missing
+var majorAdmittances = []struct {
+	index int
+	from  int
+	ratio float64
+}{{0, 1, 0.191}, {2, 3, 0.331}, {7, 6, 0.272}}
+
+// harmonic constants of the constituents used by the solver at a specific location
+type harmonicConstants struct {
+	// hcos and hsin of the 28 constituents of perth3.f
+	solver [28][2]float64
+	// true if the constituent at the index is inferred from the major constituents
+	inferred [28]bool
+	// true if the constituent at the index is neither available nor inferred and neglected
+	missing [28]bool
+	// constituents supplied by the provider in addition to the 28 constituents
+	additional []additionalHarmonic
+	// terms of the long period equilibrium tide not replaced by a supplied constituent
+	longPeriodTerms []lpeqomt.LongPeriodTerm
+}
+
+// constituents of the 28 harmonic constants and arguments, same order as in perth3.f
+var solverConstituents = [28]constituents.Constituent{
+	constituents.C_Q1, constituents.C_O1, constituents.C_P1, constituents.C_K1,
+	constituents.C_N2, constituents.C_M2, constituents.C_S2, constituents.C_K2,
+	constituents.C_2Q1, constituents.C_SIGMA1, constituents.C_RHO, constituents.C_M1,
+	constituents.C_M1, constituents.C_CHI1, constituents.C_PI1, constituents.C_PHI1,
+	constituents.C_THETA1, constituents.C_J1, constituents.C_OO1, constituents.C_2N2,
+	constituents.C_MU2, constituents.C_NU2, constituents.C_LAM2, constituents.C_L2,
+	constituents.C_L2, constituents.C_T2, constituents.C_S1, constituents.C_M4,
+}
+
+// index of the minor constituents (index 8 to 25) which are taken from the provider instead of
+// inferring them, perth3.f has two terms for M1 and L2, a supplied M1 or L2 replaces both
+var minorConstituents = map[constituents.Constituent]int{
+	constituents.C_2Q1: 8, constituents.C_SIGMA1: 9, constituents.C_RHO: 10, constituents.C_M1: 12,
+	constituents.C_CHI1: 13, constituents.C_PI1: 14, constituents.C_PHI1: 15, constituents.C_THETA1: 16,
+	constituents.C_J1: 17, constituents.C_OO1: 18, constituents.C_2N2: 19, constituents.C_MU2: 20,
+	constituents.C_NU2: 21, constituents.C_LAM2: 22, constituents.C_L2: 23, constituents.C_T2: 25,
+}
+
+// second term of M1 and L2, dropped if the constituent is supplied
+var secondaryTerms = map[int]int{12: 11, 23: 24}
+
+// constituents looked up from the provider: the major constituents, the minor constituents
+// and the additional constituents, the long period constituents only if they are used
+func wantedConstituents(solverOptions options) []constituents.Constituent {
+	wanted := append([]constituents.Constituent{}, constituentsForSolver...)
+	for index := 8; index <= 25; index++ {
+		if minorConstituents[solverConstituents[index]] == index {
+			wanted = append(wanted, solverConstituents[index])
+		}
+	}
+	wanted = append(wanted, constituents.C_LAMBDA2)
+	for _, additional := range additionalConstituents {
+		if !additional.longPeriod || solverOptions.longPeriodConstituents {
+			wanted = append(wanted, additional.constituent)
+		}
+	}
+	return wanted
+}
+
+// angular speeds of the 28 constituents in degree per hour
+var angularSpeeds = CalculateAngularSpeeds()
+
+// the long period equilibrium tide changes slowly, its rate is approximated with
+// a central difference over this interval
+const lpeqRateInterval = time.Minute
+
+func Solve(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (float64, error) {
+	harmonics, err := getHarmonicConstants(provider, lat, lon, getOptions(options))
+	if err != nil {
+		return 0, err
+	}
+	return solveHarmonicConstants(harmonics, lat, timeUtc), nil
+}
+
+// calculates the tide heights from startUtc to endUtc (inclusive) in steps of step,
+// the harmonic constants for the location are only looked up once for the whole series
+func SolveSeries(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]float64, error) {
+	steps, err := getNumberOfSteps(startUtc, endUtc, step)
+	if err != nil {
+		return nil, err
+	}
+	harmonics, err := getHarmonicConstants(provider, lat, lon, getOptions(options))
+	if err != nil {
+		return nil, err
+	}
+
+	heights := make([]float64, steps)
+	for i := range heights {
+		heights[i] = solveHarmonicConstants(harmonics, lat, startUtc.Add(time.Duration(i)*step))
+	}
+	return heights, nil
+}
+
+// like Solve but additionally returns the rate of rise/fall of the tide
+// and the contribution of each constituent
+func SolveDetailed(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (*prediction.TidePrediction, error) {
+	harmonics, err := getHarmonicConstants(provider, lat, lon, getOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	tidePrediction := predictHarmonicConstants(harmonics, lat, timeUtc)
+	return &tidePrediction, nil
+}
+
+// like SolveSeries but additionally returns the rate of rise/fall of the tide
+// and the contribution of each constituent for each step
+func SolveSeriesDetailed(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]prediction.TidePrediction, error) {
+	steps, err := getNumberOfSteps(startUtc, endUtc, step)
+	if err != nil {
+		return nil, err
+	}
+	harmonics, err := getHarmonicConstants(provider, lat, lon, getOptions(options))
+	if err != nil {
+		return nil, err
+	}
+
+	predictions := make([]prediction.TidePrediction, steps)
+	for i := range predictions {
+		predictions[i] = predictHarmonicConstants(harmonics, lat, startUtc.Add(time.Duration(i)*step))
+	}
+	return predictions, nil
+}
+
+// looks up the harmonic constants for the location once and returns a function calculating the
+// tide height at any time, e.g. for searches which evaluate the tide at many arbitrary times
+func SolveLocation(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, options ...Option) (func(timeUtc time.Time) float64, error) {
+	harmonics, err := getHarmonicConstants(provider, lat, lon, getOptions(options))
+	if err != nil {
+		return nil, err
+	}
+	return func(timeUtc time.Time) float64 {
+		return solveHarmonicConstants(harmonics, lat, timeUtc)
+	}, nil
+}
+
+func getNumberOfSteps(startUtc time.Time, endUtc time.Time, step time.Duration) (int, error) {
+	if step <= 0 || endUtc.Before(startUtc) {
+		return 0, ErrInvalidTimeRange
+	}
+	return int(endUtc.Sub(startUtc)/step) + 1, nil
+}
+
+// takes the harmonic constants at the location from the provider, the requiredConstituents must be
+// available, missing Q1, P1, K2 and minor constituents are inferred from the major constituents and
+// missing S1 and M4 are neglected
+func getHarmonicConstants(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, solverOptions options) (*harmonicConstants, error) {
+	datums, err := provider.ConstituentsAt(lat, lon, wantedConstituents(solverOptions)...)
+	if err != nil {
+		return nil, err
+	}
+
+	supplied := map[constituents.Constituent]constituents.ConstituentDatum{}
+	for _, datum := range datums {
+		constituent := datum.Constituent
+		// LAMBDA2 is an alias of LAM2
+		if constituent == constituents.C_LAMBDA2 {
+			constituent = constituents.C_LAM2
+		}
+		if _, ok := supplied[constituent]; !ok {
+			supplied[constituent] = datum
+		}
+	}
 
-	// solver array
+	// harmonic constants array
 	//   [
-	//	   [c_hcos, c_hsin, arg, f, u],
+	//	   [c_hcos, c_hsin],
 	//     ...
 	//   ]
-	var solver [][]float64 = make([][]float64, 28)
+	harmonics := harmonicConstants{}
 
-	args := CalculateArguments(timeUtc)
-	f, u := CalculateNodalCorrections(timeUtc)
-	for i := 0; i < 28; i++ {
-		solver[i] = make([]float64, 5)
-		solver[i][2] = args[i]
-		solver[i][3] = f[i]
-		solver[i][4] = u[i]
+	available := 0
+	for index, constituent := range constituentsForSolver {
+		if datum, ok := supplied[constituent]; ok {
+			harmonics.solver[index] = [2]float64{datum.GetHCos(), datum.GetHSin()}
+			available++
+		}
+	}
+	if available == 0 {
+		return nil, ErrNoConstituents
+	}
+	missing := []string{}
+	for _, constituent := range requiredConstituents {
+		if _, ok := supplied[constituent]; !ok {
+			missing = append(missing, constituent.String())
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingConstituents, strings.Join(missing, ", "))
+	}
+
+	for _, admittance := range majorAdmittances {
+		if _, ok := supplied[constituentsForSolver[admittance.index]]; !ok {
+			harmonics.solver[admittance.index][0] = admittance.ratio * harmonics.solver[admittance.from][0]
+			harmonics.solver[admittance.index][1] = admittance.ratio * harmonics.solver[admittance.from][1]
+			harmonics.inferred[admittance.index] = true
+		}
+	}
+	// S1 and M4 are moved to index 26 and 27 by inferHarmonicConstants
+	for index, constituent := range map[int]constituents.Constituent{26: constituents.C_S1, 27: constituents.C_M4} {
+		if _, ok := supplied[constituent]; !ok {
+			harmonics.missing[index] = true
+		}
 	}
 
-	constituentsForSolver := []constituents.Constituent{constituents.C_Q1, constituents.C_O1, constituents.C_P1, constituents.C_K1, constituents.C_N2, constituents.C_M2, constituents.C_S2, constituents.C_K2, constituents.C_S1, constituents.C_M4}
+	inferHarmonicConstants(&harmonics.solver)
+	for index := 8; index <= 25; index++ {
+		harmonics.inferred[index] = true
+	}
+	for constituent, index := range minorConstituents {
+		datum, ok := supplied[constituent]
+		if !ok {
+			continue
+		}
+		harmonics.solver[index] = [2]float64{datum.GetHCos(), datum.GetHSin()}
+		harmonics.inferred[index] = false
+		if secondary, ok := secondaryTerms[index]; ok {
+			harmonics.solver[secondary] = [2]float64{}
+			harmonics.inferred[secondary] = false
+		}
+	}
 
-	// populate solver with data from the constituent db
-	for index, constituent := range constituentsForSolver {
-		constituentData, err := constituentDb.GetConstituentData(constituent)
-		if err != nil {
-			return 0, err
+	harmonics.longPeriodTerms, err = lpeqomt.GetTerms(solverOptions.longPeriodTerms)
+	if err != nil {
+		return nil, err
+	}
+	for _, additional := range additionalConstituents {
+		datum, ok := supplied[additional.constituent]
+		if !ok || (additional.longPeriod && !solverOptions.longPeriodConstituents) {
+			continue
 		}
-		datum, err := constituentData.GetDataInterpolatedLatLon(lat, lon)
-		if err != nil {
-			return 0, err
+		harmonics.additional = append(harmonics.additional, additionalHarmonic{
+			additionalConstituent: additional,
+			hcos:                  datum.GetHCos(),
+			hsin:                  datum.GetHSin(),
+		})
+		harmonics.longPeriodTerms = withoutTerm(harmonics.longPeriodTerms, additional.constituent)
+	}
+
+	return &harmonics, nil
+}
+
+// returns the terms without the terms of the long period constituent
+func withoutTerm(terms []lpeqomt.LongPeriodTerm, constituent constituents.Constituent) []lpeqomt.LongPeriodTerm {
+	filtered := []lpeqomt.LongPeriodTerm{}
+	for _, term := range terms {
+		if !strings.EqualFold(term.Name, constituent.String()) {
+			filtered = append(filtered, term)
 		}
-		solver[index][0] = datum.GetHCos()
-		solver[index][1] = datum.GetHSin()
 	}
+	return filtered
+}
 
-	// move S2 to index 26 (like in perth3.f)
+// infers the minor constituents from the major constituents at index 0 to 9 (in the order
+// of constituentsForSolver) and moves S1 and M4 to their final position
+func inferHarmonicConstants(solver *[28][2]float64) {
+	// move S1 to index 26 (like in perth3.f)
 	solver[26][0] = solver[8][0]
 	solver[26][1] = solver[8][1]
 	// move M4 to index 27 (like in perth3.f)
@@ -87,17 +329,87 @@ func Solve(constituentDb *tidedatadb.TideDataDB, lat float32, lon float32, timeU
 	solver[24][1] = 0.0033*solver[5][1] + 0.0082*solver[6][1]  // L2 HSin
 	solver[25][0] = 0.0585 * solver[6][0]                      // T2 HCos
 	solver[25][1] = 0.0585 * solver[6][1]                      // T2 HSin
+}
+
+func solveHarmonicConstants(harmonics *harmonicConstants, lat float32, timeUtc time.Time) float64 {
+	args := CalculateArguments(timeUtc)
+	f, u := CalculateNodalCorrections(timeUtc)
 
 	var sum float64 = 0
 	// iterate over all heights
 	for i := 0; i < 28; i++ {
-		heightCos := solver[i][0]
-		heightSin := solver[i][1]
-		chiu := (solver[i][2] + solver[i][4]) * (math.Pi / 180)
-		sum = sum + heightCos*solver[i][3]*math.Cos(chiu) + heightSin*solver[i][3]*math.Sin(chiu)
+		heightCos := harmonics.solver[i][0]
+		heightSin := harmonics.solver[i][1]
+		chiu := (args[i] + u[i]) * (math.Pi / 180)
+		sum = sum + heightCos*f[i]*math.Cos(chiu) + heightSin*f[i]*math.Sin(chiu)
+	}
+	for _, additional := range harmonics.additional {
+		argument, fa, ua := additional.arguments(timeUtc, args, f, u)
+		chiu := (argument + ua) * (math.Pi / 180)
+		sum = sum + additional.hcos*fa*math.Cos(chiu) + additional.hsin*fa*math.Sin(chiu)
+	}
+
+	lpeqomt := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, lat, harmonics.longPeriodTerms)
+
+	return sum + lpeqomt
+}
+
+// calculates height, rate and the contributions of the constituents, the rate of each constituent is
+// calculated analytically from its angular speed, the slow changes of the nodal corrections are neglected
+func predictHarmonicConstants(harmonics *harmonicConstants, lat float32, timeUtc time.Time) prediction.TidePrediction {
+	args := CalculateArguments(timeUtc)
+	f, u := CalculateNodalCorrections(timeUtc)
+
+	contributions := make([]prediction.ConstituentContribution, 0, 28+len(harmonics.additional))
+
+	var height float64 = 0
+	var rate float64 = 0
+	addContribution := func(constituent constituents.Constituent, heightCos float64, heightSin float64, argument float64, f float64, u float64, speed float64, inferred bool, missing bool) {
+		chiu := (argument + u) * (math.Pi / 180)
+		cosChiu := math.Cos(chiu)
+		sinChiu := math.Sin(chiu)
+		contribution := heightCos*f*cosChiu + heightSin*f*sinChiu
+		height = height + contribution
+		rate = rate + (heightSin*f*cosChiu-heightCos*f*sinChiu)*speed*(math.Pi/180)
+
+		contributions = append(contributions, prediction.ConstituentContribution{
+			Constituent: constituent,
+			Amplitude:   math.Hypot(heightCos, heightSin),
+			Phase:       normalizeDegree(math.Atan2(heightSin, heightCos) * (180 / math.Pi)),
+			F:           f,
+			U:           u,
+			Argument:    normalizeDegree(argument),
+			Height:      contribution,
+			Inferred:    inferred,
+			Missing:     missing,
+		})
+	}
+	for i := 0; i < 28; i++ {
+		addContribution(solverConstituents[i], harmonics.solver[i][0], harmonics.solver[i][1], args[i], f[i], u[i], angularSpeeds[i], harmonics.inferred[i], harmonics.missing[i])
+	}
+	for _, additional := range harmonics.additional {
+		argument, fa, ua := additional.arguments(timeUtc, args, f, u)
+		addContribution(additional.constituent, additional.hcos, additional.hsin, argument, fa, ua, additional.angularSpeed(), false, false)
 	}
 
-	lpeqomt := lpeqomt.CalculateLongPeriodEquilibriumOceanMeanTide(timeUtc, lat)
+	lpeqomtHeight := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc, lat, harmonics.longPeriodTerms)
+	lpeqomtBefore := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc.Add(-lpeqRateInterval/2), lat, harmonics.longPeriodTerms)
+	lpeqomtAfter := lpeqomt.CalculateLongPeriodEquilibriumTide(timeUtc.Add(lpeqRateInterval/2), lat, harmonics.longPeriodTerms)
+
+	return prediction.TidePrediction{
+		Time:                  timeUtc,
+		Height:                height + lpeqomtHeight,
+		Rate:                  rate + (lpeqomtAfter-lpeqomtBefore)/lpeqRateInterval.Hours(),
+		Constituents:          contributions,
+		LongPeriodEquilibrium: lpeqomtHeight,
+	}
+}
 
-	return sum + lpeqomt, nil
+// normalizes an angle in degree to [0,360)
+func normalizeDegree(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle = angle + 360
+	}
+	return angle
 }
@@ -0,0 +1,39 @@
+package prediction
+
+import (
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+)
+
+// detailed result of a solver for a specific location and time
+type TidePrediction struct {
+	Time time.Time
+	// height of the tide in cm
+	Height float64
+	// rate of rise (positive) or fall (negative) of the tide in cm/h
+	Rate float64
+	// contribution of each constituent used by the solver, the sum of all
+	// contributions plus LongPeriodEquilibrium is the height of the tide
+	Constituents []ConstituentContribution
+	// long period equilibrium tide in cm
+	LongPeriodEquilibrium float64
+}
+
+type ConstituentContribution struct {
+	Constituent constituents.Constituent
+	// amplitude in cm and phase in degree of the constituent at the location
+	Amplitude float64
+	Phase     float64
+	// nodal corrections of amplitude (factor) and phase (degree)
+	F float64
+	U float64
+	// equilibrium argument in degree
+	Argument float64
+	// contribution to the tide height in cm
+	Height float64
+	// true if the constituent is inferred from the major constituents instead of read from the db
+	Inferred bool
+	// true if the constituent is not available at the location and neglected (amplitude 0)
+	Missing bool
+}
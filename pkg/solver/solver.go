@@ -4,13 +4,19 @@ import (
 	"errors"
 	"time"
 
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/lpeqomt"
 	"github.com/mzeiher/perth3-go/pkg/solver/perth3"
-	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/solver/prediction"
 )
 
 var ErrNoSolverFound = errors.New("no solver found for input")
 
 var availableSolver map[Solver]CreateSolverFunc = make(map[Solver]CreateSolverFunc)
+var availableSeriesSolver map[Solver]CreateSeriesSolverFunc = make(map[Solver]CreateSeriesSolverFunc)
+var availableDetailedSolver map[Solver]CreateDetailedSolverFunc = make(map[Solver]CreateDetailedSolverFunc)
+var availableDetailedSeriesSolver map[Solver]CreateDetailedSeriesSolverFunc = make(map[Solver]CreateDetailedSeriesSolverFunc)
+var availableLocationSolver map[Solver]CreateLocationSolverFunc = make(map[Solver]CreateLocationSolverFunc)
 
 type Solver string
 
@@ -35,11 +41,70 @@ func GetSolverFromString(solver string) (Solver, error) {
 	return unknown, ErrNoSolverFound
 }
 
+// options of the solvers, the zero values select the defaults of the solver
+type Options struct {
+	// terms of the long period equilibrium tide
+	LongPeriodTerms lpeqomt.TermSet
+	// use the long period constituents of the provider instead of their equilibrium terms
+	LongPeriodConstituents bool
+}
+
+type Option func(*Options)
+
+// selects the terms of the long period equilibrium tide
+func WithLongPeriodTerms(termSet lpeqomt.TermSet) Option {
+	return func(o *Options) {
+		o.LongPeriodTerms = termSet
+	}
+}
+
+// uses the long period constituents of the provider instead of their equilibrium terms
+func WithLongPeriodConstituents() Option {
+	return func(o *Options) {
+		o.LongPeriodConstituents = true
+	}
+}
+
+func getOptions(options []Option) Options {
+	solverOptions := Options{}
+	for _, option := range options {
+		option(&solverOptions)
+	}
+	return solverOptions
+}
+
+// converts the options to the options of the perth3 solver
+func perth3Options(options []Option) []perth3.Option {
+	solverOptions := getOptions(options)
+	perth3Options := []perth3.Option{}
+	if solverOptions.LongPeriodTerms != "" {
+		perth3Options = append(perth3Options, perth3.WithLongPeriodTerms(solverOptions.LongPeriodTerms))
+	}
+	if solverOptions.LongPeriodConstituents {
+		perth3Options = append(perth3Options, perth3.WithLongPeriodConstituents())
+	}
+	return perth3Options
+}
+
 func init() {
-	availableSolver[PERTH_3] = perth3.Solve
+	availableSolver[PERTH_3] = func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (float64, error) {
+		return perth3.Solve(provider, lat, lon, timeUtc, perth3Options(options)...)
+	}
+	availableSeriesSolver[PERTH_3] = func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]float64, error) {
+		return perth3.SolveSeries(provider, lat, lon, startUtc, endUtc, step, perth3Options(options)...)
+	}
+	availableDetailedSolver[PERTH_3] = func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (*prediction.TidePrediction, error) {
+		return perth3.SolveDetailed(provider, lat, lon, timeUtc, perth3Options(options)...)
+	}
+	availableDetailedSeriesSolver[PERTH_3] = func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]prediction.TidePrediction, error) {
+		return perth3.SolveSeriesDetailed(provider, lat, lon, startUtc, endUtc, step, perth3Options(options)...)
+	}
+	availableLocationSolver[PERTH_3] = func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, options ...Option) (func(timeUtc time.Time) float64, error) {
+		return perth3.SolveLocation(provider, lat, lon, perth3Options(options)...)
+	}
 }
 
-type CreateSolverFunc func(constituentDb *tidedatadb.TideDataDB, lat float32, lon float32, timeUtc time.Time) (float64, error)
+type CreateSolverFunc func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (float64, error)
 
 func GetSolver(solver Solver) (CreateSolverFunc, error) {
 	if availableSolver[solver] == nil {
@@ -47,3 +112,43 @@ func GetSolver(solver Solver) (CreateSolverFunc, error) {
 	}
 	return availableSolver[solver], nil
 }
+
+// solves the tide heights from startUtc to endUtc (inclusive) in steps of step
+type CreateSeriesSolverFunc func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]float64, error)
+
+func GetSeriesSolver(solver Solver) (CreateSeriesSolverFunc, error) {
+	if availableSeriesSolver[solver] == nil {
+		return nil, ErrNoSolverFound
+	}
+	return availableSeriesSolver[solver], nil
+}
+
+// solves the tide with additional information like the rate of rise/fall
+type CreateDetailedSolverFunc func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, timeUtc time.Time, options ...Option) (*prediction.TidePrediction, error)
+
+func GetDetailedSolver(solver Solver) (CreateDetailedSolverFunc, error) {
+	if availableDetailedSolver[solver] == nil {
+		return nil, ErrNoSolverFound
+	}
+	return availableDetailedSolver[solver], nil
+}
+
+// solves the tide with additional information from startUtc to endUtc (inclusive) in steps of step
+type CreateDetailedSeriesSolverFunc func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, startUtc time.Time, endUtc time.Time, step time.Duration, options ...Option) ([]prediction.TidePrediction, error)
+
+func GetDetailedSeriesSolver(solver Solver) (CreateDetailedSeriesSolverFunc, error) {
+	if availableDetailedSeriesSolver[solver] == nil {
+		return nil, ErrNoSolverFound
+	}
+	return availableDetailedSeriesSolver[solver], nil
+}
+
+// looks up the harmonic constants of a location once and returns a function solving the tide height at any time
+type CreateLocationSolverFunc func(provider constituents.HarmonicConstantsProvider, lat float32, lon float32, options ...Option) (func(timeUtc time.Time) float64, error)
+
+func GetLocationSolver(solver Solver) (CreateLocationSolverFunc, error) {
+	if availableLocationSolver[solver] == nil {
+		return nil, ErrNoSolverFound
+	}
+	return availableLocationSolver[solver], nil
+}
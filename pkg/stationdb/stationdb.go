@@ -0,0 +1,228 @@
+/*
+This package provides a point based store for the harmonic constants of tide stations,
+a station id resolves directly to its constituents without any grid interpolation.
+The stations are kept in memory and stored as json file.
+
+Concurrency: a StationDB is safe for concurrent use by multiple goroutines.
+*/
+package stationdb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+)
+
+var (
+	ErrStationNotFound     = errors.New("station not found")
+	ErrStationAlreadyInDb  = errors.New("station already in DB")
+	ErrReadOnly            = errors.New("station db is read only")
+	ErrNoStationId         = errors.New("station has no id")
+	ErrConstituentNotFound = errors.New("constituent not found at station")
+)
+
+type FileMode int
+
+const (
+	MODE_READWRITE FileMode = iota
+	MODE_READONLY
+)
+
+type Station struct {
+	ID   string
+	Name string
+	Lat  float32
+	Lon  float32
+	// harmonic constants with the amplitude in cm and the greenwich phase lag in degree
+	Constituents []constituents.ConstituentDatum
+}
+
+// returns the harmonic constants of a constituent at the station
+func (s *Station) GetConstituent(constituent constituents.Constituent) (*constituents.ConstituentDatum, error) {
+	for i := range s.Constituents {
+		if s.Constituents[i].Constituent == constituent {
+			return &s.Constituents[i], nil
+		}
+	}
+	return nil, ErrConstituentNotFound
+}
+
+// implements constituents.HarmonicConstantsProvider, the harmonic constants of the station are returned
+// for every location so a station can be used with the solvers of gridded dbs
+func (s *Station) ConstituentsAt(lat float32, lon float32, wanted ...constituents.Constituent) ([]constituents.ConstituentDatum, error) {
+	if len(wanted) == 0 {
+		return append([]constituents.ConstituentDatum{}, s.Constituents...), nil
+	}
+	datums := []constituents.ConstituentDatum{}
+	for _, constituent := range wanted {
+		if datum, err := s.GetConstituent(constituent); err == nil {
+			datums = append(datums, *datum)
+		}
+	}
+	return datums, nil
+}
+
+// loads all stations of a station file (e.g. NOAA harmonic constituents), amplitudes must be converted to cm
+type LoadStationsFunction func(filePath string) ([]Station, error)
+
+type StationDB struct {
+	filePath string
+	mode     FileMode
+	modified bool
+
+	lock     sync.RWMutex
+	stations map[string]*Station
+}
+
+// json layout of the db file, constituents are stored by name
+type stationFile struct {
+	Stations []stationEntry `json:"stations"`
+}
+
+type stationEntry struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name,omitempty"`
+	Lat          float32            `json:"lat"`
+	Lon          float32            `json:"lon"`
+	Constituents []constituentEntry `json:"constituents"`
+}
+
+type constituentEntry struct {
+	Constituent string  `json:"constituent"`
+	Amplitude   float64 `json:"amplitude"`
+	Phase       float64 `json:"phase"`
+}
+
+// opens or creates a station db, a new db is written on Close
+func OpenStationDb(filePath string, mode FileMode) (*StationDB, error) {
+	db := &StationDB{
+		filePath: filePath,
+		mode:     mode,
+		stations: make(map[string]*Station),
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil && errors.Is(err, fs.ErrNotExist) && mode != MODE_READONLY {
+		db.modified = true
+		return db, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	file := stationFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	for _, entry := range file.Stations {
+		station := &Station{
+			ID:           entry.ID,
+			Name:         entry.Name,
+			Lat:          entry.Lat,
+			Lon:          entry.Lon,
+			Constituents: make([]constituents.ConstituentDatum, 0, len(entry.Constituents)),
+		}
+		for _, constituentEntry := range entry.Constituents {
+			constituent, err := constituents.FromString(constituentEntry.Constituent)
+			if err != nil {
+				return nil, err
+			}
+			station.Constituents = append(station.Constituents, constituents.ConstituentDatum{
+				Constituent: constituent,
+				Amplitude:   constituentEntry.Amplitude,
+				Phase:       constituentEntry.Phase,
+			})
+		}
+		db.stations[station.ID] = station
+	}
+	return db, nil
+}
+
+// writes the db if it was modified
+func (s *StationDB) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.mode == MODE_READONLY || !s.modified {
+		return nil
+	}
+
+	file := stationFile{Stations: []stationEntry{}}
+	for _, id := range s.sortedIds() {
+		station := s.stations[id]
+		entry := stationEntry{
+			ID:           station.ID,
+			Name:         station.Name,
+			Lat:          station.Lat,
+			Lon:          station.Lon,
+			Constituents: make([]constituentEntry, 0, len(station.Constituents)),
+		}
+		for _, datum := range station.Constituents {
+			entry.Constituents = append(entry.Constituents, constituentEntry{
+				Constituent: datum.Constituent.String(),
+				Amplitude:   datum.Amplitude,
+				Phase:       datum.Phase,
+			})
+		}
+		file.Stations = append(file.Stations, entry)
+	}
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.filePath, content, 0666); err != nil {
+		return err
+	}
+	s.modified = false
+	return nil
+}
+
+func (s *StationDB) AddStation(station Station) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.mode == MODE_READONLY {
+		return ErrReadOnly
+	}
+	if station.ID == "" {
+		return ErrNoStationId
+	}
+	if _, ok := s.stations[station.ID]; ok {
+		return ErrStationAlreadyInDb
+	}
+	station.Constituents = append([]constituents.ConstituentDatum{}, station.Constituents...)
+	s.stations[station.ID] = &station
+	s.modified = true
+	return nil
+}
+
+// returns a copy of the station with the id
+func (s *StationDB) GetStation(id string) (*Station, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	station, ok := s.stations[id]
+	if !ok {
+		return nil, ErrStationNotFound
+	}
+	stationCopy := *station
+	stationCopy.Constituents = append([]constituents.ConstituentDatum{}, station.Constituents...)
+	return &stationCopy, nil
+}
+
+// returns the ids of all stations in the db in ascending order
+func (s *StationDB) GetStationIds() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.sortedIds()
+}
+
+func (s *StationDB) sortedIds() []string {
+	ids := make([]string, 0, len(s.stations))
+	for id := range s.stations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,86 @@
+package stationdb_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+)
+
+var testStation = stationdb.Station{
+	ID:   "9414290",
+	Name: "San Francisco",
+	Lat:  37.8063,
+	Lon:  -122.4659,
+	Constituents: []constituents.ConstituentDatum{
+		{Constituent: constituents.C_M2, Amplitude: 58, Phase: 200.1},
+		{Constituent: constituents.C_K1, Amplitude: 37, Phase: 105.9},
+	},
+}
+
+func TestStationDbRoundTrip(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "stations.json")
+
+	db, err := stationdb.OpenStationDb(dbPath, stationdb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddStation(testStation); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddStation(stationdb.Station{ID: "1", Name: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddStation(testStation); !errors.Is(err, stationdb.ErrStationAlreadyInDb) {
+		t.Fatalf("expected ErrStationAlreadyInDb, got %v", err)
+	}
+	if err := db.AddStation(stationdb.Station{}); !errors.Is(err, stationdb.ErrNoStationId) {
+		t.Fatalf("expected ErrNoStationId, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = stationdb.OpenStationDb(dbPath, stationdb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if ids := db.GetStationIds(); !reflect.DeepEqual(ids, []string{"1", "9414290"}) {
+		t.Errorf("unexpected station ids %v", ids)
+	}
+	station, err := db.GetStation("9414290")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*station, testStation) {
+		t.Errorf("expected %+v, got %+v", testStation, *station)
+	}
+	datum, err := station.GetConstituent(constituents.C_K1)
+	if err != nil || datum.Amplitude != 37 {
+		t.Errorf("expected K1 with amplitude 37, got %v %v", datum, err)
+	}
+	if _, err := station.GetConstituent(constituents.C_S2); !errors.Is(err, stationdb.ErrConstituentNotFound) {
+		t.Errorf("expected ErrConstituentNotFound, got %v", err)
+	}
+	datums, err := station.ConstituentsAt(0, 0, constituents.C_K1, constituents.C_S2)
+	if err != nil || !reflect.DeepEqual(datums, testStation.Constituents[1:]) {
+		t.Errorf("expected K1 only, got %v %v", datums, err)
+	}
+	if _, err := db.GetStation("unknown"); !errors.Is(err, stationdb.ErrStationNotFound) {
+		t.Errorf("expected ErrStationNotFound, got %v", err)
+	}
+	if err := db.AddStation(stationdb.Station{ID: "2"}); !errors.Is(err, stationdb.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestOpenMissingReadOnlyDb(t *testing.T) {
+	if _, err := stationdb.OpenStationDb(filepath.Join(t.TempDir(), "missing.json"), stationdb.MODE_READONLY); err == nil {
+		t.Fatal("expected an error for a missing read only db")
+	}
+}
@@ -0,0 +1,409 @@
+package tidedatadb_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// larger than a single chunk in both directions with partial chunks at the edges
+var multiChunkDimensions = tidedatadb.Dimensions{
+	MinLat:        -60,
+	MaxLat:        9,
+	MinLon:        0,
+	MaxLon:        129,
+	ResolutionLat: 1,
+	ResolutionLon: 1,
+	GridXSize:     130,
+	GridYSize:     70,
+}
+
+func multiChunkValue(x uint64, y uint64) []float32 {
+	return []float32{float32(x) + float32(y)/1000, float32(y)}
+}
+
+// writes all grid points of a constituent, closes the db and checks the format of the file
+func writeDb(t *testing.T, filePath string, format tidedatadb.DbFormat, constituentInfos ...tidedatadb.ConstituentInfo) {
+	t.Helper()
+	tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, constituentInfo := range constituentInfos {
+		constituentData, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, constituentInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := uint64(0); y < multiChunkDimensions.GridYSize; y++ {
+			for x := uint64(0); x < multiChunkDimensions.GridXSize; x++ {
+				if err := constituentData.WriteDataXY(multiChunkValue(x, y), x, y); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	detected, err := tidedatadb.DetectDbFormat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detected != format {
+		t.Fatalf("expected format %s, detected %s", format, detected)
+	}
+}
+
+func assertMultiChunkGrid(t *testing.T, tideDataDb *tidedatadb.TideDataDB, constituentInfo tidedatadb.ConstituentInfo) {
+	t.Helper()
+	constituentData, err := tideDataDb.GetConstituentData(constituentInfo.Constituent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if constituentData.Dimensions != multiChunkDimensions {
+		t.Errorf("expected dimensions %+v, got %+v", multiChunkDimensions, constituentData.Dimensions)
+	}
+	if constituentData.ConstituentInfo != constituentInfo {
+		t.Errorf("expected constituent info %+v, got %+v", constituentInfo, constituentData.ConstituentInfo)
+	}
+	for y := uint64(0); y < multiChunkDimensions.GridYSize; y++ {
+		for x := uint64(0); x < multiChunkDimensions.GridXSize; x++ {
+			value, err := constituentData.GetDataXY(x, y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := multiChunkValue(x, y)
+			if value[0] != expected[0] || value[1] != expected[1] {
+				t.Fatalf("value at %d,%d: expected %v, got %v", x, y, expected, value)
+			}
+		}
+	}
+}
+
+func TestBinaryBackendRoundTrip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	k1 := tidedatadb.ConstituentInfo{
+		Constituent:   constituents.C_K1,
+		AmplitudeUnit: tidedatadb.UNIT_METER,
+		PhaseUnit:     tidedatadb.UNIT_RADIAN,
+		HasFillValue:  true,
+		FillValue:     -9999,
+	}
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, m2, k1)
+
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+
+	assertMultiChunkGrid(t, tideDataDb, m2)
+	assertMultiChunkGrid(t, tideDataDb, k1)
+
+	if _, err := tideDataDb.GetConstituentData(constituents.C_S2); !errors.Is(err, tidedatadb.ErrConstituentNotFound) {
+		t.Errorf("expected ErrConstituentNotFound, got %v", err)
+	}
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := constituentData.WriteDataXY([]float32{1, 1}, 0, 0); !errors.Is(err, tidedatadb.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if _, err := constituentData.GetDataXY(multiChunkDimensions.GridXSize, 0); !errors.Is(err, tidedatadb.ErrGridIndexOutOfRange) {
+		t.Errorf("expected ErrGridIndexOutOfRange, got %v", err)
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, defaultConstituentInfo(constituents.C_S2)); !errors.Is(err, tidedatadb.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestBinaryBackendAppend(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, m2)
+
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, m2); !errors.Is(err, tidedatadb.ErrConstituentAlreadyInDb) {
+		t.Errorf("expected ErrConstituentAlreadyInDb, got %v", err)
+	}
+	// the new grid is not written, all values must be the fill value
+	s2 := defaultConstituentInfo(constituents.C_S2)
+	s2.HasFillValue = true
+	s2.FillValue = 999
+	if _, err := tideDataDb.CreateNewConstituentData(tidedatadb.Dimensions{
+		MinLat: 0, MaxLat: 1, MinLon: 0, MaxLon: 1, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 2, GridYSize: 2,
+	}, s2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+
+	assertMultiChunkGrid(t, tideDataDb, m2)
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_S2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := constituentData.GetDataXY(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 999 || value[1] != 999 {
+		t.Errorf("expected fill value, got %v", value)
+	}
+}
+
+func TestBinaryBackendIncomplete(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_BINARY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, defaultConstituentInfo(constituents.C_M2)); err != nil {
+		t.Fatal(err)
+	}
+	// the index is written on close
+	if _, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY); !errors.Is(err, tidedatadb.ErrInvalidBinaryDb) {
+		t.Errorf("expected ErrInvalidBinaryDb, got %v", err)
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// appending overwrites the index, the file is incomplete until closed again
+	tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, defaultConstituentInfo(constituents.C_S2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY); !errors.Is(err, tidedatadb.ErrInvalidBinaryDb) {
+		t.Errorf("expected ErrInvalidBinaryDb, got %v", err)
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tideDataDb.Close()
+}
+
+func TestBinaryBackendInvalidOffsets(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, defaultConstituentInfo(constituents.C_M2))
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// offsets of the index in the header and of the grid data in the first index entry
+	const indexOffset, dataOffset = 24, 52
+	index := binary.LittleEndian.Uint64(content[indexOffset:])
+
+	for name, corrupt := range map[string]func(content []byte){
+		"index behind the end of the file": func(content []byte) {
+			binary.LittleEndian.PutUint64(content[indexOffset:], uint64(len(content)))
+		},
+		"grid data behind the index": func(content []byte) {
+			binary.LittleEndian.PutUint64(content[index+dataOffset:], index-8)
+		},
+		"grid data in the header": func(content []byte) {
+			binary.LittleEndian.PutUint64(content[index+dataOffset:], 0)
+		},
+	} {
+		corrupted := append([]byte{}, content...)
+		corrupt(corrupted)
+		if err := os.WriteFile(filePath, corrupted, 0666); err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []tidedatadb.FileMode{tidedatadb.MODE_READONLY, tidedatadb.MODE_MMAP} {
+			if _, err := tidedatadb.OpenTideDataDb(filePath, mode); !errors.Is(err, tidedatadb.ErrInvalidBinaryDb) {
+				t.Errorf("%s: expected ErrInvalidBinaryDb, got %v", name, err)
+			}
+		}
+	}
+}
+
+func TestDetectDbFormat(t *testing.T) {
+	directory := t.TempDir()
+	for _, testCase := range []struct {
+		content  string
+		expected tidedatadb.DbFormat
+	}{
+		{tidedatadb.BINARY_SIGNATURE + "\x01\x00\x00\x00", tidedatadb.FORMAT_BINARY},
+		{"CDF\x01\x00\x00\x00\x00", tidedatadb.FORMAT_NETCDF},
+		{"\x89HDF\r\n\x1a\n", tidedatadb.FORMAT_NETCDF},
+	} {
+		filePath := filepath.Join(directory, "db")
+		if err := os.WriteFile(filePath, []byte(testCase.content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		format, err := tidedatadb.DetectDbFormat(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != testCase.expected {
+			t.Errorf("expected %s, got %s", testCase.expected, format)
+		}
+	}
+
+	filePath := filepath.Join(directory, "unknown")
+	if err := os.WriteFile(filePath, []byte("no db"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tidedatadb.DetectDbFormat(filePath); !errors.Is(err, tidedatadb.ErrUnknownDbFormat) {
+		t.Errorf("expected ErrUnknownDbFormat, got %v", err)
+	}
+	if _, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY); !errors.Is(err, tidedatadb.ErrUnknownDbFormat) {
+		t.Errorf("expected ErrUnknownDbFormat, got %v", err)
+	}
+}
+
+func TestLoadIntoMemory(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	k1 := defaultConstituentInfo(constituents.C_K1)
+	k1.HasFillValue = true
+	k1.FillValue = 999
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, m2, k1)
+
+	tideDataDb, err := tidedatadb.LoadTideDataDb(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	assertMultiChunkGrid(t, tideDataDb, m2)
+	assertMultiChunkGrid(t, tideDataDb, k1)
+
+	found, err := tideDataDb.GetConstituents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected M2 and K1, got %v", found)
+	}
+	if _, err := tideDataDb.GetConstituentData(constituents.C_S2); !errors.Is(err, tidedatadb.ErrConstituentNotFound) {
+		t.Errorf("expected ErrConstituentNotFound, got %v", err)
+	}
+}
+
+func TestLoadSubsetIntoMemory(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, m2)
+
+	subsetDb, err := tidedatadb.LoadTideDataDbSubset(filePath, tidedatadb.BoundingBox{MinLat: -10.5, MinLon: 100.5, MaxLat: 0, MaxLon: 110})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constituentData, err := subsetDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := tidedatadb.Dimensions{MinLat: -11, MaxLat: 0, MinLon: 100, MaxLon: 110, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 11, GridYSize: 12}
+	if constituentData.Dimensions != expected {
+		t.Fatalf("expected dimensions %+v, got %+v", expected, constituentData.Dimensions)
+	}
+	value, err := constituentData.GetDataXY(5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expectedValue := multiChunkValue(105, 54); value[0] != expectedValue[0] || value[1] != expectedValue[1] {
+		t.Errorf("expected %v, got %v", expectedValue, value)
+	}
+
+	// the settings of the db are kept
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	tideDataDb.SetInterpolationMethod(utils.INTERPOLATION_NEAREST)
+	subsetDb, err = tideDataDb.LoadSubsetIntoMemory(tidedatadb.BoundingBox{MinLat: -10.5, MinLon: 100.5, MaxLat: 0, MaxLon: 110})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nearest := multiChunkValue(105, 54)
+	assertInterpolatedLatLon(t, subsetDb, constituents.C_M2, -5.8, 104.7, float64(nearest[0]), float64(nearest[1]))
+
+	if _, err := tidedatadb.LoadTideDataDbSubset(filePath, tidedatadb.BoundingBox{MinLat: 20, MinLon: 0, MaxLat: 30, MaxLon: 10}); !errors.Is(err, tidedatadb.ErrBoundingBoxOutsideGrid) {
+		t.Errorf("expected ErrBoundingBoxOutsideGrid, got %v", err)
+	}
+}
+
+func TestMmap(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	writeDb(t, filePath, tidedatadb.FORMAT_BINARY, m2)
+
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_MMAP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMultiChunkGrid(t, tideDataDb, m2)
+
+	// bulk reads of the mapped file
+	memoryDb, err := tideDataDb.LoadIntoMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMultiChunkGrid(t, memoryDb, m2)
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := constituentData.WriteDataXY([]float32{1, 1}, 0, 0); !errors.Is(err, tidedatadb.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryTideDataDb(t *testing.T) {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	defer tideDataDb.Close()
+
+	constituentInfo := defaultConstituentInfo(constituents.C_M2)
+	constituentInfo.HasFillValue = true
+	constituentInfo.FillValue = 999
+	constituentData, err := tideDataDb.CreateNewConstituentData(tidedatadb.Dimensions{
+		MinLat: 0, MaxLat: 1, MinLon: 0, MaxLon: 1, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 2, GridYSize: 2,
+	}, constituentInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []uint64{0, 1} {
+		if err := constituentData.WriteDataXY([]float32{10, 90}, x, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the north row is land
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 0, 0.5, 10, 90)
+	if _, err := constituentData.GetDataInterpolatedLatLon(0.5, 0.5); err == nil {
+		t.Error("expected an error for undefined values")
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(constituentData.Dimensions, constituentInfo); !errors.Is(err, tidedatadb.ErrConstituentAlreadyInDb) {
+		t.Errorf("expected ErrConstituentAlreadyInDb, got %v", err)
+	}
+}
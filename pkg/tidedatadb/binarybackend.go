@@ -0,0 +1,736 @@
+package tidedatadb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sync"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+)
+
+var (
+	ErrInvalidBinaryDb     = errors.New("invalid binary tide data db")
+	ErrGridIndexOutOfRange = errors.New("grid index out of range")
+	ErrTooManyLayers       = errors.New("too many layers in nested tide data db")
+)
+
+// file signature of the binary format
+const BINARY_SIGNATURE = "PERTHTDB"
+
+const BINARY_VERSION uint32 = 1
+
+// version of nested dbs with the grids of several models (layers), see CompositeTideDataDb
+const BINARY_VERSION_NESTED uint32 = 2
+
+// version of dbs with grids with coordinates (irregular or curvilinear grids), may be nested
+const BINARY_VERSION_COORDINATES uint32 = 3
+
+// maximum number of layers of a nested db
+const MAX_LAYERS = 256
+
+// the grids are stored in square chunks of CHUNK_SIZE x CHUNK_SIZE grid points, so that the
+// neighbouring grid points needed for an interpolation are close to each other in the file
+const CHUNK_SIZE uint32 = 64
+
+// bytes of a grid point, amplitude and phase as float32
+const binaryPointSize = 8
+
+/*
+layout of the binary format, all values are little-endian
+
+	header        binaryHeader at offset 0
+	data          the grids of the constituents, each grid is a sequence of chunks (row by row from the
+	              south-west corner), each chunk holds CHUNK_SIZE rows of CHUNK_SIZE amplitude/phase pairs,
+	              chunks at the north and east edge are padded
+	index         ConstituentCount binaryIndexEntry at IndexOffset, written on Close
+	layers        LayerCount binaryLayerEntry behind the index (nested dbs only, version 2 and 3)
+	coordinates   ConstituentCount binaryCoordinateEntry behind the layers, one for each index entry (version 3).
+	              The coordinates are stored in the data section as float32 latitudes followed by the longitudes
+	              and the uint32 node indices of the triangles of meshes, grids sharing their coordinates
+	              reference the same data
+*/
+type binaryHeader struct {
+	Signature        [8]byte
+	Version          uint32
+	ChunkSize        uint32
+	ConstituentCount uint32
+	LayerCount       uint32
+	IndexOffset      uint64
+}
+
+type binaryIndexEntry struct {
+	Constituent   int32
+	AmplitudeUnit uint8
+	PhaseUnit     uint8
+	HasFillValue  uint8
+	// layer of the grid in nested dbs, 0 otherwise
+	Layer         uint8
+	FillValue     float32
+	MinLat        float32
+	MaxLat        float32
+	MinLon        float32
+	MaxLon        float32
+	ResolutionLat float32
+	ResolutionLon float32
+	GridXSize     uint64
+	GridYSize     uint64
+	DataOffset    uint64
+}
+
+type binaryLayerEntry struct {
+	Priority       int32
+	BlendWidth     float32
+	HasBoundingBox uint8
+	Reserved       [3]uint8
+	MinLat         float32
+	MinLon         float32
+	MaxLat         float32
+	MaxLon         float32
+}
+
+type binaryCoordinateEntry struct {
+	Type uint32
+	// number of triangles of meshes
+	TriangleCount uint32
+	Offset        uint64
+}
+
+// types of binaryCoordinateEntry
+const (
+	binaryCoordinatesNone uint32 = iota
+	binaryCoordinatesRectilinear
+	binaryCoordinatesCurvilinear
+	binaryCoordinatesMesh
+)
+
+// key of a grid in the index
+type binaryKey struct {
+	layer       uint8
+	constituent constituents.Constituent
+}
+
+func init() {
+	openBackend[FORMAT_BINARY] = openBinaryBackend
+	createBackend[FORMAT_BINARY] = createBinaryBackend
+}
+
+type binaryBackend struct {
+	lock      sync.RWMutex
+	file      *os.File
+	writable  bool
+	modified  bool
+	chunkSize uint64
+
+	entries map[binaryKey]binaryIndexEntry
+	// grids in the order they were added, the index is written in this order
+	order []binaryKey
+	// layers of a nested db, empty for plain dbs
+	layers []binaryLayerEntry
+	// coordinates of grids with coordinates and their offset in the file
+	coordinates       map[binaryKey]*GridCoordinates
+	coordinateOffsets map[*GridCoordinates]uint64
+	// end of the grid data, new grids are appended here
+	dataEnd uint64
+
+	// content of the file if opened with MODE_MMAP
+	mapped []byte
+	unmap  func() error
+}
+
+func createBinaryBackend(filePath string) (Backend, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	backend := &binaryBackend{
+		file:      file,
+		writable:  true,
+		modified:  true,
+		chunkSize: uint64(CHUNK_SIZE),
+		entries:   make(map[binaryKey]binaryIndexEntry),
+
+		coordinates:       make(map[binaryKey]*GridCoordinates),
+		coordinateOffsets: make(map[*GridCoordinates]uint64),
+		dataEnd:           uint64(binary.Size(binaryHeader{})),
+	}
+	// the index offset is set on close, until then the file is detected as incomplete
+	if err := backend.writeHeader(0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+func openBinaryBackend(filePath string, mode FileMode) (Backend, error) {
+	flag := os.O_RDONLY
+	if mode == MODE_READWRITE {
+		flag = os.O_RDWR
+	}
+	file, err := os.OpenFile(filePath, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	backend := &binaryBackend{
+		file:     file,
+		writable: mode == MODE_READWRITE,
+		entries:  make(map[binaryKey]binaryIndexEntry),
+
+		coordinates:       make(map[binaryKey]*GridCoordinates),
+		coordinateOffsets: make(map[*GridCoordinates]uint64),
+	}
+	if err := backend.readIndex(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if mode == MODE_MMAP {
+		backend.mapped, backend.unmap, err = mmapFile(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return backend, nil
+}
+
+func (b *binaryBackend) readIndex() error {
+	header := binaryHeader{}
+	if err := binary.Read(io.NewSectionReader(b.file, 0, int64(binary.Size(header))), binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+	}
+	if string(header.Signature[:]) != BINARY_SIGNATURE {
+		return ErrInvalidBinaryDb
+	}
+	if header.Version != BINARY_VERSION && header.Version != BINARY_VERSION_NESTED && header.Version != BINARY_VERSION_COORDINATES {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidBinaryDb, header.Version)
+	}
+	if header.ChunkSize == 0 || header.IndexOffset == 0 {
+		return fmt.Errorf("%w: incomplete file", ErrInvalidBinaryDb)
+	}
+	b.chunkSize = uint64(header.ChunkSize)
+	b.dataEnd = header.IndexOffset
+
+	// the offsets are checked against the file size, grids of the memory mapped file are read without checks
+	info, err := b.file.Stat()
+	if err != nil {
+		return err
+	}
+	indexSize := int64(header.ConstituentCount) * int64(binary.Size(binaryIndexEntry{}))
+	if header.IndexOffset < uint64(binary.Size(header)) || header.IndexOffset > uint64(info.Size()) || int64(header.IndexOffset)+indexSize > info.Size() {
+		return fmt.Errorf("%w: index outside of the file", ErrInvalidBinaryDb)
+	}
+	if header.LayerCount > MAX_LAYERS {
+		return fmt.Errorf("%w: %d layers", ErrInvalidBinaryDb, header.LayerCount)
+	}
+
+	entries := make([]binaryIndexEntry, header.ConstituentCount)
+	if err := binary.Read(io.NewSectionReader(b.file, int64(header.IndexOffset), indexSize), binary.LittleEndian, entries); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+	}
+	for _, entry := range entries {
+		if !b.inDataSection(entry.DataOffset, b.gridDataSize(entry.GridXSize, entry.GridYSize)) {
+			return fmt.Errorf("%w: grid of %s outside of the data section", ErrInvalidBinaryDb, constituents.Constituent(entry.Constituent))
+		}
+		key := binaryKey{layer: entry.Layer, constituent: constituents.Constituent(entry.Constituent)}
+		b.entries[key] = entry
+		b.order = append(b.order, key)
+	}
+
+	if header.Version == BINARY_VERSION {
+		return nil
+	}
+	offset := int64(header.IndexOffset) + indexSize
+	if header.LayerCount > 0 {
+		b.layers = make([]binaryLayerEntry, header.LayerCount)
+		layerSize := int64(binary.Size(b.layers))
+		if err := binary.Read(io.NewSectionReader(b.file, offset, layerSize), binary.LittleEndian, b.layers); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+		}
+		offset = offset + layerSize
+	}
+	if header.Version == BINARY_VERSION_COORDINATES {
+		return b.readCoordinates(entries, offset)
+	}
+	return nil
+}
+
+// returns the bytes of the chunks of a grid, math.MaxUint64 if the size overflows
+func (b *binaryBackend) gridDataSize(sizeX uint64, sizeY uint64) uint64 {
+	size := uint64(binaryPointSize)
+	for _, factor := range []uint64{b.chunkSize, b.chunkSize, b.chunksAlong(sizeX), b.chunksAlong(sizeY)} {
+		high, low := bits.Mul64(size, factor)
+		if high != 0 {
+			return math.MaxUint64
+		}
+		size = low
+	}
+	return size
+}
+
+// checks that size bytes at offset are between the header and the index
+func (b *binaryBackend) inDataSection(offset uint64, size uint64) bool {
+	return offset >= uint64(binary.Size(binaryHeader{})) && offset <= b.dataEnd && size <= b.dataEnd-offset
+}
+
+// reads the coordinate table at offset and the coordinates of the index entries
+func (b *binaryBackend) readCoordinates(entries []binaryIndexEntry, offset int64) error {
+	coordinateEntries := make([]binaryCoordinateEntry, len(entries))
+	if err := binary.Read(io.NewSectionReader(b.file, offset, int64(binary.Size(coordinateEntries))), binary.LittleEndian, coordinateEntries); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+	}
+	loaded := make(map[uint64]*GridCoordinates)
+	for i, coordinateEntry := range coordinateEntries {
+		if coordinateEntry.Type == binaryCoordinatesNone {
+			continue
+		}
+		coordinates, ok := loaded[coordinateEntry.Offset]
+		if !ok {
+			coordinates = &GridCoordinates{Curvilinear: coordinateEntry.Type == binaryCoordinatesCurvilinear || coordinateEntry.Type == binaryCoordinatesMesh}
+			latitudeCount, longitudeCount := coordinateCounts(coordinates.Curvilinear, entries[i].GridXSize, entries[i].GridYSize)
+			// the counts are bounded by the checked grid sizes
+			if !b.inDataSection(coordinateEntry.Offset, (latitudeCount+longitudeCount+3*uint64(coordinateEntry.TriangleCount))*4) {
+				return fmt.Errorf("%w: coordinates of %s outside of the data section", ErrInvalidBinaryDb, constituents.Constituent(entries[i].Constituent))
+			}
+			coordinates.Latitudes = make([]float32, latitudeCount)
+			coordinates.Longitudes = make([]float32, longitudeCount)
+			section := io.NewSectionReader(b.file, int64(coordinateEntry.Offset), int64(latitudeCount+longitudeCount)*4)
+			if err := binary.Read(section, binary.LittleEndian, coordinates.Latitudes); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+			}
+			if err := binary.Read(section, binary.LittleEndian, coordinates.Longitudes); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+			}
+			if coordinateEntry.Type == binaryCoordinatesMesh {
+				coordinates.Triangles = make([]uint32, 3*uint64(coordinateEntry.TriangleCount))
+				offset := int64(coordinateEntry.Offset + (latitudeCount+longitudeCount)*4)
+				section := io.NewSectionReader(b.file, offset, int64(len(coordinates.Triangles))*4)
+				if err := binary.Read(section, binary.LittleEndian, coordinates.Triangles); err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidBinaryDb, err)
+				}
+			}
+			loaded[coordinateEntry.Offset] = coordinates
+			b.coordinateOffsets[coordinates] = coordinateEntry.Offset
+		}
+		b.coordinates[binaryKey{layer: entries[i].Layer, constituent: constituents.Constituent(entries[i].Constituent)}] = coordinates
+	}
+	return nil
+}
+
+// returns the number of latitudes and longitudes of the coordinates of a grid
+func coordinateCounts(curvilinear bool, sizeX uint64, sizeY uint64) (uint64, uint64) {
+	if curvilinear {
+		return sizeX * sizeY, sizeX * sizeY
+	}
+	return sizeY, sizeX
+}
+
+func (b *binaryBackend) writeHeader(indexOffset uint64) error {
+	header := binaryHeader{
+		Version:          BINARY_VERSION,
+		ChunkSize:        uint32(b.chunkSize),
+		ConstituentCount: uint32(len(b.order)),
+		LayerCount:       uint32(len(b.layers)),
+		IndexOffset:      indexOffset,
+	}
+	// dbs stay readable by readers without support for nested dbs or coordinates if they don't use them
+	if len(b.coordinates) > 0 {
+		header.Version = BINARY_VERSION_COORDINATES
+	} else if len(b.layers) > 0 {
+		header.Version = BINARY_VERSION_NESTED
+	}
+	copy(header.Signature[:], BINARY_SIGNATURE)
+	return b.writeAt(header, 0)
+}
+
+func (b *binaryBackend) writeAt(data any, offset uint64) error {
+	buffer := bytes.Buffer{}
+	if err := binary.Write(&buffer, binary.LittleEndian, data); err != nil {
+		return err
+	}
+	_, err := b.file.WriteAt(buffer.Bytes(), int64(offset))
+	return err
+}
+
+// writes the index and the layers behind the grid data and the header
+func (b *binaryBackend) writeIndex() error {
+	entries := make([]binaryIndexEntry, 0, len(b.order))
+	for _, key := range b.order {
+		entries = append(entries, b.entries[key])
+	}
+	if err := b.writeAt(entries, b.dataEnd); err != nil {
+		return err
+	}
+	end := b.dataEnd + uint64(binary.Size(entries))
+	if len(b.layers) > 0 {
+		if err := b.writeAt(b.layers, end); err != nil {
+			return err
+		}
+		end = end + uint64(binary.Size(b.layers))
+	}
+	if len(b.coordinates) > 0 {
+		coordinateEntries := make([]binaryCoordinateEntry, 0, len(b.order))
+		for _, key := range b.order {
+			coordinateEntry := binaryCoordinateEntry{}
+			if coordinates, ok := b.coordinates[key]; ok {
+				coordinateEntry.Type = binaryCoordinatesRectilinear
+				if coordinates.Triangles != nil {
+					coordinateEntry.Type = binaryCoordinatesMesh
+					coordinateEntry.TriangleCount = uint32(len(coordinates.Triangles) / 3)
+				} else if coordinates.Curvilinear {
+					coordinateEntry.Type = binaryCoordinatesCurvilinear
+				}
+				coordinateEntry.Offset = b.coordinateOffsets[coordinates]
+			}
+			coordinateEntries = append(coordinateEntries, coordinateEntry)
+		}
+		if err := b.writeAt(coordinateEntries, end); err != nil {
+			return err
+		}
+		end = end + uint64(binary.Size(coordinateEntries))
+	}
+	if err := b.file.Truncate(int64(end)); err != nil {
+		return err
+	}
+	return b.writeHeader(b.dataEnd)
+}
+
+// marks the db as modified, new grids overwrite the index of an opened db, so the index offset
+// is cleared until the new index is written on close
+func (b *binaryBackend) setModified() error {
+	if !b.modified {
+		if err := b.writeHeader(0); err != nil {
+			return err
+		}
+		b.modified = true
+	}
+	return nil
+}
+
+func (b *binaryBackend) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.writable && b.modified {
+		if err := b.writeIndex(); err != nil {
+			b.file.Close()
+			return err
+		}
+		b.modified = false
+	}
+	if b.unmap != nil {
+		if err := b.unmap(); err != nil {
+			b.file.Close()
+			return err
+		}
+		b.mapped = nil
+		b.unmap = nil
+	}
+	return b.file.Close()
+}
+
+// returns the grids of the base layer of nested dbs
+func (b *binaryBackend) GetConstituentGrid(constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error) {
+	return b.getLayerGrid(0, constituent)
+}
+
+func (b *binaryBackend) getLayerGrid(layer uint8, constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	entry, ok := b.entries[binaryKey{layer: layer, constituent: constituent}]
+	if !ok {
+		return Dimensions{}, ConstituentInfo{}, nil, ErrConstituentNotFound
+	}
+	return Dimensions{
+		MinLat:        entry.MinLat,
+		MaxLat:        entry.MaxLat,
+		MinLon:        entry.MinLon,
+		MaxLon:        entry.MaxLon,
+		ResolutionLat: entry.ResolutionLat,
+		ResolutionLon: entry.ResolutionLon,
+		GridXSize:     entry.GridXSize,
+		GridYSize:     entry.GridYSize,
+		Coordinates:   b.coordinates[binaryKey{layer: layer, constituent: constituent}],
+	}, ConstituentInfo{
+		Constituent:   constituent,
+		AmplitudeUnit: ConstituentAmplitudeUnit(entry.AmplitudeUnit),
+		PhaseUnit:     ConstituentPhaseUnit(entry.PhaseUnit),
+		HasFillValue:  entry.HasFillValue != 0,
+		FillValue:     entry.FillValue,
+	}, b.newGrid(entry), nil
+}
+
+// appends a new grid to the base layer, all grid points are initialized with the fill value (or 0 without fill value)
+func (b *binaryBackend) CreateConstituentGrid(dimensions Dimensions, constituentInfo ConstituentInfo) (Grid, error) {
+	return b.createLayerGrid(0, dimensions, constituentInfo)
+}
+
+func (b *binaryBackend) createLayerGrid(layer uint8, dimensions Dimensions, constituentInfo ConstituentInfo) (Grid, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.writable {
+		return nil, ErrReadOnly
+	}
+	key := binaryKey{layer: layer, constituent: constituentInfo.Constituent}
+	if _, ok := b.entries[key]; ok {
+		return nil, ErrConstituentAlreadyInDb
+	}
+	if dimensions.GridXSize == 0 || dimensions.GridYSize == 0 {
+		return nil, fmt.Errorf("%w: empty grid", ErrGridIndexOutOfRange)
+	}
+	if coordinates := dimensions.Coordinates; coordinates != nil {
+		latitudeCount, longitudeCount := coordinateCounts(coordinates.Curvilinear, dimensions.GridXSize, dimensions.GridYSize)
+		if uint64(len(coordinates.Latitudes)) != latitudeCount || uint64(len(coordinates.Longitudes)) != longitudeCount ||
+			len(coordinates.Triangles)%3 != 0 || uint64(len(coordinates.Triangles)/3) > math.MaxUint32 {
+			return nil, ErrInvalidCoordinates
+		}
+	}
+
+	entry := binaryIndexEntry{
+		Constituent:   int32(constituentInfo.Constituent),
+		AmplitudeUnit: uint8(constituentInfo.AmplitudeUnit),
+		PhaseUnit:     uint8(constituentInfo.PhaseUnit),
+		Layer:         layer,
+		FillValue:     constituentInfo.FillValue,
+		MinLat:        dimensions.MinLat,
+		MaxLat:        dimensions.MaxLat,
+		MinLon:        dimensions.MinLon,
+		MaxLon:        dimensions.MaxLon,
+		ResolutionLat: dimensions.ResolutionLat,
+		ResolutionLon: dimensions.ResolutionLon,
+		GridXSize:     dimensions.GridXSize,
+		GridYSize:     dimensions.GridYSize,
+		DataOffset:    b.dataEnd,
+	}
+	if constituentInfo.HasFillValue {
+		entry.HasFillValue = 1
+	}
+	if err := b.setModified(); err != nil {
+		return nil, err
+	}
+
+	// initialize the grid chunk by chunk
+	chunk := make([]byte, b.chunkSize*b.chunkSize*binaryPointSize)
+	initialValue := float32(0)
+	if constituentInfo.HasFillValue {
+		initialValue = constituentInfo.FillValue
+	}
+	for i := 0; i < len(chunk); i = i + 4 {
+		binary.LittleEndian.PutUint32(chunk[i:], math.Float32bits(initialValue))
+	}
+	numberChunks := b.chunksAlong(dimensions.GridXSize) * b.chunksAlong(dimensions.GridYSize)
+	for i := uint64(0); i < numberChunks; i++ {
+		if _, err := b.file.WriteAt(chunk, int64(b.dataEnd+i*uint64(len(chunk)))); err != nil {
+			return nil, err
+		}
+	}
+
+	b.entries[key] = entry
+	b.order = append(b.order, key)
+	b.dataEnd = b.dataEnd + numberChunks*uint64(len(chunk))
+
+	if coordinates := dimensions.Coordinates; coordinates != nil {
+		// coordinates shared with other grids are only written once
+		if _, ok := b.coordinateOffsets[coordinates]; !ok {
+			if err := b.writeAt(append(append([]float32{}, coordinates.Latitudes...), coordinates.Longitudes...), b.dataEnd); err != nil {
+				return nil, err
+			}
+			b.coordinateOffsets[coordinates] = b.dataEnd
+			b.dataEnd = b.dataEnd + uint64(len(coordinates.Latitudes)+len(coordinates.Longitudes))*4
+			if coordinates.Triangles != nil {
+				if err := b.writeAt(coordinates.Triangles, b.dataEnd); err != nil {
+					return nil, err
+				}
+				b.dataEnd = b.dataEnd + uint64(len(coordinates.Triangles))*4
+			}
+		}
+		b.coordinates[key] = coordinates
+	}
+	return b.newGrid(entry), nil
+}
+
+// returns the layers of a nested db, nil for plain dbs
+func (b *binaryBackend) getLayers() []LayerInfo {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if len(b.layers) == 0 {
+		return nil
+	}
+	layers := make([]LayerInfo, 0, len(b.layers))
+	for _, entry := range b.layers {
+		layer := LayerInfo{Priority: int(entry.Priority), BlendWidth: entry.BlendWidth}
+		if entry.HasBoundingBox != 0 {
+			layer.BoundingBox = &BoundingBox{MinLat: entry.MinLat, MinLon: entry.MinLon, MaxLat: entry.MaxLat, MaxLon: entry.MaxLon}
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// returns the grids of a layer as backend, the first layer are the grids of the db itself
+func (b *binaryBackend) getLayerBackend(layer int) Backend {
+	if layer == 0 {
+		return b
+	}
+	return &binaryLayerBackend{backend: b, layer: uint8(layer)}
+}
+
+// adds a new layer, the db becomes a nested db
+func (b *binaryBackend) addLayer(layer LayerInfo) (Backend, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.writable {
+		return nil, ErrReadOnly
+	}
+	if len(b.layers) >= MAX_LAYERS {
+		return nil, ErrTooManyLayers
+	}
+	if err := b.setModified(); err != nil {
+		return nil, err
+	}
+	entry := binaryLayerEntry{Priority: int32(layer.Priority), BlendWidth: layer.BlendWidth}
+	if layer.BoundingBox != nil {
+		entry.HasBoundingBox = 1
+		entry.MinLat = layer.BoundingBox.MinLat
+		entry.MinLon = layer.BoundingBox.MinLon
+		entry.MaxLat = layer.BoundingBox.MaxLat
+		entry.MaxLon = layer.BoundingBox.MaxLon
+	}
+	b.layers = append(b.layers, entry)
+	if len(b.layers) == 1 {
+		return b, nil
+	}
+	return &binaryLayerBackend{backend: b, layer: uint8(len(b.layers) - 1)}, nil
+}
+
+func (b *binaryBackend) chunksAlong(size uint64) uint64 {
+	return (size + b.chunkSize - 1) / b.chunkSize
+}
+
+func (b *binaryBackend) newGrid(entry binaryIndexEntry) *binaryGrid {
+	return &binaryGrid{
+		file:      b.file,
+		mapped:    b.mapped,
+		writable:  b.writable,
+		chunkSize: b.chunkSize,
+		chunksX:   b.chunksAlong(entry.GridXSize),
+		sizeX:     entry.GridXSize,
+		sizeY:     entry.GridYSize,
+		offset:    entry.DataOffset,
+	}
+}
+
+// grids of a layer of a nested db, the file is closed by the backend of the base layer
+type binaryLayerBackend struct {
+	backend *binaryBackend
+	layer   uint8
+}
+
+func (b *binaryLayerBackend) Close() error {
+	return nil
+}
+
+func (b *binaryLayerBackend) GetConstituentGrid(constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error) {
+	return b.backend.getLayerGrid(b.layer, constituent)
+}
+
+func (b *binaryLayerBackend) CreateConstituentGrid(dimensions Dimensions, constituentInfo ConstituentInfo) (Grid, error) {
+	return b.backend.createLayerGrid(b.layer, dimensions, constituentInfo)
+}
+
+// grid of a constituent in the binary format, reads and writes go directly to the file
+// (ReadAt/WriteAt), which is safe for concurrent use, or to the memory mapped file
+type binaryGrid struct {
+	file      *os.File
+	mapped    []byte
+	writable  bool
+	chunkSize uint64
+	chunksX   uint64
+	sizeX     uint64
+	sizeY     uint64
+	offset    uint64
+}
+
+// returns the file offset of the grid point
+func (b *binaryGrid) pointOffset(x uint64, y uint64) (int64, error) {
+	if x >= b.sizeX || y >= b.sizeY {
+		return 0, fmt.Errorf("%w: %d,%d", ErrGridIndexOutOfRange, x, y)
+	}
+	return int64(b.offset + b.gridOffset(x, y)), nil
+}
+
+// returns the offset of the grid point relative to the start of the grid
+func (b *binaryGrid) gridOffset(x uint64, y uint64) uint64 {
+	chunk := (y/b.chunkSize)*b.chunksX + x/b.chunkSize
+	point := chunk*b.chunkSize*b.chunkSize + (y%b.chunkSize)*b.chunkSize + x%b.chunkSize
+	return point * binaryPointSize
+}
+
+func (b *binaryGrid) ReadXY(x uint64, y uint64) ([]float32, error) {
+	offset, err := b.pointOffset(x, y)
+	if err != nil {
+		return nil, err
+	}
+	var buffer []byte
+	if b.mapped != nil {
+		buffer = b.mapped[offset : offset+binaryPointSize]
+	} else {
+		buffer = make([]byte, binaryPointSize)
+		if _, err := b.file.ReadAt(buffer, offset); err != nil {
+			return nil, err
+		}
+	}
+	return []float32{
+		math.Float32frombits(binary.LittleEndian.Uint32(buffer[0:4])),
+		math.Float32frombits(binary.LittleEndian.Uint32(buffer[4:8])),
+	}, nil
+}
+
+func (b *binaryGrid) WriteXY(amplitudePhase []float32, x uint64, y uint64) error {
+	if !b.writable {
+		return ErrReadOnly
+	}
+	offset, err := b.pointOffset(x, y)
+	if err != nil {
+		return err
+	}
+	buffer := make([]byte, binaryPointSize)
+	binary.LittleEndian.PutUint32(buffer[0:4], math.Float32bits(amplitudePhase[0]))
+	binary.LittleEndian.PutUint32(buffer[4:8], math.Float32bits(amplitudePhase[1]))
+	_, err = b.file.WriteAt(buffer, offset)
+	return err
+}
+
+// reads all chunks of the grid at once and reorders them row by row
+func (b *binaryGrid) ReadAll() ([]float32, error) {
+	chunksY := (b.sizeY + b.chunkSize - 1) / b.chunkSize
+	size := b.chunksX * chunksY * b.chunkSize * b.chunkSize * binaryPointSize
+	var buffer []byte
+	if b.mapped != nil {
+		buffer = b.mapped[b.offset : b.offset+size]
+	} else {
+		buffer = make([]byte, size)
+		if _, err := b.file.ReadAt(buffer, int64(b.offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	data := make([]float32, b.sizeX*b.sizeY*2)
+	for y := uint64(0); y < b.sizeY; y++ {
+		for x := uint64(0); x < b.sizeX; x++ {
+			point := b.gridOffset(x, y)
+			index := (y*b.sizeX + x) * 2
+			data[index] = math.Float32frombits(binary.LittleEndian.Uint32(buffer[point : point+4]))
+			data[index+1] = math.Float32frombits(binary.LittleEndian.Uint32(buffer[point+4 : point+8]))
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,241 @@
+package tidedatadb
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+var (
+	ErrNoLayers             = errors.New("composite tide data db needs at least one layer")
+	ErrNestedDbNotSupported = errors.New("nested tide data dbs are only supported in the binary format")
+)
+
+// placement of a layer in a composite db
+type LayerInfo struct {
+	// layers with a higher priority are used first, the layer with the lowest priority is the base (e.g. a global model)
+	Priority int
+	// area of the layer, the extent of the grids if nil
+	BoundingBox *BoundingBox
+	// width in degree of the border of the layer in which it is blended with the layers below, 0 replaces
+	// the layers below up to the border
+	BlendWidth float32
+}
+
+type Layer struct {
+	LayerInfo
+	Db *TideDataDB
+}
+
+// implemented by backends which store the grids of several layers (nested dbs)
+type layeredBackend interface {
+	getLayers() []LayerInfo
+	getLayerBackend(layer int) Backend
+	addLayer(layer LayerInfo) (Backend, error)
+}
+
+// combines several dbs into one source of harmonic constants, e.g. high resolution regional models
+// nested in a global model. For each constituent the layers are used by priority, a layer only
+// contributes within its bounding box and where it has data (e.g. a regional model without data on
+// land falls back to the global model). Within the blend width of its border a layer is feathered
+// linearly into the layers below it, so there is no step at the seam.
+// A composite db is safe for concurrent use like its layers.
+type CompositeTideDataDb struct {
+	layers []Layer
+}
+
+// creates a composite db, layers with the same priority are used in the given order
+func NewCompositeTideDataDb(layers ...Layer) (*CompositeTideDataDb, error) {
+	if len(layers) == 0 {
+		return nil, ErrNoLayers
+	}
+	sorted := append([]Layer{}, layers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+	return &CompositeTideDataDb{layers: sorted}, nil
+}
+
+// opens a nested db written with WriteNestedTideDataDb, a plain db is opened as composite with a single layer.
+// OpenTideDataDb only returns the base layer of a nested db
+func OpenCompositeTideDataDb(filePath string, mode FileMode) (*CompositeTideDataDb, error) {
+	if mode == MODE_READWRITE {
+		return nil, ErrReadOnly
+	}
+	tideDataDb, err := OpenTideDataDb(filePath, mode)
+	if err != nil {
+		return nil, err
+	}
+	nested, ok := tideDataDb.backend.(layeredBackend)
+	if !ok || len(nested.getLayers()) == 0 {
+		return NewCompositeTideDataDb(Layer{Db: tideDataDb})
+	}
+	layers := []Layer{}
+	for i, layerInfo := range nested.getLayers() {
+		layers = append(layers, Layer{LayerInfo: layerInfo, Db: NewTideDataDb(nested.getLayerBackend(i))})
+	}
+	return NewCompositeTideDataDb(layers...)
+}
+
+// writes all layers into a single nested db in the binary format, the grids of a layer with a bounding box
+// are reduced to the bounding box
+func WriteNestedTideDataDb(filePath string, layers ...Layer) error {
+	if len(layers) == 0 {
+		return ErrNoLayers
+	}
+	tideDataDb, err := CreateTideDataDb(filePath, FORMAT_BINARY)
+	if err != nil {
+		return err
+	}
+	nested := tideDataDb.backend.(layeredBackend)
+	for _, layer := range layers {
+		layerBackend, err := nested.addLayer(layer.LayerInfo)
+		if err != nil {
+			tideDataDb.Close()
+			return err
+		}
+		if layer.BoundingBox != nil {
+			err = layer.Db.CopySubset(NewTideDataDb(layerBackend), *layer.BoundingBox)
+		} else {
+			err = layer.Db.CopyTo(NewTideDataDb(layerBackend))
+		}
+		if err != nil {
+			tideDataDb.Close()
+			return err
+		}
+	}
+	return tideDataDb.Close()
+}
+
+// returns the layers ordered by priority, the first layer has the highest priority
+func (c *CompositeTideDataDb) Layers() []Layer {
+	return append([]Layer{}, c.layers...)
+}
+
+// sets the nearest ocean search radius of all layers, see TideDataDB.SetNearestOceanSearchRadius
+func (c *CompositeTideDataDb) SetNearestOceanSearchRadius(radius float32) {
+	for _, layer := range c.layers {
+		layer.Db.SetNearestOceanSearchRadius(radius)
+	}
+}
+
+// sets the interpolation method of all layers, see TideDataDB.SetInterpolationMethod
+func (c *CompositeTideDataDb) SetInterpolationMethod(method utils.InterpolationMethod) {
+	for _, layer := range c.layers {
+		layer.Db.SetInterpolationMethod(method)
+	}
+}
+
+// closes the dbs of all layers
+func (c *CompositeTideDataDb) Close() error {
+	var closeErr error
+	for _, layer := range c.layers {
+		if err := layer.Db.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+// implements constituents.HarmonicConstantsProvider, returns the blended harmonic constants of the
+// wanted constituents in any layer at lat/lon. Constituents without a layer at the location are omitted
+func (c *CompositeTideDataDb) ConstituentsAt(lat float32, lon float32, wanted ...constituents.Constituent) ([]constituents.ConstituentDatum, error) {
+	datums := []constituents.ConstituentDatum{}
+	for _, constituent := range constituents.LookupConstituents(wanted) {
+		datum, err := c.constituentAt(constituent, lat, lon)
+		if err != nil {
+			return nil, err
+		}
+		if datum != nil {
+			datums = append(datums, *datum)
+		}
+	}
+	return datums, nil
+}
+
+// blends the constituent of all layers at lat/lon, each layer takes its weight of what is left by
+// the layers above, the last layer with data takes the rest
+func (c *CompositeTideDataDb) constituentAt(constituent constituents.Constituent, lat float32, lon float32) (*constituents.ConstituentDatum, error) {
+	var hCos, hSin float64
+	var last *constituents.ConstituentDatum
+	var lastErr error
+	remaining := 1.0
+	for _, layer := range c.layers {
+		constituentData, err := layer.Db.GetConstituentData(constituent)
+		if err != nil && errors.Is(err, ErrConstituentNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		weight, ok := layer.weightAt(constituentData.Dimensions, lat, lon)
+		if !ok {
+			continue
+		}
+		datum, err := constituentData.GetDataInterpolatedLatLon(lat, lon)
+		if err != nil && (errors.Is(err, ErrNoOceanData) || errors.Is(err, ErrGridIndexOutOfRange) || errors.Is(err, utils.ErrOutOfGrid)) {
+			lastErr = err
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		hCos = hCos + remaining*weight*datum.GetHCos()
+		hSin = hSin + remaining*weight*datum.GetHSin()
+		remaining = remaining - remaining*weight
+		last = datum
+		if remaining <= 0 {
+			break
+		}
+	}
+	if last == nil {
+		// no data in the layers covering the location
+		return nil, lastErr
+	}
+	if remaining > 0 {
+		hCos = hCos + remaining*last.GetHCos()
+		hSin = hSin + remaining*last.GetHSin()
+	}
+
+	phase := math.Atan2(hSin, hCos) * (180 / math.Pi)
+	if phase < 0 {
+		phase = phase + 360
+	}
+	return &constituents.ConstituentDatum{
+		Constituent: constituent,
+		Amplitude:   math.Hypot(hCos, hSin),
+		Phase:       phase,
+	}, nil
+}
+
+// returns the weight of the layer at lat/lon, 1 inside the layer and falling linearly to 0 within the
+// blend width of the border, false if the location is outside the layer
+func (l LayerInfo) weightAt(dimensions Dimensions, lat float32, lon float32) (float64, bool) {
+	// distance in degree to the nearest border of the grid and the bounding box, global grids
+	// have no border (the interpolation wraps around the globe and over the poles)
+	distance := math.Inf(1)
+	if !dimensions.IsGlobal() {
+		offset := float64(dimensions.NormalizeLongitude(lon) - dimensions.MinLon)
+		distance = math.Min(float64(lat-dimensions.MinLat), float64(dimensions.MaxLat-lat))
+		distance = math.Min(distance, math.Min(offset, float64(dimensions.MaxLon-dimensions.MinLon)-offset))
+	}
+	if l.BoundingBox != nil {
+		box := l.BoundingBox
+		distance = math.Min(distance, math.Min(float64(lat-box.MinLat), float64(box.MaxLat-lat)))
+		if span := float64(box.LonSpan()); span < 360 {
+			offset := math.Mod(float64(lon-box.MinLon), 360)
+			if offset < 0 {
+				offset = offset + 360
+			}
+			distance = math.Min(distance, math.Min(offset, span-offset))
+		}
+	}
+	if distance < 0 {
+		return 0, false
+	}
+	if l.BlendWidth <= 0 {
+		return 1, true
+	}
+	return math.Min(distance/float64(l.BlendWidth), 1), true
+}
@@ -0,0 +1,219 @@
+package tidedatadb_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+)
+
+var _ constituents.HarmonicConstantsProvider = &tidedatadb.CompositeTideDataDb{}
+
+var globalDimensions = tidedatadb.Dimensions{
+	MinLat: -90, MaxLat: 90, MinLon: 0, MaxLon: 350, ResolutionLat: 10, ResolutionLon: 10, GridXSize: 36, GridYSize: 19,
+}
+
+// north sea like region, lat 50..60 and lon 0..10
+var regionalDimensions = tidedatadb.Dimensions{
+	MinLat: 50, MaxLat: 60, MinLon: 0, MaxLon: 10, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 11, GridYSize: 11,
+}
+
+// creates a db with the same amplitude and phase 0 at all grid points of all constituents,
+// grid points with x < landX are land
+func createConstantDb(t *testing.T, dimensions tidedatadb.Dimensions, amplitude float32, landX uint64, constituentList ...constituents.Constituent) *tidedatadb.TideDataDB {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	for _, constituent := range constituentList {
+		constituentInfo := defaultConstituentInfo(constituent)
+		constituentInfo.HasFillValue = true
+		constituentInfo.FillValue = -1
+		constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, constituentInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := uint64(0); y < dimensions.GridYSize; y++ {
+			for x := landX; x < dimensions.GridXSize; x++ {
+				if err := constituentData.WriteDataXY([]float32{amplitude, 0}, x, y); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	return tideDataDb
+}
+
+func assertAmplitudeAt(t *testing.T, provider constituents.HarmonicConstantsProvider, constituent constituents.Constituent, lat float32, lon float32, expected float64) {
+	t.Helper()
+	datums, err := provider.ConstituentsAt(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, datum := range datums {
+		if datum.Constituent == constituent {
+			if math.Abs(datum.Amplitude-expected) > 1e-3 || math.Abs(math.Mod(datum.Phase+180, 360)-180) > 1e-3 {
+				t.Errorf("%s at %f,%f: expected amplitude %f and phase 0, got %f and %f", constituent, lat, lon, expected, datum.Amplitude, datum.Phase)
+			}
+			return
+		}
+	}
+	t.Errorf("%s at %f,%f: constituent missing", constituent, lat, lon)
+}
+
+func TestCompositePriority(t *testing.T) {
+	global := createConstantDb(t, globalDimensions, 50, 0, constituents.C_M2, constituents.C_K1)
+	regional := createConstantDb(t, regionalDimensions, 100, 0, constituents.C_M2)
+	composite, err := tidedatadb.NewCompositeTideDataDb(
+		tidedatadb.Layer{Db: global},
+		tidedatadb.Layer{LayerInfo: tidedatadb.LayerInfo{Priority: 1}, Db: regional},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer composite.Close()
+	if composite.Layers()[0].Db != regional {
+		t.Errorf("expected the regional layer first")
+	}
+
+	assertAmplitudeAt(t, composite, constituents.C_M2, 55, 5, 100)
+	// outside of the region
+	assertAmplitudeAt(t, composite, constituents.C_M2, 40, 5, 50)
+	assertAmplitudeAt(t, composite, constituents.C_M2, 55, 15, 50)
+	// constituents missing in the regional model are taken from the global model
+	assertAmplitudeAt(t, composite, constituents.C_K1, 55, 5, 50)
+
+	if _, err := tidedatadb.NewCompositeTideDataDb(); !errors.Is(err, tidedatadb.ErrNoLayers) {
+		t.Errorf("expected ErrNoLayers, got %v", err)
+	}
+}
+
+func TestCompositeBlending(t *testing.T) {
+	global := createConstantDb(t, globalDimensions, 50, 0, constituents.C_M2)
+	regional := createConstantDb(t, regionalDimensions, 100, 0, constituents.C_M2)
+	composite, err := tidedatadb.NewCompositeTideDataDb(
+		tidedatadb.Layer{LayerInfo: tidedatadb.LayerInfo{Priority: 1, BlendWidth: 2}, Db: regional},
+		tidedatadb.Layer{Db: global},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAmplitudeAt(t, composite, constituents.C_M2, 55, 5, 100)
+	assertAmplitudeAt(t, composite, constituents.C_M2, 52, 5, 100)
+	assertAmplitudeAt(t, composite, constituents.C_M2, 51, 5, 75)
+	assertAmplitudeAt(t, composite, constituents.C_M2, 55, 9.5, 62.5)
+	assertAmplitudeAt(t, composite, constituents.C_M2, 50, 5, 50)
+}
+
+func TestCompositeBoundingBox(t *testing.T) {
+	global := createConstantDb(t, globalDimensions, 50, 0, constituents.C_M2)
+	// the first three columns of the regional model are land
+	regional := createConstantDb(t, regionalDimensions, 100, 3, constituents.C_M2)
+	composite, err := tidedatadb.NewCompositeTideDataDb(
+		tidedatadb.Layer{LayerInfo: tidedatadb.LayerInfo{Priority: 1, BoundingBox: &tidedatadb.BoundingBox{MinLat: 50, MinLon: 0, MaxLat: 55, MaxLon: 10}}, Db: regional},
+		tidedatadb.Layer{Db: global},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAmplitudeAt(t, composite, constituents.C_M2, 53, 5, 100)
+	// within the grid, but outside the bounding box
+	assertAmplitudeAt(t, composite, constituents.C_M2, 57, 5, 50)
+	// no data in the regional model
+	assertAmplitudeAt(t, composite, constituents.C_M2, 53, 1, 50)
+
+	// without a layer below the missing data is an error
+	regionalOnly, err := tidedatadb.NewCompositeTideDataDb(tidedatadb.Layer{Db: regional})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := regionalOnly.ConstituentsAt(53, 1); !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Errorf("expected ErrNoOceanData, got %v", err)
+	}
+	datums, err := regionalOnly.ConstituentsAt(40, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(datums) != 0 {
+		t.Errorf("expected no constituents outside of all layers, got %v", datums)
+	}
+}
+
+func readBinaryVersion(t *testing.T, filePath string) uint32 {
+	t.Helper()
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return binary.LittleEndian.Uint32(content[len(tidedatadb.BINARY_SIGNATURE):])
+}
+
+func TestNestedTideDataDb(t *testing.T) {
+	directory := t.TempDir()
+	filePath := filepath.Join(directory, "nested.db")
+	box := &tidedatadb.BoundingBox{MinLat: 51, MinLon: 1, MaxLat: 59, MaxLon: 9}
+	err := tidedatadb.WriteNestedTideDataDb(filePath,
+		tidedatadb.Layer{Db: createConstantDb(t, globalDimensions, 50, 0, constituents.C_M2, constituents.C_K1)},
+		tidedatadb.Layer{LayerInfo: tidedatadb.LayerInfo{Priority: 1, BoundingBox: box, BlendWidth: 2}, Db: createConstantDb(t, regionalDimensions, 100, 0, constituents.C_M2)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := readBinaryVersion(t, filePath); version != tidedatadb.BINARY_VERSION_NESTED {
+		t.Errorf("expected version %d, got %d", tidedatadb.BINARY_VERSION_NESTED, version)
+	}
+
+	for _, mode := range []tidedatadb.FileMode{tidedatadb.MODE_READONLY, tidedatadb.MODE_MMAP} {
+		composite, err := tidedatadb.OpenCompositeTideDataDb(filePath, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers := composite.Layers()
+		if len(layers) != 2 || layers[0].Priority != 1 || layers[0].BlendWidth != 2 || *layers[0].BoundingBox != *box || layers[1].BoundingBox != nil {
+			t.Errorf("unexpected layers %+v", layers)
+		}
+		// the regional grid is reduced to the bounding box
+		constituentData, err := layers[0].Db.GetConstituentData(constituents.C_M2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if constituentData.Dimensions.MinLat != 51 || constituentData.Dimensions.GridXSize != 9 {
+			t.Errorf("unexpected dimensions of the regional layer %+v", constituentData.Dimensions)
+		}
+
+		assertAmplitudeAt(t, composite, constituents.C_M2, 55, 5, 100)
+		assertAmplitudeAt(t, composite, constituents.C_M2, 52, 5, 75)
+		assertAmplitudeAt(t, composite, constituents.C_M2, 40, 5, 50)
+		assertAmplitudeAt(t, composite, constituents.C_K1, 55, 5, 50)
+		if err := composite.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// plain readers see the base layer
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAmplitudeAt(t, tideDataDb, constituents.C_M2, 55, 5, 50)
+	tideDataDb.Close()
+
+	// plain dbs are opened with a single layer and keep the version of plain dbs
+	plainPath := filepath.Join(directory, "plain.db")
+	writeDb(t, plainPath, tidedatadb.FORMAT_BINARY, defaultConstituentInfo(constituents.C_M2))
+	if version := readBinaryVersion(t, plainPath); version != tidedatadb.BINARY_VERSION {
+		t.Errorf("expected version %d, got %d", tidedatadb.BINARY_VERSION, version)
+	}
+	composite, err := tidedatadb.OpenCompositeTideDataDb(plainPath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer composite.Close()
+	if len(composite.Layers()) != 1 {
+		t.Errorf("expected a single layer, got %d", len(composite.Layers()))
+	}
+}
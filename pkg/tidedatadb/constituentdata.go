@@ -4,7 +4,6 @@ import (
 	"errors"
 	"math"
 
-	"github.com/fhs/go-netcdf/netcdf"
 	"github.com/mzeiher/perth3-go/pkg/constituents"
 	"github.com/mzeiher/perth3-go/pkg/utils"
 )
@@ -13,6 +12,7 @@ var (
 	ErrConstituentNotFound    = errors.New("constituent not found")
 	ErrConstituentAlreadyInDb = errors.New("constituent already in DB")
 	ErrUnitNotFound           = errors.New("unit not found")
+	ErrNoOceanData            = errors.New("no ocean data at location")
 )
 
 type ConstituentAmplitudeUnit byte
@@ -74,222 +74,311 @@ func (c ConstituentPhaseUnit) String() string {
 	return ""
 }
 
-const ATTR_UNIT_AMPLITUDE = "UNIT_AMP"
-const ATTR_UNIT_PHASE = "UNIT_PHASE"
-
 type ConstituentInfo struct {
 	Constituent   constituents.Constituent
 	AmplitudeUnit ConstituentAmplitudeUnit
 	PhaseUnit     ConstituentPhaseUnit
+	// grid points where amplitude or phase equal the fill value have no data (e.g. land)
+	HasFillValue bool
+	FillValue    float32
 }
 
 func (t *TideDataDB) GetConstituentData(constituent constituents.Constituent) (*ConstituentData, error) {
-	variable, err := t.file.Var(constituent.String())
+	dimensions, constituentInfo, grid, err := t.backend.GetConstituentGrid(constituent)
 	if err != nil {
 		return nil, err
 	}
+	return &ConstituentData{
+		grid:                     grid,
+		Dimensions:               dimensions,
+		ConstituentInfo:          constituentInfo,
+		NearestOceanSearchRadius: t.nearestOceanSearchRadius,
+		InterpolationMethod:      t.interpolationMethod,
+	}, nil
+}
 
-	attrAmpUnit, err := utils.NetcdfGetStringFromAttribute(ATTR_UNIT_AMPLITUDE, &variable)
-	if err != nil {
-		return nil, err
-	}
-	attrPhaseUnit, err := utils.NetcdfGetStringFromAttribute(ATTR_UNIT_PHASE, &variable)
-	if err != nil {
-		return nil, err
+// implements constituents.HarmonicConstantsProvider, returns the interpolated harmonic constants of
+// the wanted constituents in the db at lat/lon, only the grids of these constituents are read
+func (t *TideDataDB) ConstituentsAt(lat float32, lon float32, wanted ...constituents.Constituent) ([]constituents.ConstituentDatum, error) {
+	datums := []constituents.ConstituentDatum{}
+	for _, constituent := range constituents.LookupConstituents(wanted) {
+		constituentData, err := t.GetConstituentData(constituent)
+		if err != nil && errors.Is(err, ErrConstituentNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		datum, err := constituentData.GetDataInterpolatedLatLon(lat, lon)
+		if err != nil {
+			return nil, err
+		}
+		datums = append(datums, *datum)
 	}
+	return datums, nil
+}
 
-	ampUnit, err := ConstituentAmplitudeUnitFromString(attrAmpUnit)
-	if err != nil {
-		return nil, err
-	}
-	phaseUnit, err := ConstituentPhaseUnitFromString(attrPhaseUnit)
+func (t *TideDataDB) CreateNewConstituentData(dimensionsToCreate Dimensions, constituentInfoToCreate ConstituentInfo) (*ConstituentData, error) {
+	grid, err := t.backend.CreateConstituentGrid(dimensionsToCreate, constituentInfoToCreate)
 	if err != nil {
 		return nil, err
 	}
+	return &ConstituentData{
+		grid:                     grid,
+		Dimensions:               dimensionsToCreate,
+		ConstituentInfo:          constituentInfoToCreate,
+		NearestOceanSearchRadius: t.nearestOceanSearchRadius,
+		InterpolationMethod:      t.interpolationMethod,
+	}, nil
+}
 
-	dimensionsLat, err := t.file.Dim("lat")
-	if err != nil {
-		return nil, err
-	}
-	dimensionLatLen, err := dimensionsLat.Len()
-	if err != nil {
-		return nil, err
-	}
+// Dimensions, ConstituentInfo, NearestOceanSearchRadius and InterpolationMethod must not be changed while the
+// constituent data is used concurrently, all methods are safe for concurrent use
+type ConstituentData struct {
+	grid            Grid
+	Dimensions      Dimensions
+	ConstituentInfo ConstituentInfo
+	// if there is no valid data at a location, use the nearest grid point with data within
+	// this radius (in degree), 0 disables the search, defaults to the radius set on the db
+	NearestOceanSearchRadius float32
+	// method used by GetDataInterpolatedLatLon, defaults to the method set on the db
+	InterpolationMethod utils.InterpolationMethod
+}
 
-	dimensionsLon, err := t.file.Dim("lon")
-	if err != nil {
-		return nil, err
+func (c *ConstituentData) WriteDataXY(amplitudePhase []float32, x uint64, y uint64) error {
+	return c.grid.WriteXY(amplitudePhase, x, y)
+}
+
+func (c *ConstituentData) GetDataXY(x uint64, y uint64) ([]float32, error) {
+	return c.grid.ReadXY(x, y)
+}
+
+// interpolates amplitude and phase at lat/lon, the interpolation is done on the in-phase and quadrature
+// components (hcos/hsin) and converted back to amplitude and phase afterwards, interpolating
+// the phase directly would give wrong results at the 360/0 degree seam and near amphidromic points.
+// The grid is interpolated with InterpolationMethod
+func (c *ConstituentData) GetDataInterpolatedLatLon(lat float32, lon float32) (*constituents.ConstituentDatum, error) {
+	return c.GetDataInterpolatedLatLonWithMethod(lat, lon, c.InterpolationMethod)
+}
+
+// like GetDataInterpolatedLatLon with the given interpolation method, e.g. to compare a single location
+// with the nearest grid point. Meshes are interpolated linearly within the triangle for all methods but
+// nearest, which takes the nearest node of the triangle
+func (c *ConstituentData) GetDataInterpolatedLatLonWithMethod(lat float32, lon float32, method utils.InterpolationMethod) (*constituents.ConstituentDatum, error) {
+	lon = c.Dimensions.NormalizeLongitude(lon)
+	var rawData []float32
+	var err error
+	if c.Dimensions.IsMesh() {
+		rawData, err = c.interpolateMesh(lat, lon, method)
+	} else if c.Dimensions.IsRegular() {
+		rawData, err = utils.InterpolateValuesWithMethod(lat, lon, c.Dimensions.MinLat, c.Dimensions.MaxLat, c.Dimensions.MinLon, c.Dimensions.MaxLon, c.Dimensions.GridXSize, c.Dimensions.GridYSize, harmonicGrid{constituentData: c}, c.Dimensions.IsGlobal(), method)
+	} else {
+		x, y, positionErr := c.Dimensions.GridPosition(lat, lon)
+		if positionErr != nil {
+			return nil, positionErr
+		}
+		rawData, err = utils.InterpolateGridPositionWithMethod(x, y, c.Dimensions.GridXSize, c.Dimensions.GridYSize, harmonicGrid{constituentData: c}, c.Dimensions.IsGlobal(), method)
+	}
+	if err != nil && errors.Is(err, utils.ErrUndefinedValue) {
+		rawData, err = c.getNearestOceanData(lat, lon)
+	} else if err != nil && errors.Is(err, utils.ErrOutOfGrid) && c.Dimensions.IsMesh() {
+		// the border of a mesh is usually the coastline, locations on land next to it use the nearest node
+		rawData, err = c.getNearestOceanData(lat, lon)
+		if err != nil && errors.Is(err, ErrNoOceanData) {
+			err = utils.ErrOutOfGrid
+		}
 	}
-	dimensionLonLen, err := dimensionsLon.Len()
 	if err != nil {
 		return nil, err
 	}
+	hCos := float64(rawData[0])
+	hSin := float64(rawData[1])
 
-	latVar, err := t.file.Var("lat")
-	if err != nil {
-		return nil, err
+	amplitude := math.Hypot(hCos, hSin)
+	phase := math.Atan2(hSin, hCos) * (180 / math.Pi)
+	if phase < 0 {
+		phase = phase + 360
 	}
 
-	lonVar, err := t.file.Var("lon")
-	if err != nil {
-		return nil, err
+	if c.ConstituentInfo.AmplitudeUnit == UNIT_METER {
+		amplitude = amplitude * 100
+	} else if c.ConstituentInfo.AmplitudeUnit == UNIT_FEET {
+		amplitude = amplitude * 30.48
 	}
+	return &constituents.ConstituentDatum{
+		Constituent: c.ConstituentInfo.Constituent,
+		Amplitude:   amplitude,
+		Phase:       phase,
+	}, nil
+}
 
-	minLat, err := latVar.ReadFloat64At([]uint64{0})
-	if err != nil {
-		return nil, err
-	}
-	maxLat, err := latVar.ReadFloat64At([]uint64{dimensionLatLen - 1})
+// exposes the amplitude/phase grid of a constituent as hcos/hsin grid for interpolation
+type harmonicGrid struct {
+	constituentData *ConstituentData
+}
+
+func (h harmonicGrid) GetDataXY(x uint64, y uint64) ([]float32, error) {
+	rawData, err := h.constituentData.GetDataXY(x, y)
 	if err != nil {
 		return nil, err
 	}
-	minLon, err := lonVar.ReadFloat64At([]uint64{0})
-	if err != nil {
-		return nil, err
+	if h.constituentData.isUndefined(rawData) {
+		return nil, utils.ErrUndefinedValue
 	}
-	maxLon, err := lonVar.ReadFloat64At([]uint64{dimensionLonLen - 1})
-	if err != nil {
-		return nil, err
+	amplitude := float64(rawData[0])
+	phase := float64(rawData[1])
+	if h.constituentData.ConstituentInfo.PhaseUnit == UNIT_DEGREE {
+		phase = phase * (math.Pi / 180)
 	}
-
-	return &ConstituentData{
-		variable: &variable,
-		Dimensions: Dimensions{
-			MinLat:        float32(minLat),
-			MaxLat:        float32(maxLat),
-			MinLon:        float32(minLon),
-			MaxLon:        float32(maxLon),
-			GridXSize:     dimensionLonLen,
-			GridYSize:     dimensionLatLen,
-			ResolutionLat: (float32(maxLat) - float32(minLat)) / float32(dimensionLatLen-1),
-			ResolutionLon: (float32(maxLon) - float32(minLon)) / float32(dimensionLonLen-1),
-		},
-		ConstituentInfo: ConstituentInfo{
-			Constituent:   constituent,
-			AmplitudeUnit: ampUnit,
-			PhaseUnit:     phaseUnit,
-		},
-	}, nil
+	return []float32{float32(amplitude * math.Cos(phase)), float32(amplitude * math.Sin(phase))}, nil
 }
 
-func (t *TideDataDB) CreateNewConstituentData(dimensionsToCreate Dimensions, constituentInfoToCreate ConstituentInfo) (*ConstituentData, error) {
-	// create dimensions if not exist
-	var dimLat netcdf.Dim
-	var dimLon netcdf.Dim
-	var dimData netcdf.Dim
-	var err error
-
-	if dimLat, err = t.file.Dim("lat"); err != nil {
-		dimLat, err = t.file.AddDim("lat", dimensionsToCreate.GridYSize)
-		if err != nil {
-			return nil, err
-		}
+// interpolates the hcos/hsin components with the barycentric weights of the nodes of the mesh triangle
+// containing lat/lon, undefined nodes are excluded like the corners of grid cells. With INTERPOLATION_NEAREST
+// the node with the highest weight is used
+func (c *ConstituentData) interpolateMesh(lat float32, lon float32, method utils.InterpolationMethod) ([]float32, error) {
+	nodes, weights, err := c.Dimensions.MeshPosition(lat, lon)
+	if err != nil {
+		return nil, err
 	}
-	if dimLon, err = t.file.Dim("lon"); err != nil {
-		dimLon, err = t.file.AddDim("lon", dimensionsToCreate.GridXSize)
-		if err != nil {
-			return nil, err
+	grid := harmonicGrid{constituentData: c}
+	if method == utils.INTERPOLATION_NEAREST {
+		nearest := 0
+		for i := range weights {
+			if weights[i] > weights[nearest] {
+				nearest = i
+			}
 		}
-	}
-	if dimData, err = t.file.Dim("data"); err != nil {
-		dimData, err = t.file.AddDim("data", 2)
-		if err != nil {
+		return grid.GetDataXY(c.Dimensions.NodeXY(nodes[nearest]))
+	}
+	values := make([]float32, 2)
+	combinedWeight := float32(0)
+	for i, node := range nodes {
+		nodeValues, err := grid.GetDataXY(c.Dimensions.NodeXY(node))
+		if errors.Is(err, utils.ErrUndefinedValue) {
+			continue
+		} else if err != nil {
 			return nil, err
 		}
+		values[0] = values[0] + weights[i]*nodeValues[0]
+		values[1] = values[1] + weights[i]*nodeValues[1]
+		combinedWeight = combinedWeight + weights[i]
 	}
-	// create dimension data if not exist
-	if _, err := t.file.Var("lat"); err != nil {
-		dimLatVar, err := t.file.AddVar("lat", netcdf.DOUBLE, []netcdf.Dim{dimLat})
-		if err != nil {
-			return nil, err
-		}
-		for i := 0; i < int(dimensionsToCreate.GridYSize); i++ {
-			dimLatVar.WriteFloat64At([]uint64{uint64(i)}, float64(dimensionsToCreate.MinLat)+(float64(i)*float64(dimensionsToCreate.ResolutionLat)))
-		}
+	if combinedWeight <= 0.5 {
+		return nil, utils.ErrUndefinedValue
 	}
-	if _, err := t.file.Var("lon"); err != nil {
-		dimLonVar, err := t.file.AddVar("lon", netcdf.DOUBLE, []netcdf.Dim{dimLon})
-		if err != nil {
-			return nil, err
+	values[0] = values[0] / combinedWeight
+	values[1] = values[1] / combinedWeight
+	return values, nil
+}
+
+func (c *ConstituentData) isUndefined(amplitudePhase []float32) bool {
+	for _, value := range amplitudePhase {
+		if math.IsNaN(float64(value)) {
+			return true
 		}
-		for i := 0; i < int(dimensionsToCreate.GridXSize); i++ {
-			dimLonVar.WriteFloat64At([]uint64{uint64(i)}, float64(dimensionsToCreate.MinLon)+(float64(i)*float64(dimensionsToCreate.ResolutionLon)))
+		if c.ConstituentInfo.HasFillValue && value == c.ConstituentInfo.FillValue {
+			return true
 		}
 	}
-
-	constituentVariable, err := t.file.AddVar(constituentInfoToCreate.Constituent.String(), netcdf.FLOAT, []netcdf.Dim{dimLat, dimLon, dimData})
-	if err != nil {
-		return nil, err
-	}
-
-	attrUnitAmplitude := constituentVariable.Attr(ATTR_UNIT_AMPLITUDE)
-	err = attrUnitAmplitude.WriteBytes([]byte(constituentInfoToCreate.AmplitudeUnit.String()))
-	if err != nil {
-		return nil, err
-	}
-
-	attrUnitPhase := constituentVariable.Attr(ATTR_UNIT_PHASE)
-	if err != nil {
-		return nil, err
-	}
-	err = attrUnitPhase.WriteBytes([]byte(constituentInfoToCreate.PhaseUnit.String()))
-	if err != nil {
-		return nil, err
-	}
-
-	return &ConstituentData{
-		variable:        &constituentVariable,
-		Dimensions:      dimensionsToCreate,
-		ConstituentInfo: constituentInfoToCreate,
-	}, nil
+	return false
 }
 
-type ConstituentData struct {
-	variable        *netcdf.Var
-	Dimensions      Dimensions
-	ConstituentInfo ConstituentInfo
-}
-
-func (c *ConstituentData) WriteDataXY(amplitudePhase []float32, x uint64, y uint64) error {
-	err := c.variable.WriteFloat32At([]uint64{y, x, 0}, amplitudePhase[0])
-	if err != nil {
-		return err
+// searches the nearest grid point with valid data within NearestOceanSearchRadius
+// and returns its hcos/hsin components, ErrNoOceanData if nothing is found
+func (c *ConstituentData) getNearestOceanData(lat float32, lon float32) ([]float32, error) {
+	radius := float64(c.NearestOceanSearchRadius)
+	if radius <= 0 {
+		return nil, ErrNoOceanData
 	}
-	err = c.variable.WriteFloat32At([]uint64{y, x, 1}, amplitudePhase[1])
-	if err != nil {
-		return err
+	grid := harmonicGrid{constituentData: c}
+	dimensions := c.Dimensions
+	if dimensions.IsMesh() {
+		return c.getNearestMeshNode(lat, lon, radius)
 	}
-	return nil
-}
 
-func (c *ConstituentData) GetDataXY(x uint64, y uint64) ([]float32, error) {
-	amp, err := c.variable.ReadFloat32At([]uint64{y, x, 0})
+	positionX, positionY, err := dimensions.GridPosition(lat, lon)
 	if err != nil {
 		return nil, err
 	}
-	phase, err := c.variable.ReadFloat32At([]uint64{y, x, 1})
-	if err != nil {
-		return nil, err
+	centerX := int64(math.Round(float64(positionX)))
+	centerY := int64(math.Round(float64(positionY)))
+	// a degree of longitude gets shorter towards the poles, so search more grid points along the longitude
+	searchY := int64(math.Ceil(radius / math.Abs(float64(dimensions.ResolutionLat))))
+	maxAbsLat := math.Min(math.Abs(float64(lat))+radius, 90)
+	searchX := int64(dimensions.GridXSize)
+	if math.Cos(maxAbsLat*(math.Pi/180)) > 1e-6 {
+		searchX = int64(math.Ceil(radius / (math.Abs(float64(dimensions.ResolutionLon)) * math.Cos(maxAbsLat*(math.Pi/180)))))
+	}
+	if searchX > int64(dimensions.GridXSize) {
+		searchX = int64(dimensions.GridXSize)
+	}
+	global := dimensions.IsGlobal()
+
+	var nearest []float32
+	nearestDistance := math.Inf(1)
+	for y := centerY - searchY; y <= centerY+searchY; y++ {
+		if y < 0 || y >= int64(dimensions.GridYSize) {
+			continue
+		}
+		for x := centerX - searchX; x <= centerX+searchX; x++ {
+			cellX := x
+			if global {
+				cellX = ((x % int64(dimensions.GridXSize)) + int64(dimensions.GridXSize)) % int64(dimensions.GridXSize)
+			} else if x < 0 || x >= int64(dimensions.GridXSize) {
+				continue
+			}
+			latXY, lonXY := dimensions.LatLonXY(uint64(cellX), uint64(y))
+			cellLat := float64(latXY)
+			if math.IsNaN(cellLat) {
+				continue
+			}
+
+			// equirectangular approximation of the angular distance, the longitudes may be on both sides of the seam of a global grid
+			deltaLat := cellLat - float64(lat)
+			deltaLon := (math.Mod(float64(lonXY-lon)+540, 360) - 180) * math.Cos(((cellLat+float64(lat))/2)*(math.Pi/180))
+			distance := math.Hypot(deltaLat, deltaLon)
+			if distance > radius || distance >= nearestDistance {
+				continue
+			}
+
+			values, err := grid.GetDataXY(uint64(cellX), uint64(y))
+			if err != nil && errors.Is(err, utils.ErrUndefinedValue) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			nearest = values
+			nearestDistance = distance
+		}
+	}
+	if nearest == nil {
+		return nil, ErrNoOceanData
 	}
-	return []float32{amp, phase}, nil
+	return nearest, nil
 }
 
-func (c *ConstituentData) GetDataInterpolatedLatLon(lat float32, lon float32) (*constituents.ConstituentDatum, error) {
-	rawData, err := utils.InterpolateValues(lat, lon, c.Dimensions.MinLat, c.Dimensions.MaxLat, c.Dimensions.MinLon, c.Dimensions.MaxLon, c.Dimensions.GridXSize, c.Dimensions.GridYSize, c, true)
-	if err != nil {
-		return nil, err
-	}
-	if c.ConstituentInfo.AmplitudeUnit == UNIT_METER {
-		rawData[0] = rawData[0] / 100
-	} else if c.ConstituentInfo.AmplitudeUnit == UNIT_FEET {
-		rawData[0] = rawData[0] * 30.48
+// returns the hcos/hsin components of the nearest mesh node with valid data within radius
+func (c *ConstituentData) getNearestMeshNode(lat float32, lon float32, radius float64) ([]float32, error) {
+	grid := harmonicGrid{constituentData: c}
+	index, _ := c.Dimensions.Coordinates.searchIndex()
+	var nearest []float32
+	var readErr error
+	node := index.NearestWithin(lat, lon, radius, func(node int) bool {
+		values, err := grid.GetDataXY(c.Dimensions.NodeXY(uint64(node)))
+		if err != nil && !errors.Is(err, utils.ErrUndefinedValue) && readErr == nil {
+			readErr = err
+		}
+		if err != nil {
+			return false
+		}
+		nearest = values
+		return true
+	})
+	if readErr != nil {
+		return nil, readErr
 	}
-	if c.ConstituentInfo.PhaseUnit == UNIT_RADIAN {
-		rawData[1] = rawData[1] * (180 / math.Pi)
+	if node < 0 {
+		return nil, ErrNoOceanData
 	}
-	return &constituents.ConstituentDatum{
-		Constituent: c.ConstituentInfo.Constituent,
-		Amplitude:   float64(rawData[0]),
-		Phase:       float64(rawData[1]),
-	}, nil
+	return nearest, nil
 }
@@ -0,0 +1,414 @@
+package tidedatadb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+var ErrInvalidCoordinates = errors.New("invalid grid coordinates")
+
+// relative deviation of the spacing of an axis up to which it is treated as regular
+const regularSpacingTolerance = 1e-4
+
+// coordinates of the grid points of grids without a regular spacing, e.g. regional models refined
+// towards the coast or the output of ocean models. All constituents of a model should share the
+// same coordinates, the search index is built once per GridCoordinates
+type GridCoordinates struct {
+	// true if Latitudes and Longitudes are the coordinates of every grid point (index y*GridXSize+x),
+	// otherwise the strictly increasing latitudes of the rows and longitudes of the columns
+	Curvilinear bool
+	Latitudes   []float32
+	Longitudes  []float32
+	// node indices of the triangles of unstructured meshes (three per triangle), the nodes are
+	// stored like the grid points of a curvilinear grid, see NewMeshDimensions
+	Triangles []uint32
+
+	indexOnce     sync.Once
+	index         *utils.KDTree
+	triangleIndex *utils.TriangleIndex
+}
+
+// returns the search indices of the coordinates, they are built on the first use
+func (g *GridCoordinates) searchIndex() (*utils.KDTree, *utils.TriangleIndex) {
+	g.indexOnce.Do(func() {
+		g.index = utils.NewKDTree(g.Latitudes, g.Longitudes)
+		if g.Triangles != nil {
+			g.triangleIndex = utils.NewTriangleIndex(g.Latitudes, g.Longitudes, g.Triangles)
+		}
+	})
+	return g.index, g.triangleIndex
+}
+
+// returns the dimensions of a grid with the given latitudes of the rows and longitudes of the columns,
+// both strictly increasing. Regularly spaced axes return a regular grid without coordinates
+func NewRectilinearDimensions(latitudes []float32, longitudes []float32) (Dimensions, error) {
+	if len(latitudes) < 2 || len(longitudes) < 2 {
+		return Dimensions{}, fmt.Errorf("%w: at least 2 rows and columns needed", ErrInvalidCoordinates)
+	}
+	regularLat, err := isRegularAxis(latitudes)
+	if err != nil {
+		return Dimensions{}, fmt.Errorf("%w: latitudes %s", ErrInvalidCoordinates, err)
+	}
+	regularLon, err := isRegularAxis(longitudes)
+	if err != nil {
+		return Dimensions{}, fmt.Errorf("%w: longitudes %s", ErrInvalidCoordinates, err)
+	}
+	dimensions := Dimensions{
+		MinLat:    latitudes[0],
+		MaxLat:    latitudes[len(latitudes)-1],
+		MinLon:    longitudes[0],
+		MaxLon:    longitudes[len(longitudes)-1],
+		GridXSize: uint64(len(longitudes)),
+		GridYSize: uint64(len(latitudes)),
+	}
+	dimensions.ResolutionLat = (dimensions.MaxLat - dimensions.MinLat) / float32(dimensions.GridYSize-1)
+	dimensions.ResolutionLon = (dimensions.MaxLon - dimensions.MinLon) / float32(dimensions.GridXSize-1)
+	if !regularLat || !regularLon {
+		dimensions.Coordinates = &GridCoordinates{
+			Latitudes:  append([]float32{}, latitudes...),
+			Longitudes: append([]float32{}, longitudes...),
+		}
+	}
+	return dimensions, nil
+}
+
+// returns the dimensions of a curvilinear grid with the coordinates of each grid point (index y*sizeX+x),
+// grid points without coordinates (NaN, e.g. land in some ocean models) are never found by a lookup.
+// Longitudes may be in any convention, the extent of the grid is the bounding box of all grid points
+func NewCurvilinearDimensions(sizeX uint64, sizeY uint64, latitudes []float32, longitudes []float32) (Dimensions, error) {
+	if sizeX < 2 || sizeY < 2 {
+		return Dimensions{}, fmt.Errorf("%w: at least 2 rows and columns needed", ErrInvalidCoordinates)
+	}
+	if uint64(len(latitudes)) != sizeX*sizeY || uint64(len(longitudes)) != sizeX*sizeY {
+		return Dimensions{}, fmt.Errorf("%w: expected %d coordinates", ErrInvalidCoordinates, sizeX*sizeY)
+	}
+	minLat, maxLat := float32(math.Inf(1)), float32(math.Inf(-1))
+	minLon, maxLon := float32(math.Inf(1)), float32(math.Inf(-1))
+	for i := range latitudes {
+		if math.IsNaN(float64(latitudes[i])) || math.IsNaN(float64(longitudes[i])) {
+			continue
+		}
+		minLat = float32(math.Min(float64(minLat), float64(latitudes[i])))
+		maxLat = float32(math.Max(float64(maxLat), float64(latitudes[i])))
+		minLon = float32(math.Min(float64(minLon), float64(longitudes[i])))
+		maxLon = float32(math.Max(float64(maxLon), float64(longitudes[i])))
+	}
+	if minLat > maxLat {
+		return Dimensions{}, fmt.Errorf("%w: no grid point with coordinates", ErrInvalidCoordinates)
+	}
+	return Dimensions{
+		MinLat:        minLat,
+		MaxLat:        maxLat,
+		MinLon:        minLon,
+		MaxLon:        maxLon,
+		ResolutionLat: (maxLat - minLat) / float32(sizeY-1),
+		ResolutionLon: (maxLon - minLon) / float32(sizeX-1),
+		GridXSize:     sizeX,
+		GridYSize:     sizeY,
+		Coordinates: &GridCoordinates{
+			Curvilinear: true,
+			Latitudes:   append([]float32{}, latitudes...),
+			Longitudes:  append([]float32{}, longitudes...),
+		},
+	}, nil
+}
+
+// returns the dimensions of an unstructured triangular mesh (e.g. ADCIRC or FVCOM models) with the
+// coordinates of the nodes and the node indices of the triangles. The nodes are stored in a square
+// grid of ceil(sqrt(nodes)) columns, node i is the grid point i%GridXSize, i/GridXSize (see NodeXY)
+func NewMeshDimensions(latitudes []float32, longitudes []float32, triangles []uint32) (Dimensions, error) {
+	if len(latitudes) != len(longitudes) || len(latitudes) < 3 {
+		return Dimensions{}, fmt.Errorf("%w: expected the coordinates of at least 3 nodes", ErrInvalidCoordinates)
+	}
+	sizeX := uint64(math.Ceil(math.Sqrt(float64(len(latitudes)))))
+	sizeY := (uint64(len(latitudes)) + sizeX - 1) / sizeX
+	// the grid points behind the last node have no coordinates
+	paddedLatitudes := make([]float32, sizeX*sizeY)
+	paddedLongitudes := make([]float32, sizeX*sizeY)
+	for i := len(latitudes); i < len(paddedLatitudes); i++ {
+		paddedLatitudes[i] = float32(math.NaN())
+		paddedLongitudes[i] = float32(math.NaN())
+	}
+	copy(paddedLatitudes, latitudes)
+	copy(paddedLongitudes, longitudes)
+	return meshDimensions(sizeX, sizeY, paddedLatitudes, paddedLongitudes, triangles)
+}
+
+// returns the dimensions of a mesh with nodes stored in a grid of sizeX*sizeY grid points
+func meshDimensions(sizeX uint64, sizeY uint64, latitudes []float32, longitudes []float32, triangles []uint32) (Dimensions, error) {
+	if len(triangles) == 0 || len(triangles)%3 != 0 {
+		return Dimensions{}, fmt.Errorf("%w: expected three nodes per triangle", ErrInvalidCoordinates)
+	}
+	for _, node := range triangles {
+		if uint64(node) >= uint64(len(latitudes)) || math.IsNaN(float64(latitudes[node])) || math.IsNaN(float64(longitudes[node])) {
+			return Dimensions{}, fmt.Errorf("%w: triangle with unknown node %d", ErrInvalidCoordinates, node)
+		}
+	}
+	dimensions, err := NewCurvilinearDimensions(sizeX, sizeY, latitudes, longitudes)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	dimensions.Coordinates.Triangles = append([]uint32{}, triangles...)
+	return dimensions, nil
+}
+
+// returns true if the grid holds the nodes of a triangular mesh
+func (d Dimensions) IsMesh() bool {
+	return d.Coordinates != nil && d.Coordinates.Triangles != nil
+}
+
+// returns the grid point of the node of a mesh
+func (d Dimensions) NodeXY(node uint64) (uint64, uint64) {
+	return node % d.GridXSize, node / d.GridXSize
+}
+
+// returns the three nodes of the mesh triangle containing lat/lon and their barycentric weights,
+// ErrOutOfGrid if the location is outside of the mesh
+func (d Dimensions) MeshPosition(lat float32, lon float32) ([3]uint64, [3]float32, error) {
+	_, triangleIndex := d.Coordinates.searchIndex()
+	triangle, weights, ok := triangleIndex.Locate(lat, lon)
+	if !ok {
+		return [3]uint64{}, [3]float32{}, utils.ErrOutOfGrid
+	}
+	triangles := d.Coordinates.Triangles
+	return [3]uint64{uint64(triangles[3*triangle]), uint64(triangles[3*triangle+1]), uint64(triangles[3*triangle+2])}, weights, nil
+}
+
+// returns true if the spacing of the strictly increasing axis is constant
+func isRegularAxis(axis []float32) (bool, error) {
+	resolution := float64(axis[len(axis)-1]-axis[0]) / float64(len(axis)-1)
+	regular := true
+	for i := 1; i < len(axis); i++ {
+		spacing := float64(axis[i] - axis[i-1])
+		if !(spacing > 0) {
+			return false, errors.New("must be strictly increasing")
+		}
+		if math.Abs(spacing-resolution) > regularSpacingTolerance*resolution {
+			regular = false
+		}
+	}
+	return regular, nil
+}
+
+// returns true if the grid points are regularly spaced by ResolutionLat/ResolutionLon
+func (d Dimensions) IsRegular() bool {
+	return d.Coordinates == nil
+}
+
+// returns latitude and longitude of the grid point x,y
+func (d Dimensions) LatLonXY(x uint64, y uint64) (float32, float32) {
+	switch {
+	case d.Coordinates == nil:
+		return d.MinLat + float32(y)*d.ResolutionLat, d.MinLon + float32(x)*d.ResolutionLon
+	case d.Coordinates.Curvilinear:
+		return d.Coordinates.Latitudes[y*d.GridXSize+x], d.Coordinates.Longitudes[y*d.GridXSize+x]
+	}
+	return d.Coordinates.Latitudes[y], d.Coordinates.Longitudes[x]
+}
+
+// returns the fractional grid position of lat/lon, e.g. 1.5/2 between the grid points 1,2 and 2,2.
+// The rows and columns of rectilinear grids are found by a binary search, the cell of curvilinear
+// grids by a search for the nearest grid point. ErrOutOfGrid if a grid with coordinates does not
+// contain the location, meshes have no grid positions (see MeshPosition)
+func (d Dimensions) GridPosition(lat float32, lon float32) (float32, float32, error) {
+	if d.IsMesh() {
+		return 0, 0, utils.ErrOutOfGrid
+	}
+	if d.Coordinates == nil {
+		lon = d.NormalizeLongitude(lon)
+		return utils.MapValue(lon, d.MinLon, d.MaxLon, 0, float32(d.GridXSize-1)), utils.MapValue(lat, d.MinLat, d.MaxLat, 0, float32(d.GridYSize-1)), nil
+	}
+	if d.Coordinates.Curvilinear {
+		return d.curvilinearPosition(lat, lon)
+	}
+
+	y, err := utils.AxisPosition(d.Coordinates.Latitudes, lat)
+	if err != nil {
+		return 0, 0, err
+	}
+	lon = d.NormalizeLongitude(lon)
+	x, err := utils.AxisPosition(d.Coordinates.Longitudes, lon)
+	if err != nil && d.IsGlobal() {
+		// between the last and the first column around the globe
+		last := d.Coordinates.Longitudes[d.GridXSize-1]
+		return float32(d.GridXSize-1) + (lon-last)/(d.MinLon+360-last), y, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
+
+func (d Dimensions) curvilinearPosition(lat float32, lon float32) (float32, float32, error) {
+	index, _ := d.Coordinates.searchIndex()
+	nearest := index.Nearest(lat, lon)
+	if nearest < 0 {
+		return 0, 0, utils.ErrOutOfGrid
+	}
+	nearestX := int64(uint64(nearest) % d.GridXSize)
+	nearestY := int64(uint64(nearest) / d.GridXSize)
+
+	// the location is in one of the cells around the nearest grid point
+	point := func(x int64, y int64) [2]float64 {
+		pointLat, pointLon := d.LatLonXY(uint64(x), uint64(y))
+		// longitudes relative to the location, so cells crossing the antimeridian stay connected
+		deltaLon := math.Mod(float64(pointLon-lon)+540, 360) - 180
+		return [2]float64{float64(lon) + deltaLon, float64(pointLat)}
+	}
+	for y0 := nearestY - 1; y0 <= nearestY; y0++ {
+		for x0 := nearestX - 1; x0 <= nearestX; x0++ {
+			if x0 < 0 || y0 < 0 || x0 >= int64(d.GridXSize)-1 || y0 >= int64(d.GridYSize)-1 {
+				continue
+			}
+			s, t, ok := utils.CellPosition(point(x0, y0), point(x0+1, y0), point(x0, y0+1), point(x0+1, y0+1), float64(lon), float64(lat))
+			if ok {
+				return float32(x0) + float32(s), float32(y0) + float32(t), nil
+			}
+		}
+	}
+	return 0, 0, utils.ErrOutOfGrid
+}
+
+// returns the window of grid points of a grid with coordinates covering the bounding box,
+// grids with coordinates are not wrapped around the globe
+func coordinateWindow(dimensions Dimensions, box BoundingBox) (Dimensions, gridWindow, error) {
+	var x0, y0, x1, y1 int64
+	if dimensions.Coordinates.Curvilinear {
+		// all grid points within the box
+		x0, y0, x1, y1 = int64(dimensions.GridXSize), int64(dimensions.GridYSize), -1, -1
+		for y := int64(0); y < int64(dimensions.GridYSize); y++ {
+			for x := int64(0); x < int64(dimensions.GridXSize); x++ {
+				if !box.contains(dimensions.LatLonXY(uint64(x), uint64(y))) {
+					continue
+				}
+				x0, y0 = min64(x0, x), min64(y0, y)
+				x1, y1 = max64(x1, x), max64(y1, y)
+			}
+		}
+		if x1 < 0 {
+			return Dimensions{}, gridWindow{}, ErrBoundingBoxOutsideGrid
+		}
+		// the cells around the grid points within the box
+		x0, y0, x1, y1 = x0-1, y0-1, x1+1, y1+1
+	} else {
+		latitudes := dimensions.Coordinates.Latitudes
+		longitudes := dimensions.Coordinates.Longitudes
+		y0, y1 = axisWindow(latitudes, box.MinLat, box.MaxLat)
+		// a box starting east of the grid may overlap it from the west
+		minLon := dimensions.NormalizeLongitude(box.MinLon)
+		if minLon > longitudes[len(longitudes)-1] {
+			minLon = minLon - 360
+		}
+		x0, x1 = axisWindow(longitudes, minLon, minLon+box.LonSpan())
+	}
+
+	x0, x1, okX := clampWindow(x0, x1, int64(dimensions.GridXSize))
+	y0, y1, okY := clampWindow(y0, y1, int64(dimensions.GridYSize))
+	if !okX || !okY {
+		return Dimensions{}, gridWindow{}, ErrBoundingBoxOutsideGrid
+	}
+	window := gridWindow{x0: x0, y0: y0, sizeX: uint64(x1 - x0 + 1), sizeY: uint64(y1 - y0 + 1)}
+
+	latitudes := []float32{}
+	longitudes := []float32{}
+	if dimensions.Coordinates.Curvilinear {
+		for y := uint64(0); y < window.sizeY; y++ {
+			for x := uint64(0); x < window.sizeX; x++ {
+				sourceX, sourceY, _ := window.sourceXY(x, y)
+				lat, lon := dimensions.LatLonXY(sourceX, sourceY)
+				latitudes = append(latitudes, lat)
+				longitudes = append(longitudes, lon)
+			}
+		}
+		subset, err := NewCurvilinearDimensions(window.sizeX, window.sizeY, latitudes, longitudes)
+		return subset, window, err
+	}
+	latitudes = append(latitudes, dimensions.Coordinates.Latitudes[y0:y1+1]...)
+	longitudes = append(longitudes, dimensions.Coordinates.Longitudes[x0:x1+1]...)
+	subset, err := NewRectilinearDimensions(latitudes, longitudes)
+	return subset, window, err
+}
+
+// returns the mesh of the triangles with a node within the bounding box or containing one of its corners
+func meshWindow(dimensions Dimensions, box BoundingBox) (Dimensions, gridWindow, error) {
+	coordinates := dimensions.Coordinates
+	selected := make([]bool, len(coordinates.Triangles)/3)
+	for t := range selected {
+		for k := 0; k < 3 && !selected[t]; k++ {
+			node := uint64(coordinates.Triangles[3*t+k])
+			selected[t] = box.contains(dimensions.LatLonXY(dimensions.NodeXY(node)))
+		}
+	}
+	_, triangleIndex := coordinates.searchIndex()
+	for _, corner := range [][2]float32{{box.MinLat, box.MinLon}, {box.MinLat, box.MaxLon}, {box.MaxLat, box.MinLon}, {box.MaxLat, box.MaxLon}} {
+		if triangle, _, ok := triangleIndex.Locate(corner[0], corner[1]); ok {
+			selected[triangle] = true
+		}
+	}
+
+	window := gridWindow{}
+	subsetNodes := make(map[uint32]uint32)
+	latitudes := []float32{}
+	longitudes := []float32{}
+	triangles := []uint32{}
+	for t, ok := range selected {
+		if !ok {
+			continue
+		}
+		for _, node := range coordinates.Triangles[3*t : 3*t+3] {
+			subsetNode, ok := subsetNodes[node]
+			if !ok {
+				subsetNode = uint32(len(window.nodes))
+				subsetNodes[node] = subsetNode
+				x, y := dimensions.NodeXY(uint64(node))
+				lat, lon := dimensions.LatLonXY(x, y)
+				window.nodes = append(window.nodes, [2]uint64{x, y})
+				latitudes = append(latitudes, lat)
+				longitudes = append(longitudes, lon)
+			}
+			triangles = append(triangles, subsetNode)
+		}
+	}
+	if len(triangles) == 0 {
+		return Dimensions{}, gridWindow{}, ErrBoundingBoxOutsideGrid
+	}
+	subset, err := NewMeshDimensions(latitudes, longitudes, triangles)
+	window.sizeX, window.sizeY = subset.GridXSize, subset.GridYSize
+	return subset, window, err
+}
+
+// returns the first and last index of the axis covering from to to, the indices may be outside of the axis
+func axisWindow(axis []float32, from float32, to float32) (int64, int64) {
+	first := int64(-1)
+	if from >= axis[0] {
+		first = int64(len(axis))
+		if position, err := utils.AxisPosition(axis, from); err == nil {
+			first = int64(math.Floor(float64(position)))
+		}
+	}
+	last := int64(len(axis))
+	if to <= axis[len(axis)-1] {
+		last = -1
+		if position, err := utils.AxisPosition(axis, to); err == nil {
+			last = int64(math.Ceil(float64(position)))
+		}
+	}
+	return first, last
+}
+
+func min64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
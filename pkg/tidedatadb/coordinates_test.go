@@ -0,0 +1,198 @@
+package tidedatadb_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// amplitude linear in lat/lon with phase 0, bilinear interpolation reproduces it exactly
+func linearAmplitude(lat float32, lon float32) float64 {
+	return 100 + float64(lat) + 2*float64(lon)
+}
+
+// writes the linear amplitude at the coordinates of every grid point
+func writeLinearField(t *testing.T, tideDataDb *tidedatadb.TideDataDB, dimensions tidedatadb.Dimensions, constituentList ...constituents.Constituent) {
+	t.Helper()
+	for _, constituent := range constituentList {
+		constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, defaultConstituentInfo(constituent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := uint64(0); y < dimensions.GridYSize; y++ {
+			for x := uint64(0); x < dimensions.GridXSize; x++ {
+				lat, lon := dimensions.LatLonXY(x, y)
+				if err := constituentData.WriteDataXY([]float32{float32(linearAmplitude(lat, lon)), 0}, x, y); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+// rotated and skewed grid, lat 50..56.5 and lon 7..17.5
+func curvilinearDimensions(t *testing.T) tidedatadb.Dimensions {
+	t.Helper()
+	const sizeX, sizeY = 6, 5
+	latitudes := make([]float32, sizeX*sizeY)
+	longitudes := make([]float32, sizeX*sizeY)
+	for y := 0; y < sizeY; y++ {
+		for x := 0; x < sizeX; x++ {
+			latitudes[y*sizeX+x] = 50 + 0.5*float32(x) + float32(y)
+			longitudes[y*sizeX+x] = 10 + 1.5*float32(x) - 0.75*float32(y)
+		}
+	}
+	dimensions, err := tidedatadb.NewCurvilinearDimensions(sizeX, sizeY, latitudes, longitudes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dimensions
+}
+
+func TestRectilinearDimensions(t *testing.T) {
+	regular, err := tidedatadb.NewRectilinearDimensions([]float32{-10, -5, 0, 5}, []float32{0, 2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regular.IsRegular() || regular.ResolutionLat != 5 || regular.ResolutionLon != 2 || regular.GridXSize != 3 || regular.GridYSize != 4 {
+		t.Errorf("expected a regular grid, got %+v", regular)
+	}
+
+	// refined towards the north
+	irregular, err := tidedatadb.NewRectilinearDimensions([]float32{50, 54, 56, 57}, []float32{0, 2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if irregular.IsRegular() {
+		t.Fatalf("expected an irregular grid")
+	}
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, irregular, constituents.C_M2)
+	// uniform spacing would put lat 55 at a third of the way between the rows at 54 and 56
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 55, 3, linearAmplitude(55, 3), 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 56.5, 0.5, linearAmplitude(56.5, 0.5), 0)
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constituentData.GetDataInterpolatedLatLon(58, 2); !errors.Is(err, utils.ErrOutOfGrid) {
+		t.Errorf("expected ErrOutOfGrid, got %v", err)
+	}
+
+	if _, err := tidedatadb.NewRectilinearDimensions([]float32{50, 49, 56}, []float32{0, 2}); !errors.Is(err, tidedatadb.ErrInvalidCoordinates) {
+		t.Errorf("expected ErrInvalidCoordinates, got %v", err)
+	}
+}
+
+func TestCurvilinearInterpolation(t *testing.T) {
+	dimensions := curvilinearDimensions(t)
+	if dimensions.MinLat != 50 || dimensions.MaxLat != 56.5 || dimensions.MinLon != 7 || dimensions.MaxLon != 17.5 {
+		t.Errorf("unexpected extent %+v", dimensions)
+	}
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, dimensions, constituents.C_M2)
+
+	for _, location := range [][2]float32{{50.2, 10.3}, {52.7, 12.1}, {53.4, 9.0}, {55.9, 14.2}} {
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, location[0], location[1], linearAmplitude(location[0], location[1]), 0)
+	}
+	// within the extent, but outside of the rotated grid
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constituentData.GetDataInterpolatedLatLon(50.5, 16); !errors.Is(err, utils.ErrOutOfGrid) {
+		t.Errorf("expected ErrOutOfGrid, got %v", err)
+	}
+}
+
+func TestBinaryCoordinates(t *testing.T) {
+	directory := t.TempDir()
+	irregular, err := tidedatadb.NewRectilinearDimensions([]float32{50, 54, 56, 57}, []float32{0, 2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	curvilinear := curvilinearDimensions(t)
+	for name, dimensions := range map[string]tidedatadb.Dimensions{"rectilinear": irregular, "curvilinear": curvilinear} {
+		filePath := filepath.Join(directory, name+".db")
+		tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_BINARY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeLinearField(t, tideDataDb, dimensions, constituents.C_M2, constituents.C_K1)
+		if err := tideDataDb.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if version := readBinaryVersion(t, filePath); version != tidedatadb.BINARY_VERSION_COORDINATES {
+			t.Errorf("%s: expected version %d, got %d", name, tidedatadb.BINARY_VERSION_COORDINATES, version)
+		}
+
+		for _, mode := range []tidedatadb.FileMode{tidedatadb.MODE_READONLY, tidedatadb.MODE_MMAP} {
+			tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m2, err := tideDataDb.GetConstituentData(constituents.C_M2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k1, err := tideDataDb.GetConstituentData(constituents.C_K1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			coordinates := m2.Dimensions.Coordinates
+			if coordinates == nil || coordinates != k1.Dimensions.Coordinates {
+				t.Fatalf("%s: expected coordinates shared by all constituents", name)
+			}
+			if coordinates.Curvilinear != dimensions.Coordinates.Curvilinear || len(coordinates.Latitudes) != len(dimensions.Coordinates.Latitudes) {
+				t.Errorf("%s: unexpected coordinates %+v", name, coordinates)
+			}
+			for i := range coordinates.Latitudes {
+				if coordinates.Latitudes[i] != dimensions.Coordinates.Latitudes[i] {
+					t.Errorf("%s: latitude %d: expected %f, got %f", name, i, dimensions.Coordinates.Latitudes[i], coordinates.Latitudes[i])
+				}
+			}
+			// between the grid points 1,1 and 2,2
+			lat1, lon1 := dimensions.LatLonXY(1, 1)
+			lat2, lon2 := dimensions.LatLonXY(2, 2)
+			lat, lon := (lat1+lat2)/2, (lon1+lon2)/2
+			assertInterpolatedLatLon(t, tideDataDb, constituents.C_K1, lat, lon, linearAmplitude(lat, lon), 0)
+			tideDataDb.Close()
+		}
+	}
+}
+
+func TestCopySubsetCoordinates(t *testing.T) {
+	irregular, err := tidedatadb.NewRectilinearDimensions([]float32{50, 54, 56, 57, 60}, []float32{0, 2, 4, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, irregular, constituents.C_M2, constituents.C_K1)
+
+	subsetDb := tidedatadb.NewMemoryTideDataDb()
+	if err := tideDataDb.CopySubset(subsetDb, tidedatadb.BoundingBox{MinLat: 55, MinLon: 1, MaxLat: 57, MaxLon: 3}); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := subsetDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, err := subsetDb.GetConstituentData(constituents.C_K1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dimensions := m2.Dimensions
+	// the rows and columns around the box
+	if dimensions.GridXSize != 3 || dimensions.GridYSize != 3 || dimensions.MinLat != 54 || dimensions.MaxLat != 57 || dimensions.MinLon != 0 || dimensions.MaxLon != 4 {
+		t.Errorf("unexpected subset dimensions %+v", dimensions)
+	}
+	if dimensions.Coordinates == nil || dimensions.Coordinates != k1.Dimensions.Coordinates {
+		t.Fatalf("expected coordinates shared by all constituents")
+	}
+	assertInterpolatedLatLon(t, subsetDb, constituents.C_M2, 55, 3, linearAmplitude(55, 3), 0)
+}
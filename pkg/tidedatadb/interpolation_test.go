@@ -0,0 +1,276 @@
+package tidedatadb_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// interpolates the M2 amplitude at lat/lon with the method and checks it
+func assertAmplitudeWithMethod(t *testing.T, tideDataDb *tidedatadb.TideDataDB, method utils.InterpolationMethod, lat float32, lon float32, expected float64) {
+	t.Helper()
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, err := constituentData.GetDataInterpolatedLatLonWithMethod(lat, lon, method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(datum.Amplitude-expected) > 1e-3 {
+		t.Errorf("%s at %f,%f: expected %f, got %f", method, lat, lon, expected, datum.Amplitude)
+	}
+}
+
+func TestBicubicInterpolation(t *testing.T) {
+	// quadratic along the longitude, bicubic reproduces it within the grid, bilinear does not
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		return []float32{float32(100 + x*x + y), 0}
+	})
+	defer tideDataDb.Close()
+
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 53.5, 3.5, 100+3.5*3.5+3.5)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 57.2, 6.3, 100+6.3*6.3+7.2)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BILINEAR, 53.5, 3.5, 100+(9+16)/2.0+3.5)
+	// on a grid point the values are unchanged
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 53, 4, 100+16+3)
+
+	// the method of the db is used by GetDataInterpolatedLatLon
+	tideDataDb.SetInterpolationMethod(utils.INTERPOLATION_BICUBIC)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 53.5, 3.5, 100+3.5*3.5+3.5, 0)
+
+	// and kept when the db is loaded into memory
+	memoryDb, err := tideDataDb.LoadIntoMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInterpolatedLatLon(t, memoryDb, constituents.C_M2, 53.5, 3.5, 100+3.5*3.5+3.5, 0)
+}
+
+func TestInterpolationMethodsAtCoast(t *testing.T) {
+	// ocean with amplitude x+1 for x < 5
+	tideDataDb := createCoastTestDb(t)
+	defer tideDataDb.Close()
+
+	// all 16 grid points in the ocean
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 52.5, 2.5, 3.5)
+	// the grid points at x=5 are land, bilinear with the ocean corners
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 52.5, 3.5, 4.5)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 52, 4.25, 5)
+
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 52.4, 3.6, 5)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 52.4, 3.4, 4)
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the nearest grid point is land
+	if _, err := constituentData.GetDataInterpolatedLatLonWithMethod(52, 4.6, utils.INTERPOLATION_NEAREST); !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Errorf("expected ErrNoOceanData, got %v", err)
+	}
+	tideDataDb.SetNearestOceanSearchRadius(1)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 52, 4.6, 5)
+}
+
+func TestInterpolationMethodsWrap(t *testing.T) {
+	// quadratic in the distance (in grid points) to the null meridian, symmetric around the seam
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	constituentData, err := tideDataDb.CreateNewConstituentData(globalDimensions, defaultConstituentInfo(constituents.C_M2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := uint64(0); y < globalDimensions.GridYSize; y++ {
+		for x := uint64(0); x < globalDimensions.GridXSize; x++ {
+			distance := math.Min(float64(x), float64(globalDimensions.GridXSize-x))
+			if err := constituentData.WriteDataXY([]float32{float32(100 + distance*distance), 0}, x, y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 15, 355, 100.25)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 15, -5, 100.25)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 15, 5, 100.25)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 15, 357, 100)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 15, 353, 101)
+}
+
+func TestMeshNearestNode(t *testing.T) {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, meshDimensions(t, 4), constituents.C_M2)
+
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 50.2, 4.3, linearAmplitude(50, 4))
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 50.8, 6.6, linearAmplitude(51, 7))
+	// linear within the triangle
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 50.8, 6.6, linearAmplitude(50.8, 6.6))
+}
+
+func TestInterpolationMethodFromString(t *testing.T) {
+	for _, method := range []utils.InterpolationMethod{utils.INTERPOLATION_BILINEAR, utils.INTERPOLATION_BICUBIC, utils.INTERPOLATION_NEAREST} {
+		parsed, err := utils.InterpolationMethodFromString(method.String())
+		if err != nil || parsed != method {
+			t.Errorf("%s: expected %d, got %d (%v)", method, method, parsed, err)
+		}
+	}
+	if _, err := utils.InterpolationMethodFromString("spline"); !errors.Is(err, utils.ErrUnknownInterpolationMethod) {
+		t.Errorf("expected ErrUnknownInterpolationMethod, got %v", err)
+	}
+}
+
+// creates a memory db with the M2 amplitude of amplitude(x, y) and phase 0
+func createFieldDb(t *testing.T, dimensions tidedatadb.Dimensions, amplitude func(x uint64, y uint64) float32) *tidedatadb.TideDataDB {
+	t.Helper()
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, defaultConstituentInfo(constituents.C_M2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := uint64(0); y < dimensions.GridYSize; y++ {
+		for x := uint64(0); x < dimensions.GridXSize; x++ {
+			if err := constituentData.WriteDataXY([]float32{amplitude(x, y), 0}, x, y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return tideDataDb
+}
+
+func columnAmplitude(x uint64, y uint64) float32 {
+	return 100 + float32(x)
+}
+
+func TestIsGlobal(t *testing.T) {
+	for _, test := range []struct {
+		minLon   float32
+		maxLon   float32
+		sizeX    uint64
+		expected bool
+	}{
+		{0, 350, 36, true},
+		{0, 359.75, 1440, true},
+		{-180, 179.5, 720, true},
+		// the seam column twice
+		{0, 360, 37, true},
+		{0, 340, 35, false},
+		{170, 190, 21, false},
+		{0, 0, 1, false},
+	} {
+		dimensions := tidedatadb.Dimensions{MinLon: test.minLon, MaxLon: test.maxLon, GridXSize: test.sizeX, GridYSize: 2}
+		if dimensions.IsGlobal() != test.expected {
+			t.Errorf("%f..%f with %d columns: expected global %t", test.minLon, test.maxLon, test.sizeX, test.expected)
+		}
+	}
+}
+
+func TestInterpolateAntimeridian(t *testing.T) {
+	// 0..350 and -180..170 degree, between the last (x=35) and the first column (x=0) around the globe
+	for _, minLon := range []float32{0, -180} {
+		dimensions := globalDimensions
+		dimensions.MinLon, dimensions.MaxLon = minLon, minLon+350
+		tideDataDb := createFieldDb(t, dimensions, columnAmplitude)
+		for _, lon := range []float32{minLon + 355, minLon - 5, minLon + 715} {
+			assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 15, lon, 117.5, 0)
+		}
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 15, minLon+362.5, 100.25, 0)
+		assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 15, minLon+358, 100)
+		assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 15, minLon-6, 135)
+	}
+
+	// the seam column at 0 and 360 degree
+	dimensions := globalDimensions
+	dimensions.MaxLon, dimensions.GridXSize = 360, 37
+	tideDataDb := createFieldDb(t, dimensions, func(x uint64, y uint64) float32 {
+		return 100 + float32(x%36)
+	})
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 15, -5, 117.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 15, 0, 100, 0)
+
+	// a regional grid crossing the antimeridian
+	regional := tidedatadb.Dimensions{MinLat: -20, MaxLat: -10, MinLon: 170, MaxLon: 190, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 21, GridYSize: 11}
+	tideDataDb = createFieldDb(t, regional, columnAmplitude)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, -15, -175.5, 114.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, -15, 180, 110, 0)
+}
+
+func TestBicubicDuplicateSeamColumn(t *testing.T) {
+	// the same field with the seam column at 0 and 360 degree and without it
+	field := func(x uint64, y uint64) float32 {
+		angle := float64(x%36) * math.Pi / 18
+		return float32(100 + 10*math.Sin(angle) + 5*math.Cos(2*angle+1))
+	}
+	tideDataDb := createFieldDb(t, globalDimensions, field)
+	duplicateDimensions := globalDimensions
+	duplicateDimensions.MaxLon, duplicateDimensions.GridXSize = 360, 37
+	duplicateDb := createFieldDb(t, duplicateDimensions, field)
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lon := range []float32{335, 345, 352, 355, 359.5, 0, 2, 5, 8, 15, 25} {
+		expected, err := constituentData.GetDataInterpolatedLatLonWithMethod(15, lon, utils.INTERPOLATION_BICUBIC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertAmplitudeWithMethod(t, duplicateDb, utils.INTERPOLATION_BICUBIC, 15, lon, expected.Amplitude)
+	}
+}
+
+func TestInterpolatePoles(t *testing.T) {
+	// rows at the centre of 10 degree cells from -85 to 85 degree, the poles are half a row beyond
+	dimensions := tidedatadb.Dimensions{MinLat: -85, MaxLat: 85, MinLon: 0, MaxLon: 350, ResolutionLat: 10, ResolutionLon: 10, GridXSize: 36, GridYSize: 18}
+	tideDataDb := createFieldDb(t, dimensions, columnAmplitude)
+	// with the row on the opposite meridian (x=18)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 90, 0, 109, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 87.5, 0, 104.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, -90, 90, 118, 0)
+	// 185 degree (x=18.5) on the row and 5 degree (x=0.5) on the opposite meridian
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, -87.5, -175, 0.75*118.5+0.25*100.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 85, 5, 100.5, 0)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_NEAREST, 89, 0, 100)
+	assertAmplitudeWithMethod(t, tideDataDb, utils.INTERPOLATION_BICUBIC, 87.5, 0, 104.5)
+
+	// the poles are rows of the grid
+	tideDataDb = createFieldDb(t, globalDimensions, columnAmplitude)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 90, 5, 100.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, -90, 355, 117.5, 0)
+
+	// global in longitude, but without the polar regions
+	dimensions = tidedatadb.Dimensions{MinLat: -60, MaxLat: 60, MinLon: 0, MaxLon: 350, ResolutionLat: 10, ResolutionLon: 10, GridXSize: 36, GridYSize: 13}
+	tideDataDb = createFieldDb(t, dimensions, columnAmplitude)
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lat := range []float32{65, -65, 91} {
+		if _, err := constituentData.GetDataInterpolatedLatLon(lat, 0); !errors.Is(err, utils.ErrOutOfGrid) {
+			t.Errorf("%f: expected ErrOutOfGrid, got %v", lat, err)
+		}
+	}
+}
+
+func TestInterpolateOutOfGrid(t *testing.T) {
+	// lat 50..59 and lon 0..9
+	tideDataDb := createFieldDb(t, regionalDimensions, columnAmplitude)
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []utils.InterpolationMethod{utils.INTERPOLATION_BILINEAR, utils.INTERPOLATION_BICUBIC, utils.INTERPOLATION_NEAREST} {
+		for _, location := range [][2]float32{{61, 5}, {49, 5}, {55, 10.5}, {55, -0.5}, {55, 200}} {
+			if _, err := constituentData.GetDataInterpolatedLatLonWithMethod(location[0], location[1], method); !errors.Is(err, utils.ErrOutOfGrid) {
+				t.Errorf("%s at %f,%f: expected ErrOutOfGrid, got %v", method, location[0], location[1], err)
+			}
+		}
+		// the corners are within the grid
+		assertAmplitudeWithMethod(t, tideDataDb, method, 60, 10, 110)
+		assertAmplitudeWithMethod(t, tideDataDb, method, 50, 0, 100)
+	}
+	// on the regional grid the longitude is not wrapped
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 55, 9.5, 109.5, 0)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 55, 360.5, 100.5, 0)
+}
@@ -0,0 +1,191 @@
+package tidedatadb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+)
+
+// keeps all constituent grids in memory, lookups are plain array indexing
+type memoryBackend struct {
+	lock    sync.RWMutex
+	entries map[constituents.Constituent]memoryEntry
+}
+
+type memoryEntry struct {
+	dimensions      Dimensions
+	constituentInfo ConstituentInfo
+	grid            *memoryGrid
+}
+
+// creates an empty db in memory, e.g. to build a table of harmonic constants without a file
+func NewMemoryTideDataDb() *TideDataDB {
+	return NewTideDataDb(&memoryBackend{entries: make(map[constituents.Constituent]memoryEntry)})
+}
+
+// opens a db and loads all constituents into memory, the file is closed afterwards
+func LoadTideDataDb(filePath string) (*TideDataDB, error) {
+	tideDataDb, err := OpenTideDataDb(filePath, MODE_READONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer tideDataDb.Close()
+	return tideDataDb.LoadIntoMemory()
+}
+
+// opens a db and loads the grid points covering the bounding box into memory, the file is closed afterwards
+func LoadTideDataDbSubset(filePath string, box BoundingBox) (*TideDataDB, error) {
+	tideDataDb, err := OpenTideDataDb(filePath, MODE_READONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer tideDataDb.Close()
+	return tideDataDb.LoadSubsetIntoMemory(box)
+}
+
+// returns all constituents of the db
+func (t *TideDataDB) GetConstituents() ([]constituents.Constituent, error) {
+	found := []constituents.Constituent{}
+	for _, constituent := range constituents.GetAllConstituents() {
+		_, _, _, err := t.backend.GetConstituentGrid(constituent)
+		if err != nil && errors.Is(err, ErrConstituentNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = append(found, constituent)
+	}
+	return found, nil
+}
+
+// copies all constituents of the db into a new in-memory db, the db itself is unchanged
+func (t *TideDataDB) LoadIntoMemory() (*TideDataDB, error) {
+	constituentList, err := t.GetConstituents()
+	if err != nil {
+		return nil, err
+	}
+	backend := &memoryBackend{entries: make(map[constituents.Constituent]memoryEntry)}
+	for _, constituent := range constituentList {
+		dimensions, constituentInfo, grid, err := t.backend.GetConstituentGrid(constituent)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readAll(grid, dimensions)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", constituent, err)
+		}
+		backend.entries[constituent] = memoryEntry{
+			dimensions:      dimensions,
+			constituentInfo: constituentInfo,
+			grid:            &memoryGrid{sizeX: dimensions.GridXSize, sizeY: dimensions.GridYSize, data: data},
+		}
+	}
+	memoryDb := NewTideDataDb(backend)
+	memoryDb.nearestOceanSearchRadius = t.nearestOceanSearchRadius
+	memoryDb.interpolationMethod = t.interpolationMethod
+	return memoryDb, nil
+}
+
+// copies the grid points covering the bounding box of all constituents into a new in-memory db (see CopySubset),
+// e.g. to serve the lookups of a region of a global model without holding the whole grids in memory
+func (t *TideDataDB) LoadSubsetIntoMemory(box BoundingBox) (*TideDataDB, error) {
+	memoryDb := NewMemoryTideDataDb()
+	if err := t.CopySubset(memoryDb, box); err != nil {
+		return nil, err
+	}
+	memoryDb.nearestOceanSearchRadius = t.nearestOceanSearchRadius
+	memoryDb.interpolationMethod = t.interpolationMethod
+	return memoryDb, nil
+}
+
+// reads all grid points, in a single call if the grid supports it
+func readAll(grid Grid, dimensions Dimensions) ([]float32, error) {
+	if bulkReader, ok := grid.(BulkReader); ok {
+		return bulkReader.ReadAll()
+	}
+	data := make([]float32, 0, dimensions.GridXSize*dimensions.GridYSize*2)
+	for y := uint64(0); y < dimensions.GridYSize; y++ {
+		for x := uint64(0); x < dimensions.GridXSize; x++ {
+			value, err := grid.ReadXY(x, y)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, value[0], value[1])
+		}
+	}
+	return data, nil
+}
+
+func (m *memoryBackend) Close() error {
+	return nil
+}
+
+func (m *memoryBackend) GetConstituentGrid(constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entry, ok := m.entries[constituent]
+	if !ok {
+		return Dimensions{}, ConstituentInfo{}, nil, ErrConstituentNotFound
+	}
+	return entry.dimensions, entry.constituentInfo, entry.grid, nil
+}
+
+// adds a new grid, all grid points are initialized with the fill value (or 0 without fill value)
+func (m *memoryBackend) CreateConstituentGrid(dimensions Dimensions, constituentInfo ConstituentInfo) (Grid, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.entries[constituentInfo.Constituent]; ok {
+		return nil, ErrConstituentAlreadyInDb
+	}
+	data := make([]float32, dimensions.GridXSize*dimensions.GridYSize*2)
+	if constituentInfo.HasFillValue {
+		for i := range data {
+			data[i] = constituentInfo.FillValue
+		}
+	}
+	grid := &memoryGrid{sizeX: dimensions.GridXSize, sizeY: dimensions.GridYSize, data: data}
+	m.entries[constituentInfo.Constituent] = memoryEntry{dimensions: dimensions, constituentInfo: constituentInfo, grid: grid}
+	return grid, nil
+}
+
+// amplitude and phase of all grid points, the values of x,y are at index (y*sizeX+x)*2
+type memoryGrid struct {
+	sizeX uint64
+	sizeY uint64
+	data  []float32
+}
+
+func (m *memoryGrid) index(x uint64, y uint64) (uint64, error) {
+	if x >= m.sizeX || y >= m.sizeY {
+		return 0, fmt.Errorf("%w: %d,%d", ErrGridIndexOutOfRange, x, y)
+	}
+	return (y*m.sizeX + x) * 2, nil
+}
+
+// returns a view into the grid without copying, the returned slice must not be modified
+func (m *memoryGrid) ReadXY(x uint64, y uint64) ([]float32, error) {
+	index, err := m.index(x, y)
+	if err != nil {
+		return nil, err
+	}
+	return m.data[index : index+2 : index+2], nil
+}
+
+// writes are not synchronized with reads of the same grid point, grids should be written before they are read concurrently
+func (m *memoryGrid) WriteXY(amplitudePhase []float32, x uint64, y uint64) error {
+	index, err := m.index(x, y)
+	if err != nil {
+		return err
+	}
+	m.data[index] = amplitudePhase[0]
+	m.data[index+1] = amplitudePhase[1]
+	return nil
+}
+
+func (m *memoryGrid) ReadAll() ([]float32, error) {
+	return append([]float32{}, m.data...), nil
+}
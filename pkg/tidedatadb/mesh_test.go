@@ -0,0 +1,235 @@
+package tidedatadb_test
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// two rows of 5 nodes (lat 50..51, lon 4..8) with two triangles between each four nodes,
+// the 10 nodes are stored in a 4x3 grid, the last two grid points are no nodes
+func meshDimensions(t *testing.T, firstLon float32) tidedatadb.Dimensions {
+	t.Helper()
+	const columns, rows = 5, 2
+	latitudes := make([]float32, 0, columns*rows)
+	longitudes := make([]float32, 0, columns*rows)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			latitudes = append(latitudes, 50+float32(row))
+			lon := firstLon + float32(column)
+			if lon > 180 {
+				lon = lon - 360
+			}
+			longitudes = append(longitudes, lon)
+		}
+	}
+	triangles := []uint32{}
+	for column := uint32(0); column < columns-1; column++ {
+		triangles = append(triangles, column, column+1, column+columns+1, column, column+columns+1, column+columns)
+	}
+	dimensions, err := tidedatadb.NewMeshDimensions(latitudes, longitudes, triangles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dimensions
+}
+
+func TestMeshDimensions(t *testing.T) {
+	dimensions := meshDimensions(t, 4)
+	if !dimensions.IsMesh() || dimensions.IsRegular() || dimensions.GridXSize != 4 || dimensions.GridYSize != 3 {
+		t.Fatalf("unexpected mesh dimensions %+v", dimensions)
+	}
+	if x, y := dimensions.NodeXY(9); x != 1 || y != 2 {
+		t.Errorf("expected node 9 at 1,2, got %d,%d", x, y)
+	}
+	if lat, lon := dimensions.LatLonXY(1, 2); lat != 51 || lon != 8 {
+		t.Errorf("expected node 9 at 51,8, got %f,%f", lat, lon)
+	}
+	if lat, _ := dimensions.LatLonXY(3, 2); !math.IsNaN(float64(lat)) {
+		t.Errorf("expected no coordinates behind the last node, got %f", lat)
+	}
+
+	if _, err := tidedatadb.NewMeshDimensions([]float32{50, 51, 50}, []float32{4, 4, 5}, []uint32{0, 1, 3}); !errors.Is(err, tidedatadb.ErrInvalidCoordinates) {
+		t.Errorf("expected ErrInvalidCoordinates, got %v", err)
+	}
+	if _, err := tidedatadb.NewMeshDimensions([]float32{50, 51, 50}, []float32{4, 4, 5}, []uint32{0, 1}); !errors.Is(err, tidedatadb.ErrInvalidCoordinates) {
+		t.Errorf("expected ErrInvalidCoordinates, got %v", err)
+	}
+}
+
+func TestMeshInterpolation(t *testing.T) {
+	dimensions := meshDimensions(t, 4)
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, dimensions, constituents.C_M2)
+
+	// barycentric interpolation reproduces the linear field
+	for _, location := range [][2]float32{{50.3, 4.2}, {50.9, 7.7}, {50.5, 6.5}, {51, 5}} {
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, location[0], location[1], linearAmplitude(location[0], location[1]), 0)
+	}
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constituentData.GetDataInterpolatedLatLon(51.5, 6); !errors.Is(err, utils.ErrOutOfGrid) {
+		t.Errorf("expected ErrOutOfGrid, got %v", err)
+	}
+	// next to the mesh the nearest node is used
+	tideDataDb.SetNearestOceanSearchRadius(1)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 51.3, 6.1, linearAmplitude(51, 6), 0)
+	constituentData, err = tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constituentData.GetDataInterpolatedLatLon(53, 6); !errors.Is(err, utils.ErrOutOfGrid) {
+		t.Errorf("expected ErrOutOfGrid, got %v", err)
+	}
+}
+
+func TestMeshUndefinedNodes(t *testing.T) {
+	dimensions := meshDimensions(t, 4)
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	constituentInfo := defaultConstituentInfo(constituents.C_M2)
+	constituentInfo.HasFillValue = true
+	constituentInfo.FillValue = -1
+	constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, constituentInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the nodes at lon 7 and 8 are land
+	for node := uint64(0); node < 10; node++ {
+		x, y := dimensions.NodeXY(node)
+		amplitude := float32(10)
+		if node%5 >= 3 {
+			amplitude = -1
+		}
+		if err := constituentData.WriteDataXY([]float32{amplitude, 0}, x, y); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one of three nodes is land
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 50.1, 6.2, 10, 0)
+	constituentData, err = tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := constituentData.GetDataInterpolatedLatLon(50.5, 7.5); !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Errorf("expected ErrNoOceanData, got %v", err)
+	}
+	// ~1.1 degree to the nearest node in the ocean
+	tideDataDb.SetNearestOceanSearchRadius(1.5)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 50.5, 7.5, 10, 0)
+}
+
+func TestMeshAntimeridian(t *testing.T) {
+	// nodes from lon 178 to -178
+	dimensions := meshDimensions(t, 178)
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	constituentData, err := tideDataDb.CreateNewConstituentData(dimensions, defaultConstituentInfo(constituents.C_M2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for node := uint64(0); node < 10; node++ {
+		x, y := dimensions.NodeXY(node)
+		lat, _ := dimensions.LatLonXY(x, y)
+		if err := constituentData.WriteDataXY([]float32{float32(linearAmplitude(lat, 0)), 0}, x, y); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, location := range [][2]float32{{50.25, 179.5}, {50.5, 180}, {50.75, -179.5}, {50.5, -178.5}} {
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, location[0], location[1], linearAmplitude(location[0], 0), 0)
+	}
+}
+
+func TestBinaryMesh(t *testing.T) {
+	dimensions := meshDimensions(t, 4)
+	filePath := filepath.Join(t.TempDir(), "mesh.db")
+	tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_BINARY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLinearField(t, tideDataDb, dimensions, constituents.C_M2, constituents.C_K1)
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if version := readBinaryVersion(t, filePath); version != tidedatadb.BINARY_VERSION_COORDINATES {
+		t.Errorf("expected version %d, got %d", tidedatadb.BINARY_VERSION_COORDINATES, version)
+	}
+
+	for _, mode := range []tidedatadb.FileMode{tidedatadb.MODE_READONLY, tidedatadb.MODE_MMAP} {
+		tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m2, err := tideDataDb.GetConstituentData(constituents.C_M2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k1, err := tideDataDb.GetConstituentData(constituents.C_K1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m2.Dimensions.IsMesh() || m2.Dimensions.Coordinates != k1.Dimensions.Coordinates {
+			t.Fatalf("expected mesh coordinates shared by all constituents")
+		}
+		triangles := m2.Dimensions.Coordinates.Triangles
+		for i, node := range dimensions.Coordinates.Triangles {
+			if triangles[i] != node {
+				t.Fatalf("expected triangles %v, got %v", dimensions.Coordinates.Triangles, triangles)
+			}
+		}
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_K1, 50.4, 5.7, linearAmplitude(50.4, 5.7), 0)
+		tideDataDb.Close()
+	}
+}
+
+func TestCopySubsetMesh(t *testing.T) {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, tideDataDb, meshDimensions(t, 4), constituents.C_M2)
+
+	subsetDb := tidedatadb.NewMemoryTideDataDb()
+	if err := tideDataDb.CopySubset(subsetDb, tidedatadb.BoundingBox{MinLat: 50, MinLon: 5.5, MaxLat: 51, MaxLon: 5.8}); err != nil {
+		t.Fatal(err)
+	}
+	constituentData, err := subsetDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the two triangles between lon 5 and 6
+	dimensions := constituentData.Dimensions
+	if !dimensions.IsMesh() || len(dimensions.Coordinates.Triangles) != 6 || dimensions.MinLon != 5 || dimensions.MaxLon != 6 {
+		t.Fatalf("unexpected subset dimensions %+v", dimensions)
+	}
+	assertInterpolatedLatLon(t, subsetDb, constituents.C_M2, 50.6, 5.6, linearAmplitude(50.6, 5.6), 0)
+	if _, err := constituentData.GetDataInterpolatedLatLon(50.5, 6.5); !errors.Is(err, utils.ErrOutOfGrid) {
+		t.Errorf("expected ErrOutOfGrid, got %v", err)
+	}
+}
+
+func TestCompositeMesh(t *testing.T) {
+	global := createConstantDb(t, globalDimensions, 50, 0, constituents.C_M2)
+	// only the first triangle between lon 4 and 5 of the mesh
+	coordinates := meshDimensions(t, 4).Coordinates
+	dimensions, err := tidedatadb.NewMeshDimensions(coordinates.Latitudes[:10], coordinates.Longitudes[:10], coordinates.Triangles[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh := tidedatadb.NewMemoryTideDataDb()
+	writeLinearField(t, mesh, dimensions, constituents.C_M2)
+	composite, err := tidedatadb.NewCompositeTideDataDb(
+		tidedatadb.Layer{Db: global},
+		tidedatadb.Layer{Db: mesh, LayerInfo: tidedatadb.LayerInfo{Priority: 1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAmplitudeAt(t, composite, constituents.C_M2, 50.2, 4.5, linearAmplitude(50.2, 4.5))
+	// within the extent of the mesh, but outside of its triangle
+	assertAmplitudeAt(t, composite, constituents.C_M2, 50.5, 7, 50)
+}
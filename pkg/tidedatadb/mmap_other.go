@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package tidedatadb
+
+import (
+	"os"
+)
+
+// platforms without mmap read the whole file into memory instead
+func mmapFile(file *os.File) ([]byte, func() error, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	data := make([]byte, info.Size())
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,382 @@
+//go:build cgo && !nonetcdf
+
+package tidedatadb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/fhs/go-netcdf/netcdf"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
+
+// the netcdf c library keeps global state and is not thread-safe,
+// so all calls into the library are guarded by this lock
+var netcdfLock sync.Mutex
+
+func init() {
+	openBackend[FORMAT_NETCDF] = openNetcdfBackend
+	createBackend[FORMAT_NETCDF] = createNetcdfBackend
+}
+
+// stores all constituents as variables (lat, lon, data) of a netcdf file,
+// all constituents share the lat/lon dimensions of the first constituent
+type netcdfBackend struct {
+	file *netcdf.Dataset
+	// the lat/lon dimensions never change once created, they are only read once
+	dimensions *Dimensions
+}
+
+func openNetcdfBackend(filePath string, mode FileMode) (Backend, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	netcdfMode := netcdf.NOWRITE
+	if mode == MODE_READWRITE {
+		netcdfMode = netcdf.WRITE
+	}
+	file, err := netcdf.OpenFile(filePath, netcdf.NETCDF4|netcdfMode)
+	if err != nil {
+		return nil, err
+	}
+	return &netcdfBackend{file: &file}, nil
+}
+
+func createNetcdfBackend(filePath string) (Backend, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	file, err := netcdf.CreateFile(filePath, netcdf.NETCDF4)
+	if err != nil {
+		return nil, err
+	}
+	return &netcdfBackend{file: &file}, nil
+}
+
+func (n *netcdfBackend) Close() error {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+	return n.file.Close()
+}
+
+func (n *netcdfBackend) GetConstituentGrid(constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	variable, err := n.file.Var(constituent.String())
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, ErrConstituentNotFound
+	}
+
+	attrAmpUnit, err := utils.NetcdfGetStringFromAttribute(ATTR_UNIT_AMPLITUDE, &variable)
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+	attrPhaseUnit, err := utils.NetcdfGetStringFromAttribute(ATTR_UNIT_PHASE, &variable)
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+
+	ampUnit, err := ConstituentAmplitudeUnitFromString(attrAmpUnit)
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+	phaseUnit, err := ConstituentPhaseUnitFromString(attrPhaseUnit)
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+
+	hasFillValue := true
+	fillValue, err := utils.NetcdfGetFloat32FromAttribute(ATTR_FILL_VALUE, &variable)
+	if err != nil && errors.Is(err, utils.ErrNetcdfAttributeNotFound) {
+		hasFillValue = false
+		fillValue = []float32{0}
+	} else if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+
+	dimensions, err := n.readDimensions()
+	if err != nil {
+		return Dimensions{}, ConstituentInfo{}, nil, err
+	}
+
+	return dimensions, ConstituentInfo{
+		Constituent:   constituent,
+		AmplitudeUnit: ampUnit,
+		PhaseUnit:     phaseUnit,
+		HasFillValue:  hasFillValue,
+		FillValue:     fillValue[0],
+	}, &netcdfGrid{variable: &variable}, nil
+}
+
+// 2d coordinate variables (lat, lon) of curvilinear grids, lat and lon then only index the grid points
+const VAR_LATITUDE_2D = "lat_2d"
+const VAR_LONGITUDE_2D = "lon_2d"
+
+// node indices of the triangles of meshes (triangle, vertex), the nodes are stored in lat_2d and lon_2d
+const VAR_TRIANGLES = "triangles"
+
+func (n *netcdfBackend) readDimensions() (Dimensions, error) {
+	if n.dimensions != nil {
+		return *n.dimensions, nil
+	}
+	latVar, err := n.file.Var("lat")
+	if err != nil {
+		return Dimensions{}, err
+	}
+	lonVar, err := n.file.Var("lon")
+	if err != nil {
+		return Dimensions{}, err
+	}
+	latitudes, err := utils.NetcdfReadFloat32s(&latVar)
+	if err != nil {
+		return Dimensions{}, err
+	}
+	longitudes, err := utils.NetcdfReadFloat32s(&lonVar)
+	if err != nil {
+		return Dimensions{}, err
+	}
+
+	var dimensions Dimensions
+	if lat2dVar, err := n.file.Var(VAR_LATITUDE_2D); err == nil {
+		lon2dVar, err := n.file.Var(VAR_LONGITUDE_2D)
+		if err != nil {
+			return Dimensions{}, err
+		}
+		latitudes2d, err := utils.NetcdfReadFloat32s(&lat2dVar)
+		if err != nil {
+			return Dimensions{}, err
+		}
+		longitudes2d, err := utils.NetcdfReadFloat32s(&lon2dVar)
+		if err != nil {
+			return Dimensions{}, err
+		}
+		if trianglesVar, err := n.file.Var(VAR_TRIANGLES); err == nil {
+			triangles, err := readTriangles(&trianglesVar)
+			if err != nil {
+				return Dimensions{}, err
+			}
+			dimensions, err = meshDimensions(uint64(len(longitudes)), uint64(len(latitudes)), latitudes2d, longitudes2d, triangles)
+			if err != nil {
+				return Dimensions{}, err
+			}
+		} else {
+			dimensions, err = NewCurvilinearDimensions(uint64(len(longitudes)), uint64(len(latitudes)), latitudes2d, longitudes2d)
+			if err != nil {
+				return Dimensions{}, err
+			}
+		}
+	} else {
+		dimensions, err = NewRectilinearDimensions(latitudes, longitudes)
+		if err != nil {
+			return Dimensions{}, err
+		}
+	}
+	n.dimensions = &dimensions
+	return *n.dimensions, nil
+}
+
+func (n *netcdfBackend) CreateConstituentGrid(dimensionsToCreate Dimensions, constituentInfoToCreate ConstituentInfo) (Grid, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	if _, err := n.file.Var(constituentInfoToCreate.Constituent.String()); err == nil {
+		return nil, ErrConstituentAlreadyInDb
+	}
+
+	// create dimensions if not exist
+	var dimLat netcdf.Dim
+	var dimLon netcdf.Dim
+	var dimData netcdf.Dim
+	var err error
+
+	if dimLat, err = n.file.Dim("lat"); err != nil {
+		dimLat, err = n.file.AddDim("lat", dimensionsToCreate.GridYSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dimLon, err = n.file.Dim("lon"); err != nil {
+		dimLon, err = n.file.AddDim("lon", dimensionsToCreate.GridXSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dimData, err = n.file.Dim("data"); err != nil {
+		dimData, err = n.file.AddDim("data", 2)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// create dimension data if not exist
+	if _, err := n.file.Var("lat"); err != nil {
+		dimLatVar, err := n.file.AddVar("lat", netcdf.DOUBLE, []netcdf.Dim{dimLat})
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(dimensionsToCreate.GridYSize); i++ {
+			lat := float64(dimensionsToCreate.MinLat) + (float64(i) * float64(dimensionsToCreate.ResolutionLat))
+			if coordinates := dimensionsToCreate.Coordinates; coordinates != nil && !coordinates.Curvilinear {
+				lat = float64(coordinates.Latitudes[i])
+			}
+			dimLatVar.WriteFloat64At([]uint64{uint64(i)}, lat)
+		}
+	}
+	if _, err := n.file.Var("lon"); err != nil {
+		dimLonVar, err := n.file.AddVar("lon", netcdf.DOUBLE, []netcdf.Dim{dimLon})
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(dimensionsToCreate.GridXSize); i++ {
+			lon := float64(dimensionsToCreate.MinLon) + (float64(i) * float64(dimensionsToCreate.ResolutionLon))
+			if coordinates := dimensionsToCreate.Coordinates; coordinates != nil && !coordinates.Curvilinear {
+				lon = float64(coordinates.Longitudes[i])
+			}
+			dimLonVar.WriteFloat64At([]uint64{uint64(i)}, lon)
+		}
+	}
+	if coordinates := dimensionsToCreate.Coordinates; coordinates != nil && coordinates.Curvilinear {
+		if _, err := n.file.Var(VAR_LATITUDE_2D); err != nil {
+			if err := addCoordinateVariable(n.file, VAR_LATITUDE_2D, []netcdf.Dim{dimLat, dimLon}, coordinates.Latitudes); err != nil {
+				return nil, err
+			}
+			if err := addCoordinateVariable(n.file, VAR_LONGITUDE_2D, []netcdf.Dim{dimLat, dimLon}, coordinates.Longitudes); err != nil {
+				return nil, err
+			}
+			if coordinates.Triangles != nil {
+				if err := addTriangleVariable(n.file, coordinates.Triangles); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	constituentVariable, err := n.file.AddVar(constituentInfoToCreate.Constituent.String(), netcdf.FLOAT, []netcdf.Dim{dimLat, dimLon, dimData})
+	if err != nil {
+		return nil, err
+	}
+
+	attrUnitAmplitude := constituentVariable.Attr(ATTR_UNIT_AMPLITUDE)
+	err = attrUnitAmplitude.WriteBytes([]byte(constituentInfoToCreate.AmplitudeUnit.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	attrUnitPhase := constituentVariable.Attr(ATTR_UNIT_PHASE)
+	err = attrUnitPhase.WriteBytes([]byte(constituentInfoToCreate.PhaseUnit.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	if constituentInfoToCreate.HasFillValue {
+		err = constituentVariable.Attr(ATTR_FILL_VALUE).WriteFloat32s([]float32{constituentInfoToCreate.FillValue})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &netcdfGrid{variable: &constituentVariable}, nil
+}
+
+func addCoordinateVariable(file *netcdf.Dataset, name string, dimensions []netcdf.Dim, values []float32) error {
+	variable, err := file.AddVar(name, netcdf.DOUBLE, dimensions)
+	if err != nil {
+		return err
+	}
+	data := make([]float64, len(values))
+	for i, value := range values {
+		data[i] = float64(value)
+	}
+	return variable.WriteFloat64s(data)
+}
+
+func addTriangleVariable(file *netcdf.Dataset, triangles []uint32) error {
+	dimTriangle, err := file.AddDim("triangle", uint64(len(triangles)/3))
+	if err != nil {
+		return err
+	}
+	dimVertex, err := file.AddDim("vertex", 3)
+	if err != nil {
+		return err
+	}
+	variable, err := file.AddVar(VAR_TRIANGLES, netcdf.INT, []netcdf.Dim{dimTriangle, dimVertex})
+	if err != nil {
+		return err
+	}
+	data := make([]int32, len(triangles))
+	for i, node := range triangles {
+		data[i] = int32(node)
+	}
+	return variable.WriteInt32s(data)
+}
+
+func readTriangles(variable *netcdf.Var) ([]uint32, error) {
+	length, err := variable.Len()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]int32, length)
+	if err := variable.ReadInt32s(data); err != nil {
+		return nil, err
+	}
+	triangles := make([]uint32, length)
+	for i, node := range data {
+		if node < 0 {
+			return nil, fmt.Errorf("%w: negative node index", ErrInvalidCoordinates)
+		}
+		triangles[i] = uint32(node)
+	}
+	return triangles, nil
+}
+
+type netcdfGrid struct {
+	variable *netcdf.Var
+}
+
+func (n *netcdfGrid) WriteXY(amplitudePhase []float32, x uint64, y uint64) error {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	err := n.variable.WriteFloat32At([]uint64{y, x, 0}, amplitudePhase[0])
+	if err != nil {
+		return err
+	}
+	err = n.variable.WriteFloat32At([]uint64{y, x, 1}, amplitudePhase[1])
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (n *netcdfGrid) ReadXY(x uint64, y uint64) ([]float32, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	amp, err := n.variable.ReadFloat32At([]uint64{y, x, 0})
+	if err != nil {
+		return nil, err
+	}
+	phase, err := n.variable.ReadFloat32At([]uint64{y, x, 1})
+	if err != nil {
+		return nil, err
+	}
+	return []float32{amp, phase}, nil
+}
+
+// the variable is stored as [lat][lon][amplitude/phase], the layout of BulkReader
+func (n *netcdfGrid) ReadAll() ([]float32, error) {
+	netcdfLock.Lock()
+	defer netcdfLock.Unlock()
+
+	size, err := n.variable.Len()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]float32, size)
+	if err := n.variable.ReadFloat32s(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
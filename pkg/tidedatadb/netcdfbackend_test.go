@@ -0,0 +1,131 @@
+//go:build cgo && !nonetcdf
+
+package tidedatadb_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+)
+
+func TestNetcdfBackendRoundTrip(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.nc")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	m2.HasFillValue = true
+	m2.FillValue = 999
+	writeDb(t, filePath, tidedatadb.FORMAT_NETCDF, m2)
+
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	assertMultiChunkGrid(t, tideDataDb, m2)
+}
+
+// new dbs with the .nc extension are created as netcdf
+func TestOpenTideDataDbCreatesNetcdf(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.nc")
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tideDataDb.CreateNewConstituentData(multiChunkDimensions, defaultConstituentInfo(constituents.C_M2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	format, err := tidedatadb.DetectDbFormat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != tidedatadb.FORMAT_NETCDF {
+		t.Errorf("expected netcdf, got %s", format)
+	}
+}
+
+func TestNetcdfLoadIntoMemory(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.nc")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	writeDb(t, filePath, tidedatadb.FORMAT_NETCDF, m2)
+
+	tideDataDb, err := tidedatadb.LoadTideDataDb(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	assertMultiChunkGrid(t, tideDataDb, m2)
+
+	if _, err := tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_MMAP); !errors.Is(err, tidedatadb.ErrMmapNotSupported) {
+		t.Errorf("expected ErrMmapNotSupported, got %v", err)
+	}
+}
+
+func TestNetcdfCoordinates(t *testing.T) {
+	directory := t.TempDir()
+	irregular, err := tidedatadb.NewRectilinearDimensions([]float32{50, 54, 56, 57}, []float32{0, 2, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	curvilinear := curvilinearDimensions(t)
+	for name, dimensions := range map[string]tidedatadb.Dimensions{"rectilinear": irregular, "curvilinear": curvilinear} {
+		filePath := filepath.Join(directory, name+".nc")
+		tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_NETCDF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeLinearField(t, tideDataDb, dimensions, constituents.C_M2)
+		if err := tideDataDb.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+		if err != nil {
+			t.Fatal(err)
+		}
+		constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coordinates := constituentData.Dimensions.Coordinates
+		if coordinates == nil || coordinates.Curvilinear != dimensions.Coordinates.Curvilinear || len(coordinates.Longitudes) != len(dimensions.Coordinates.Longitudes) {
+			t.Fatalf("%s: unexpected coordinates %+v", name, coordinates)
+		}
+		lat1, lon1 := dimensions.LatLonXY(1, 1)
+		lat2, lon2 := dimensions.LatLonXY(2, 2)
+		lat, lon := (lat1+lat2)/2, (lon1+lon2)/2
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, lat, lon, linearAmplitude(lat, lon), 0)
+		tideDataDb.Close()
+	}
+}
+
+func TestNetcdfMesh(t *testing.T) {
+	dimensions := meshDimensions(t, 4)
+	filePath := filepath.Join(t.TempDir(), "mesh.nc")
+	tideDataDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_NETCDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLinearField(t, tideDataDb, dimensions, constituents.C_M2)
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !constituentData.Dimensions.IsMesh() || len(constituentData.Dimensions.Coordinates.Triangles) != len(dimensions.Coordinates.Triangles) {
+		t.Fatalf("unexpected mesh %+v", constituentData.Dimensions.Coordinates)
+	}
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 50.4, 5.7, linearAmplitude(50.4, 5.7), 0)
+}
@@ -0,0 +1,272 @@
+package tidedatadb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidBoundingBox     = errors.New("invalid bounding box, expected minLat,minLon,maxLat,maxLon")
+	ErrBoundingBoxOutsideGrid = errors.New("bounding box outside of the grid")
+)
+
+// tolerance in grid points for rounding the bounding box to the grid
+const gridEpsilon = 1e-4
+
+// rectangular area in degree, a box crossing the antimeridian has MinLon > MaxLon (e.g. 170 to -170)
+type BoundingBox struct {
+	MinLat float32
+	MinLon float32
+	MaxLat float32
+	MaxLon float32
+}
+
+// parses a bounding box in the form minLat,minLon,maxLat,maxLon
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, ErrInvalidBoundingBox
+	}
+	values := [4]float32{}
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return BoundingBox{}, fmt.Errorf("%w: %s", ErrInvalidBoundingBox, err)
+		}
+		values[i] = float32(parsed)
+	}
+	box := BoundingBox{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}
+	if box.MinLat > box.MaxLat || box.MinLat < -90 || box.MaxLat > 90 {
+		return BoundingBox{}, fmt.Errorf("%w: latitudes must be ascending between -90 and 90", ErrInvalidBoundingBox)
+	}
+	if math.Abs(float64(box.MinLon)) > 360 || math.Abs(float64(box.MaxLon)) > 360 {
+		return BoundingBox{}, fmt.Errorf("%w: longitudes must be between -360 and 360", ErrInvalidBoundingBox)
+	}
+	return box, nil
+}
+
+// returns the extent of the box in longitude, boxes crossing the antimeridian continue east of 180
+func (b BoundingBox) LonSpan() float32 {
+	if b.MaxLon >= b.MinLon {
+		return b.MaxLon - b.MinLon
+	}
+	return b.MaxLon - b.MinLon + 360
+}
+
+// grid points of a source grid covering a bounding box, x0 may exceed the grid of a global
+// source and is wrapped around the globe
+type gridWindow struct {
+	x0     int64
+	y0     int64
+	sizeX  uint64
+	sizeY  uint64
+	period int64
+	// source grid points of the nodes of a mesh subset, in the order of the nodes
+	nodes [][2]uint64
+}
+
+// returns the source grid point of the grid point x,y of the window, false for grid points
+// without a source (behind the last node of a mesh)
+func (w gridWindow) sourceXY(x uint64, y uint64) (uint64, uint64, bool) {
+	if w.nodes != nil {
+		node := y*w.sizeX + x
+		if node >= uint64(len(w.nodes)) {
+			return 0, 0, false
+		}
+		return w.nodes[node][0], w.nodes[node][1], true
+	}
+	sourceX := w.x0 + int64(x)
+	if w.period > 0 {
+		sourceX = sourceX % w.period
+	}
+	return uint64(sourceX), uint64(w.y0 + int64(y)), true
+}
+
+// returns true if lat/lon is within the box
+func (b BoundingBox) contains(lat float32, lon float32) bool {
+	offset := math.Mod(float64(lon-b.MinLon), 360)
+	if offset < 0 {
+		offset = offset + 360
+	}
+	return lat >= b.MinLat && lat <= b.MaxLat && offset <= float64(b.LonSpan())
+}
+
+// returns the dimensions of the window of the bounding box and the grid points to copy, the window covers
+// the whole bounding box if the grid does and has at least two grid points in each direction for the interpolation
+func subsetWindow(dimensions Dimensions, box BoundingBox) (Dimensions, gridWindow, error) {
+	if dimensions.IsMesh() {
+		return meshWindow(dimensions, box)
+	} else if !dimensions.IsRegular() {
+		return coordinateWindow(dimensions, box)
+	}
+	resolutionLat := float64(dimensions.ResolutionLat)
+	resolutionLon := float64(dimensions.ResolutionLon)
+
+	y0 := int64(math.Floor((float64(box.MinLat-dimensions.MinLat))/resolutionLat + gridEpsilon))
+	y1 := int64(math.Ceil((float64(box.MaxLat-dimensions.MinLat))/resolutionLat - gridEpsilon))
+	y0, y1, ok := clampWindow(y0, y1, int64(dimensions.GridYSize))
+	if !ok {
+		return Dimensions{}, gridWindow{}, ErrBoundingBoxOutsideGrid
+	}
+
+	// offset of the box from the west edge of the grid, in [0,360)
+	start := math.Mod(float64(box.MinLon-dimensions.MinLon), 360)
+	if start < 0 {
+		start = start + 360
+	}
+	window := gridWindow{}
+	var x0, x1 int64
+	if dimensions.IsGlobal() {
+		window.period = int64(math.Round(360 / math.Abs(resolutionLon)))
+		x0 = int64(math.Floor(start/resolutionLon + gridEpsilon))
+		x1 = int64(math.Ceil((start+float64(box.LonSpan()))/resolutionLon - gridEpsilon))
+		if x1-x0+1 > window.period {
+			x1 = x0 + window.period - 1
+		}
+		if x1 == x0 {
+			x1 = x0 + 1
+		}
+	} else {
+		// a box starting east of the grid may overlap it from the west
+		if start > float64(dimensions.MaxLon-dimensions.MinLon) {
+			start = start - 360
+		}
+		x0 = int64(math.Floor(start/resolutionLon + gridEpsilon))
+		x1 = int64(math.Ceil((start+float64(box.LonSpan()))/resolutionLon - gridEpsilon))
+		x0, x1, ok = clampWindow(x0, x1, int64(dimensions.GridXSize))
+		if !ok {
+			return Dimensions{}, gridWindow{}, ErrBoundingBoxOutsideGrid
+		}
+	}
+
+	window.x0 = x0
+	window.y0 = y0
+	window.sizeX = uint64(x1 - x0 + 1)
+	window.sizeY = uint64(y1 - y0 + 1)
+	minLat := float64(dimensions.MinLat) + float64(y0)*resolutionLat
+	minLon := float64(dimensions.MinLon) + float64(x0)*resolutionLon
+	return Dimensions{
+		MinLat:        float32(minLat),
+		MaxLat:        float32(minLat + float64(window.sizeY-1)*resolutionLat),
+		MinLon:        float32(minLon),
+		MaxLon:        float32(minLon + float64(window.sizeX-1)*resolutionLon),
+		ResolutionLat: dimensions.ResolutionLat,
+		ResolutionLon: dimensions.ResolutionLon,
+		GridXSize:     window.sizeX,
+		GridYSize:     window.sizeY,
+	}, window, nil
+}
+
+// clamps the first and last grid point of a window to a grid of size points and
+// widens single point windows, returns false if the window is outside the grid
+func clampWindow(first int64, last int64, size int64) (int64, int64, bool) {
+	if first < 0 {
+		first = 0
+	}
+	if last > size-1 {
+		last = size - 1
+	}
+	if first > last {
+		return 0, 0, false
+	}
+	if first == last {
+		if last < size-1 {
+			last = last + 1
+		} else if first > 0 {
+			first = first - 1
+		}
+	}
+	return first, last, true
+}
+
+// copies the grid points covering the bounding box into a new constituent of destination
+func (c *ConstituentData) CopySubset(destination *TideDataDB, box BoundingBox) (*ConstituentData, error) {
+	return c.copySubset(destination, box, map[*GridCoordinates]Dimensions{})
+}
+
+// subsets of grids with coordinates are cached, so the constituents of a model keep sharing their coordinates
+func (c *ConstituentData) copySubset(destination *TideDataDB, box BoundingBox, subsets map[*GridCoordinates]Dimensions) (*ConstituentData, error) {
+	dimensions, window, err := subsetWindow(c.Dimensions, box)
+	if err != nil {
+		return nil, err
+	}
+	if c.Dimensions.Coordinates != nil {
+		if subset, ok := subsets[c.Dimensions.Coordinates]; ok {
+			dimensions = subset
+		}
+		subsets[c.Dimensions.Coordinates] = dimensions
+	}
+	subset, err := destination.CreateNewConstituentData(dimensions, c.ConstituentInfo)
+	if err != nil {
+		return nil, err
+	}
+	for y := uint64(0); y < window.sizeY; y++ {
+		for x := uint64(0); x < window.sizeX; x++ {
+			sourceX, sourceY, ok := window.sourceXY(x, y)
+			if !ok {
+				continue
+			}
+			value, err := c.GetDataXY(sourceX, sourceY)
+			if err != nil {
+				return nil, err
+			}
+			if err := subset.WriteDataXY(value, x, y); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return subset, nil
+}
+
+// copies the grid points covering the bounding box of every constituent into destination,
+// e.g. to extract a small regional db from a global model
+func (t *TideDataDB) CopySubset(destination *TideDataDB, box BoundingBox) error {
+	subsets := map[*GridCoordinates]Dimensions{}
+	return t.copyConstituents(func(constituentData *ConstituentData) error {
+		_, err := constituentData.copySubset(destination, box, subsets)
+		return err
+	})
+}
+
+// copies all constituents into destination, e.g. to convert the format of a db
+func (t *TideDataDB) CopyTo(destination *TideDataDB) error {
+	return t.copyConstituents(func(constituentData *ConstituentData) error {
+		data, err := readAll(constituentData.grid, constituentData.Dimensions)
+		if err != nil {
+			return err
+		}
+		copied, err := destination.CreateNewConstituentData(constituentData.Dimensions, constituentData.ConstituentInfo)
+		if err != nil {
+			return err
+		}
+		for y := uint64(0); y < constituentData.Dimensions.GridYSize; y++ {
+			for x := uint64(0); x < constituentData.Dimensions.GridXSize; x++ {
+				index := (y*constituentData.Dimensions.GridXSize + x) * 2
+				if err := copied.WriteDataXY(data[index:index+2], x, y); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (t *TideDataDB) copyConstituents(copyConstituent func(constituentData *ConstituentData) error) error {
+	constituentList, err := t.GetConstituents()
+	if err != nil {
+		return err
+	}
+	for _, constituent := range constituentList {
+		constituentData, err := t.GetConstituentData(constituent)
+		if err != nil {
+			return err
+		}
+		if err := copyConstituent(constituentData); err != nil {
+			return fmt.Errorf("%s: %w", constituent, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package tidedatadb_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+)
+
+// global 10 degree grid, the amplitude is the longitude and the phase the latitude of the grid point
+func createGlobalDb(t *testing.T, minLon float32) *tidedatadb.TideDataDB {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	constituentData, err := tideDataDb.CreateNewConstituentData(tidedatadb.Dimensions{
+		MinLat:        -90,
+		MaxLat:        90,
+		MinLon:        minLon,
+		MaxLon:        minLon + 350,
+		ResolutionLat: 10,
+		ResolutionLon: 10,
+		GridXSize:     36,
+		GridYSize:     19,
+	}, defaultConstituentInfo(constituents.C_M2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := uint64(0); y < 19; y++ {
+		for x := uint64(0); x < 36; x++ {
+			lon := minLon + float32(x)*10
+			if lon < 0 {
+				lon = lon + 360
+			}
+			if err := constituentData.WriteDataXY([]float32{lon, -90 + float32(y)*10}, x, y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return tideDataDb
+}
+
+func assertSubset(t *testing.T, subsetDb *tidedatadb.TideDataDB, expected tidedatadb.Dimensions) {
+	t.Helper()
+	constituentData, err := subsetDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if constituentData.Dimensions != expected {
+		t.Fatalf("expected dimensions %+v, got %+v", expected, constituentData.Dimensions)
+	}
+	// the values are the coordinates of the source grid point
+	for y := uint64(0); y < expected.GridYSize; y++ {
+		for x := uint64(0); x < expected.GridXSize; x++ {
+			value, err := constituentData.GetDataXY(x, y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lon := float64(expected.MinLon + float32(x)*expected.ResolutionLon)
+			lat := expected.MinLat + float32(y)*expected.ResolutionLat
+			if float64(value[0]) != math.Mod(lon+360, 360) || value[1] != lat {
+				t.Errorf("value at %d,%d: expected %f,%f, got %v", x, y, lon, lat, value)
+			}
+		}
+	}
+}
+
+func TestCopySubsetAntimeridian(t *testing.T) {
+	expected := tidedatadb.Dimensions{
+		MinLat: 10, MaxLat: 30, MinLon: 170, MaxLon: 190, ResolutionLat: 10, ResolutionLon: 10, GridXSize: 3, GridYSize: 3,
+	}
+	box := tidedatadb.BoundingBox{MinLat: 12, MinLon: 172, MaxLat: 28, MaxLon: -172}
+	// the same window from grids starting at 0 and at -180 degree
+	for _, minLon := range []float32{0, -180} {
+		tideDataDb := createGlobalDb(t, minLon)
+		subsetDb := tidedatadb.NewMemoryTideDataDb()
+		if err := tideDataDb.CopySubset(subsetDb, box); err != nil {
+			t.Fatal(err)
+		}
+		assertSubset(t, subsetDb, expected)
+
+		// lookups on both sides of the antimeridian
+		assertInterpolatedLatLon(t, subsetDb, constituents.C_M2, 20, 175, 175, 20)
+		assertInterpolatedLatLon(t, subsetDb, constituents.C_M2, 20, -175, 185, 20)
+	}
+}
+
+func TestCopySubsetWholeGlobe(t *testing.T) {
+	subsetDb := tidedatadb.NewMemoryTideDataDb()
+	if err := createGlobalDb(t, 0).CopySubset(subsetDb, tidedatadb.BoundingBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}); err != nil {
+		t.Fatal(err)
+	}
+	// the window is limited to a single turn around the globe
+	assertSubset(t, subsetDb, tidedatadb.Dimensions{
+		MinLat: -90, MaxLat: 90, MinLon: 180, MaxLon: 530, ResolutionLat: 10, ResolutionLon: 10, GridXSize: 36, GridYSize: 19,
+	})
+}
+
+func TestCopySubsetRegional(t *testing.T) {
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		return []float32{float32(x), float32(y)}
+	})
+	defer tideDataDb.Close()
+
+	// the box overlaps the west edge of the grid (lon 0..9, lat 50..59) and is clamped
+	filePath := filepath.Join(t.TempDir(), "subset.db")
+	subsetDb, err := tidedatadb.CreateTideDataDb(filePath, tidedatadb.FORMAT_BINARY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tideDataDb.CopySubset(subsetDb, tidedatadb.BoundingBox{MinLat: 52.5, MinLon: -5, MaxLat: 53, MaxLon: 2.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := subsetDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	subsetDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subsetDb.Close()
+
+	constituentData, err := subsetDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := tidedatadb.Dimensions{MinLat: 52, MaxLat: 53, MinLon: 0, MaxLon: 3, ResolutionLat: 1, ResolutionLon: 1, GridXSize: 4, GridYSize: 2}
+	if constituentData.Dimensions != expected {
+		t.Fatalf("expected dimensions %+v, got %+v", expected, constituentData.Dimensions)
+	}
+	value, err := constituentData.GetDataXY(3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value[0] != 3 || value[1] != 3 {
+		t.Errorf("expected source grid point 3,3, got %v", value)
+	}
+
+	for _, box := range []tidedatadb.BoundingBox{
+		{MinLat: 52, MinLon: 20, MaxLat: 53, MaxLon: 30},
+		{MinLat: 0, MinLon: 0, MaxLat: 10, MaxLon: 5},
+	} {
+		if err := tideDataDb.CopySubset(tidedatadb.NewMemoryTideDataDb(), box); !errors.Is(err, tidedatadb.ErrBoundingBoxOutsideGrid) {
+			t.Errorf("expected ErrBoundingBoxOutsideGrid for %+v, got %v", box, err)
+		}
+	}
+}
+
+func TestParseBoundingBox(t *testing.T) {
+	box, err := tidedatadb.ParseBoundingBox("58.5, 5.25,61,-170")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if box != (tidedatadb.BoundingBox{MinLat: 58.5, MinLon: 5.25, MaxLat: 61, MaxLon: -170}) {
+		t.Errorf("unexpected bounding box %+v", box)
+	}
+	if box.LonSpan() != 184.75 {
+		t.Errorf("expected a span of 184.75 across the antimeridian, got %f", box.LonSpan())
+	}
+	for _, value := range []string{"1,2,3", "a,1,2,3", "10,0,5,1", "-91,0,0,1", "0,0,1,400"} {
+		if _, err := tidedatadb.ParseBoundingBox(value); !errors.Is(err, tidedatadb.ErrInvalidBoundingBox) {
+			t.Errorf("expected ErrInvalidBoundingBox for %s, got %v", value, err)
+		}
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	directory := t.TempDir()
+	sourcePath := filepath.Join(directory, "source.db")
+	m2 := defaultConstituentInfo(constituents.C_M2)
+	writeDb(t, sourcePath, tidedatadb.FORMAT_BINARY, m2)
+	if !tidedatadb.IsTideDataDb(sourcePath) {
+		t.Fatalf("expected %s to be a tide db", sourcePath)
+	}
+
+	tideDataDb, err := tidedatadb.OpenTideDataDb(sourcePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tideDataDb.Close()
+	copied := tidedatadb.NewMemoryTideDataDb()
+	if err := tideDataDb.CopyTo(copied); err != nil {
+		t.Fatal(err)
+	}
+	assertMultiChunkGrid(t, copied, m2)
+
+	// model files and empty dbs are no tide dbs
+	modelPath := filepath.Join(directory, "fort.30")
+	if err := os.WriteFile(modelPath, []byte("DTU16 ocean tide model"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	emptyPath := filepath.Join(directory, "empty.db")
+	writeDb(t, emptyPath, tidedatadb.FORMAT_BINARY)
+	for _, filePath := range []string{modelPath, emptyPath, filepath.Join(directory, "missing.db")} {
+		if tidedatadb.IsTideDataDb(filePath) {
+			t.Errorf("expected %s not to be a tide db", filePath)
+		}
+	}
+}
@@ -1,23 +1,67 @@
 /*
-This package provides the functions to read and write a constituent database for quick lookup of amplitude and phase
-the binary data is structured as a netcdf file with each constituent it's own variable
+This package provides the functions to read and write a constituent database for quick lookup of amplitude and phase.
+The grids are stored by a Backend, either a chunked little-endian binary file (pure go) or a netcdf file with
+each constituent it's own variable. The netcdf backend needs cgo and libnetcdf, it is left out if cgo is
+disabled (e.g. for static builds) or with the build tag nonetcdf.
+For high-throughput lookups a binary db can be memory mapped (MODE_MMAP) or any db can be loaded
+completely into memory (LoadTideDataDb), lookups are then plain array indexing.
+Several dbs (e.g. regional models within a global model) are layered by a CompositeTideDataDb, which
+can also be stored as a single nested binary db.
+
+Concurrency: a TideDataDB and all ConstituentData retrieved from it are safe for concurrent use by
+multiple goroutines. The netcdf c library itself is not thread-safe, therefore every call into the
+library (from any TideDataDB) is serialized by a package wide lock, concurrent readers are safe but
+do not read in parallel. Close must not be called while other goroutines still use the db.
 */
 package tidedatadb
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/utils"
+)
 
-	"github.com/fhs/go-netcdf/netcdf"
+var (
+	ErrUnknownDbFormat    = errors.New("unknown tide data db format")
+	ErrNetcdfNotSupported = errors.New("netcdf tide data db not supported by this build (requires cgo and libnetcdf, built with tag nonetcdf?)")
+	ErrReadOnly           = errors.New("tide data db is read only")
+	ErrMmapNotSupported   = errors.New("only binary tide data dbs can be memory mapped")
+)
+
+type FileMode int
+
+const (
+	MODE_READONLY FileMode = iota
+	MODE_READWRITE
+	// read only, the file is mapped into memory so lookups don't need any file access (binary format only)
+	MODE_MMAP
 )
 
-type FileMode netcdf.FileMode
+type DbFormat string
 
 const (
-	MODE_READONLY FileMode = FileMode(netcdf.NOWRITE)
+	FORMAT_BINARY DbFormat = "binary"
+	FORMAT_NETCDF DbFormat = "netcdf"
 )
 
+// attributes of the constituent variables of netcdf dbs
+const ATTR_UNIT_AMPLITUDE = "UNIT_AMP"
+const ATTR_UNIT_PHASE = "UNIT_PHASE"
+const ATTR_FILL_VALUE = "_FillValue"
+
+// file extension of netcdf dbs, new dbs with this extension are created as netcdf if supported by the build
+const NETCDF_EXTENSION = ".nc"
+
+// layout of a grid, the grid point x,y of a regular grid is at MinLat+y*ResolutionLat, MinLon+x*ResolutionLon.
+// Grids with irregular spacing have Coordinates, the extent and the resolutions are then the bounding
+// box of the grid points and the mean spacing
 type Dimensions struct {
 	MinLat        float32
 	MaxLat        float32
@@ -27,37 +71,169 @@ type Dimensions struct {
 	ResolutionLon float32
 	GridXSize     uint64
 	GridYSize     uint64
+	// coordinates of the grid points, nil for regular grids
+	Coordinates *GridCoordinates
+}
+
+// returns true if the grid covers all longitudes (see utils.IsGlobalLongitude), curvilinear grids
+// are never wrapped around the globe
+func (d Dimensions) IsGlobal() bool {
+	if d.Coordinates != nil && d.Coordinates.Curvilinear {
+		return false
+	}
+	return utils.IsGlobalLongitude(d.MinLon, d.MaxLon, d.GridXSize)
 }
 
+// shifts lon by multiples of 360 degree into the longitudes of the grid (MinLon to MinLon+360),
+// e.g. -170 to 190 for a grid from 170 to 190 degree crossing the antimeridian
+func (d Dimensions) NormalizeLongitude(lon float32) float32 {
+	return utils.NormalizeLongitude(lon, d.MinLon)
+}
+
+// storage of the constituent grids of a db, implementations must be safe for concurrent use
+type Backend interface {
+	io.Closer
+	// returns the layout and the grid of a constituent, ErrConstituentNotFound if it is not in the db
+	GetConstituentGrid(constituent constituents.Constituent) (Dimensions, ConstituentInfo, Grid, error)
+	// adds a new constituent grid, ErrConstituentAlreadyInDb if it already exists
+	CreateConstituentGrid(dimensions Dimensions, constituentInfo ConstituentInfo) (Grid, error)
+}
+
+// amplitude/phase grid of a single constituent
+type Grid interface {
+	// returns amplitude and phase at the grid point
+	ReadXY(x uint64, y uint64) ([]float32, error)
+	WriteXY(amplitudePhase []float32, x uint64, y uint64) error
+}
+
+// optionally implemented by a Grid which can read the whole grid faster than point by point
+type BulkReader interface {
+	// returns amplitude and phase of all grid points row by row from the south-west corner,
+	// the values of x,y are at index (y*GridXSize+x)*2
+	ReadAll() ([]float32, error)
+}
+
+type openBackendFunc func(filePath string, mode FileMode) (Backend, error)
+type createBackendFunc func(filePath string) (Backend, error)
+
+var openBackend map[DbFormat]openBackendFunc = make(map[DbFormat]openBackendFunc)
+var createBackend map[DbFormat]createBackendFunc = make(map[DbFormat]createBackendFunc)
+
 type TideDataDB struct {
-	file *netcdf.Dataset
+	backend                  Backend
+	nearestOceanSearchRadius float32
+	interpolationMethod      utils.InterpolationMethod
+}
+
+// creates a db on top of a backend, e.g. to read from a custom storage
+func NewTideDataDb(backend Backend) *TideDataDB {
+	return &TideDataDB{backend: backend}
+}
+
+// if there is no valid data at a location (e.g. on land or at the coast) use the nearest
+// grid point with data within radius (in degree), 0 disables the search (default).
+// the radius is applied to all constituent data retrieved afterwards, so it should be set
+// before the db is used concurrently
+func (t *TideDataDB) SetNearestOceanSearchRadius(radius float32) {
+	t.nearestOceanSearchRadius = radius
+}
+
+// sets the method to interpolate between the grid points (bilinear by default) of all constituent data
+// retrieved afterwards, like the nearest ocean search radius it should be set before concurrent use
+func (t *TideDataDB) SetInterpolationMethod(method utils.InterpolationMethod) {
+	t.interpolationMethod = method
 }
 
 func (t *TideDataDB) Close() error {
-	return t.file.Close()
+	return t.backend.Close()
 }
 
-// open or creates a new tide data db
-// IMPORTANT! currently not threadsafe!
+// open or creates a new tide data db, the db is safe for concurrent use.
+// the format of an existing db is detected by its file signature, new dbs are created as netcdf if the
+// file name ends with .nc and the build supports netcdf, otherwise the binary format is used
 func OpenTideDataDb(filePath string, mode FileMode) (*TideDataDB, error) {
 	_, err := os.Stat(filePath)
-	var file netcdf.Dataset
 	// if file does not exist, create a new file
 	if err != nil && errors.Is(err, fs.ErrNotExist) {
-		file, err = netcdf.CreateFile(filePath, netcdf.NETCDF4|netcdf.FileMode(mode))
-		if err != nil {
-			return nil, err
+		format := FORMAT_BINARY
+		if strings.EqualFold(filepath.Ext(filePath), NETCDF_EXTENSION) && createBackend[FORMAT_NETCDF] != nil {
+			format = FORMAT_NETCDF
 		}
+		return CreateTideDataDb(filePath, format)
 	} else if err != nil {
 		return nil, err
-	} else {
-		file, err = netcdf.OpenFile(filePath, netcdf.NETCDF4|netcdf.FileMode(mode))
-		if err != nil {
-			return nil, err
+	}
+
+	format, err := DetectDbFormat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if mode == MODE_MMAP && format != FORMAT_BINARY {
+		return nil, ErrMmapNotSupported
+	}
+	if openBackend[format] == nil {
+		return nil, ErrNetcdfNotSupported
+	}
+	backend, err := openBackend[format](filePath, mode)
+	if err != nil {
+		return nil, err
+	}
+	return NewTideDataDb(backend), nil
+}
+
+// creates a new db in the given format, an existing file is overwritten
+func CreateTideDataDb(filePath string, format DbFormat) (*TideDataDB, error) {
+	if createBackend[format] == nil {
+		if format == FORMAT_NETCDF {
+			return nil, ErrNetcdfNotSupported
 		}
+		return nil, ErrUnknownDbFormat
 	}
+	backend, err := createBackend[format](filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewTideDataDb(backend), nil
+}
+
+// returns the format of an existing db by its file signature
+func DetectDbFormat(filePath string) (DbFormat, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	signature := make([]byte, len(BINARY_SIGNATURE))
+	n, err := io.ReadFull(file, signature)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	signature = signature[:n]
+
+	switch {
+	case bytes.HasPrefix(signature, []byte(BINARY_SIGNATURE)):
+		return FORMAT_BINARY, nil
+	// classic netcdf starts with "CDF", netcdf-4 is stored as HDF5
+	case bytes.HasPrefix(signature, []byte("CDF")) || bytes.HasPrefix(signature, []byte("\x89HDF")):
+		return FORMAT_NETCDF, nil
+	}
+	return "", ErrUnknownDbFormat
+}
 
-	return &TideDataDB{
-		file: &file,
-	}, nil
+// returns true if the file is a tide data db with at least one constituent, netcdf files of
+// other models (e.g. TPXO or FES) are not recognized as db
+func IsTideDataDb(filePath string) bool {
+	format, err := DetectDbFormat(filePath)
+	if err != nil || openBackend[format] == nil {
+		return false
+	}
+	backend, err := openBackend[format](filePath, MODE_READONLY)
+	if err != nil {
+		return false
+	}
+	tideDataDb := NewTideDataDb(backend)
+	defer tideDataDb.Close()
+	constituentList, err := tideDataDb.GetConstituents()
+	return err == nil && len(constituentList) > 0
 }
@@ -1,12 +1,252 @@
 package tidedatadb_test
 
 import (
+	"errors"
+	"math"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/mzeiher/perth3-go/pkg/constituents"
 	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
 )
 
+// creates a db with a single 10x10 constituent grid (lat 50..59, lon 0..9) filled with
+// the amplitude/phase returned by gridValue and reopens it read-only
+func createTestDb(t *testing.T, constituentInfo tidedatadb.ConstituentInfo, gridValue func(x uint64, y uint64) []float32) *tidedatadb.TideDataDB {
+	filePath := filepath.Join(t.TempDir(), "test.db")
+	tideDataDb, err := tidedatadb.OpenTideDataDb(filePath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	constituentData, err := tideDataDb.CreateNewConstituentData(tidedatadb.Dimensions{
+		MinLat:        50,
+		MaxLat:        59,
+		MinLon:        0,
+		MaxLon:        9,
+		ResolutionLat: 1,
+		ResolutionLon: 1,
+		GridXSize:     10,
+		GridYSize:     10,
+	}, constituentInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := uint64(0); y < 10; y++ {
+		for x := uint64(0); x < 10; x++ {
+			if err := constituentData.WriteDataXY(gridValue(x, y), x, y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tideDataDb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tideDataDb, err = tidedatadb.OpenTideDataDb(filePath, tidedatadb.MODE_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tideDataDb
+}
+
+func defaultConstituentInfo(constituent constituents.Constituent) tidedatadb.ConstituentInfo {
+	return tidedatadb.ConstituentInfo{
+		Constituent:   constituent,
+		AmplitudeUnit: tidedatadb.UNIT_CM,
+		PhaseUnit:     tidedatadb.UNIT_DEGREE,
+	}
+}
+
+// interpolates the constituent at lat/lon and checks amplitude and phase (in degree, compared modulo 360)
+func assertInterpolatedLatLon(t *testing.T, tideDataDb *tidedatadb.TideDataDB, constituent constituents.Constituent, lat float32, lon float32, expectedAmplitude float64, expectedPhase float64) {
+	t.Helper()
+	constituentData, err := tideDataDb.GetConstituentData(constituent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, err := constituentData.GetDataInterpolatedLatLon(lat, lon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(datum.Amplitude-expectedAmplitude) > 1e-3 {
+		t.Errorf("amplitude at %f,%f: expected %f, got %f", lat, lon, expectedAmplitude, datum.Amplitude)
+	}
+	phaseDiff := math.Mod(math.Abs(datum.Phase-expectedPhase), 360)
+	if math.Min(phaseDiff, 360-phaseDiff) > 1e-3 {
+		t.Errorf("phase at %f,%f: expected %f, got %f", lat, lon, expectedPhase, datum.Phase)
+	}
+	if datum.Phase < 0 || datum.Phase >= 360 {
+		t.Errorf("phase at %f,%f not normalized to [0,360): %f", lat, lon, datum.Phase)
+	}
+}
+
+func TestInterpolatePhaseWrap(t *testing.T) {
+	// phase alternates between 359 and 1 degree along the longitude
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		if x%2 == 0 {
+			return []float32{10, 359}
+		}
+		return []float32{10, 1}
+	})
+	defer tideDataDb.Close()
+
+	// halfway between 359 and 1 degree the phase must be 0, not 180
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 2.5, 10*math.Cos(math.Pi/180), 0)
+	// a quarter of the way the phase is 359.5
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 2.25, 10*math.Hypot(math.Cos(math.Pi/180), 0.5*math.Sin(math.Pi/180)), 360-math.Atan2(0.5*math.Sin(math.Pi/180), math.Cos(math.Pi/180))*180/math.Pi)
+	// on a grid point the values are unchanged
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 3, 10, 1)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 4, 10, 359)
+}
+
+func TestInterpolatePhaseWrapRadian(t *testing.T) {
+	constituentInfo := defaultConstituentInfo(constituents.C_M2)
+	constituentInfo.PhaseUnit = tidedatadb.UNIT_RADIAN
+	tideDataDb := createTestDb(t, constituentInfo, func(x uint64, y uint64) []float32 {
+		if y%2 == 0 {
+			return []float32{10, float32(2*math.Pi - 0.1)}
+		}
+		return []float32{10, 0.1}
+	})
+	defer tideDataDb.Close()
+
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52.5, 3, 10*math.Cos(0.1), 0)
+}
+
+func TestInterpolateAmphidromicPoint(t *testing.T) {
+	// opposite phases with equal amplitude cancel each other out
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		if x < 5 {
+			return []float32{20, 90}
+		}
+		return []float32{20, 270}
+	})
+	defer tideDataDb.Close()
+
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 55, 4.25, 10, 90)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 55, 4.75, 10, 270)
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, err := constituentData.GetDataInterpolatedLatLon(55, 4.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if datum.Amplitude > 1e-3 {
+		t.Errorf("expected vanishing amplitude at amphidromic point, got %f", datum.Amplitude)
+	}
+}
+
+// ocean in the west (x < 5) with amplitude x+1, land in the east
+func createCoastTestDb(t *testing.T) *tidedatadb.TideDataDB {
+	constituentInfo := defaultConstituentInfo(constituents.C_M2)
+	constituentInfo.HasFillValue = true
+	constituentInfo.FillValue = 999
+	return createTestDb(t, constituentInfo, func(x uint64, y uint64) []float32 {
+		if x >= 5 {
+			return []float32{999, 999}
+		}
+		return []float32{float32(x + 1), 45}
+	})
+}
+
+func TestFillValue(t *testing.T) {
+	tideDataDb := createCoastTestDb(t)
+	defer tideDataDb.Close()
+
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !constituentData.ConstituentInfo.HasFillValue || constituentData.ConstituentInfo.FillValue != 999 {
+		t.Fatalf("expected fill value 999, got %v", constituentData.ConstituentInfo)
+	}
+
+	// land corners are excluded from the weighting
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 4.25, 5, 45)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52.5, 3.5, 4.5, 45)
+
+	// mostly land around the location
+	_, err = constituentData.GetDataInterpolatedLatLon(52, 4.75)
+	if !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Errorf("expected ErrNoOceanData, got %v", err)
+	}
+}
+
+func TestNearestOceanSearch(t *testing.T) {
+	tideDataDb := createCoastTestDb(t)
+	defer tideDataDb.Close()
+
+	tideDataDb.SetNearestOceanSearchRadius(1)
+	constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nearest ocean grid point is x=4 (amplitude 5)
+	assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 52, 4.75, 5, 45)
+
+	// ~1.85 degree to the next ocean grid point
+	_, err = constituentData.GetDataInterpolatedLatLon(52, 7)
+	if !errors.Is(err, tidedatadb.ErrNoOceanData) {
+		t.Errorf("expected ErrNoOceanData, got %v", err)
+	}
+
+	constituentData.NearestOceanSearchRadius = 2
+	datum, err := constituentData.GetDataInterpolatedLatLon(52, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(datum.Amplitude-5) > 1e-3 {
+		t.Errorf("expected amplitude of nearest ocean grid point 5, got %f", datum.Amplitude)
+	}
+}
+
+func TestConcurrentGetDataInterpolatedLatLon(t *testing.T) {
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		return []float32{float32(x + y), 0}
+	})
+	defer tideDataDb.Close()
+
+	const goroutines = 32
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				constituentData, err := tideDataDb.GetConstituentData(constituents.C_M2)
+				if err != nil {
+					errs <- err
+					return
+				}
+				lat := float32(50 + (g+i)%9)
+				lon := float32((g*i)%9) + 0.5
+				datum, err := constituentData.GetDataInterpolatedLatLon(lat, lon)
+				if err != nil {
+					errs <- err
+					return
+				}
+				expected := float64(lat-50) + float64(lon)
+				if math.Abs(datum.Amplitude-expected) > 1e-4 {
+					t.Errorf("amplitude at %f,%f: expected %f, got %f", lat, lon, expected, datum.Amplitude)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkPerth3Solver(b *testing.B) {
 	tideDataDb, err := tidedatadb.OpenTideDataDb("../../.data/dtu16.nc", tidedatadb.MODE_READONLY)
 	if err != nil {
@@ -24,3 +264,70 @@ func BenchmarkPerth3Solver(b *testing.B) {
 		}
 	}
 }
+
+func TestConstituentsAt(t *testing.T) {
+	tideDataDb := createTestDb(t, defaultConstituentInfo(constituents.C_M2), func(x uint64, y uint64) []float32 {
+		return []float32{10, 45}
+	})
+	defer tideDataDb.Close()
+
+	var provider constituents.HarmonicConstantsProvider = tideDataDb
+	datums, err := provider.ConstituentsAt(52.5, 3.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the constituents of the db are returned
+	if len(datums) != 1 || datums[0].Constituent != constituents.C_M2 {
+		t.Fatalf("expected M2 only, got %+v", datums)
+	}
+	if math.Abs(datums[0].Amplitude-10) > 1e-3 || math.Abs(datums[0].Phase-45) > 1e-3 {
+		t.Errorf("expected amplitude 10 and phase 45, got %+v", datums[0])
+	}
+}
+
+func TestConstituentsAtWanted(t *testing.T) {
+	tideDataDb := createConstantDb(t, regionalDimensions, 10, 0, constituents.C_M2, constituents.C_S2)
+	// the K1 grid has no ocean data
+	constituentInfo := defaultConstituentInfo(constituents.C_K1)
+	constituentInfo.HasFillValue = true
+	constituentInfo.FillValue = -1
+	if _, err := tideDataDb.CreateNewConstituentData(regionalDimensions, constituentInfo); err != nil {
+		t.Fatal(err)
+	}
+	composite, err := tidedatadb.NewCompositeTideDataDb(tidedatadb.Layer{Db: tideDataDb})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, provider := range []constituents.HarmonicConstantsProvider{tideDataDb, composite} {
+		if _, err := provider.ConstituentsAt(55, 5); !errors.Is(err, tidedatadb.ErrNoOceanData) {
+			t.Errorf("expected ErrNoOceanData, got %v", err)
+		}
+		// only the wanted constituents are looked up, the missing O1 is omitted
+		datums, err := provider.ConstituentsAt(55, 5, constituents.C_M2, constituents.C_O1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(datums) != 1 || datums[0].Constituent != constituents.C_M2 {
+			t.Errorf("expected M2 only, got %+v", datums)
+		}
+	}
+}
+
+func TestAmplitudeUnits(t *testing.T) {
+	for _, expected := range []struct {
+		unit      tidedatadb.ConstituentAmplitudeUnit
+		amplitude float64
+	}{
+		{tidedatadb.UNIT_CM, 1.5},
+		{tidedatadb.UNIT_METER, 150},
+		{tidedatadb.UNIT_FEET, 45.72},
+	} {
+		constituentInfo := defaultConstituentInfo(constituents.C_M2)
+		constituentInfo.AmplitudeUnit = expected.unit
+		tideDataDb := createTestDb(t, constituentInfo, func(x uint64, y uint64) []float32 { return []float32{1.5, 30} })
+		// amplitudes are returned in cm
+		assertInterpolatedLatLon(t, tideDataDb, constituents.C_M2, 54.5, 4.5, expected.amplitude, 30)
+		tideDataDb.Close()
+	}
+}
@@ -1,14 +1,74 @@
 package tidedatums
 
 import (
+	"errors"
 	"math"
 	"time"
 
+	"github.com/mzeiher/perth3-go/pkg/astro"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
 	"github.com/mzeiher/perth3-go/pkg/solver"
-	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/tideextrema"
 )
 
+var ErrEpochNotFound = errors.New("epoch not found")
+
+// time span and step of the predicted series the datums are calculated from
+type DatumEpoch struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// length of a full nodal cycle (18.61 years)
+const NODAL_CYCLE = time.Duration(18.61 * 365.25 * 24 * float64(time.Hour))
+
+var (
+	// epoch used before the epoch was configurable
+	EPOCH_2000_2020 = DatumEpoch{
+		Name:  "2000-2020",
+		Start: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Step:  15 * time.Minute,
+	}
+	// full nodal cycle starting 2000-01-01
+	EPOCH_NODAL_CYCLE = NewNodalCycleEpoch(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	// National Tidal Datum Epoch 1983-2001 used by NOAA, sampled like the 6 minute observations
+	EPOCH_NTDE_1983_2001 = DatumEpoch{
+		Name:  "ntde1983-2001",
+		Start: time.Date(1983, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC).Add(-6 * time.Minute),
+		Step:  6 * time.Minute,
+	}
+)
+
+// creates an epoch over a full nodal cycle (18.61 years) from start with a 15 minute step
+func NewNodalCycleEpoch(start time.Time) DatumEpoch {
+	return DatumEpoch{
+		Name:  "nodalcycle",
+		Start: start,
+		End:   start.Add(NODAL_CYCLE),
+		Step:  15 * time.Minute,
+	}
+}
+
+func GetEpochFromString(epoch string) (DatumEpoch, error) {
+	switch epoch {
+	case EPOCH_2000_2020.Name:
+		return EPOCH_2000_2020, nil
+	case EPOCH_NODAL_CYCLE.Name:
+		return EPOCH_NODAL_CYCLE, nil
+	case EPOCH_NTDE_1983_2001.Name:
+		return EPOCH_NTDE_1983_2001, nil
+	}
+	return DatumEpoch{}, ErrEpochNotFound
+}
+
 type TideDatums struct {
+	// epoch the datums were calculated for
+	Epoch DatumEpoch
+
 	HAT  float32
 	MHWS float32
 	MHW  float32
@@ -20,49 +80,126 @@ type TideDatums struct {
 	LAT  float32
 }
 
-func GetDatumsForLatLan(constituentDb *tidedatadb.TideDataDB, solverName solver.Solver, lat float32, lon float32) (*TideDatums, error) {
+// calculates the datums from the predicted series of the epoch, the high and low waters are found with tideextrema
+func GetDatumsForLatLan(provider constituents.HarmonicConstantsProvider, solverName solver.Solver, lat float32, lon float32, epoch DatumEpoch, options ...solver.Option) (*TideDatums, error) {
 
-	currentTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
-	end := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
-	step := 15 * time.Minute
+	locationSolver, err := solver.GetLocationSolver(solverName)
+	if err != nil {
+		return nil, err
+	}
 
-	tideSolver, err := solver.GetSolver(solverName)
+	heightAt, err := locationSolver(provider, lat, lon, options...)
 	if err != nil {
 		return nil, err
 	}
 
-	lat_datum := 0.0
-	hat_datum := 0.0
+	datums := calculateDatums(heightAt, epoch)
+	datums.Epoch = epoch
+	return datums, nil
+}
 
-	tideHeight := 0.0
-	nbrTideData := 0
+// calculates all datums from the tide function heightAt over the epoch
+//
+// HAT/LAT and MSL are taken from the series sampled with the step of the epoch, the high and low
+// waters from tideextrema.FindExtremaFunc. MHW/MLW are the mean of all high/low waters. For the
+// spring and neap datums the series is split in half lunations: MHWS/MLWS are the mean of the
+// highest high and lowest low water of each period around new and full moon, MHWN/MLWN the mean
+// of the lowest high and highest low water of each period around the first and third quarter.
+// Taking the extreme of the whole period instead of the water at the exact lunar phase accounts
+// for the local age of the tide.
+func calculateDatums(heightAt func(time.Time) float64, epoch DatumEpoch) *TideDatums {
+	lat_datum := heightAt(epoch.Start)
+	hat_datum := lat_datum
 
-	for {
-		height, err := tideSolver(constituentDb, lat, lon, currentTime)
-		if err != nil {
-			return nil, err
-		}
+	tideHeight := 0.0
+	count := 0
 
+	for timeUtc := epoch.Start; !timeUtc.After(epoch.End); timeUtc = timeUtc.Add(epoch.Step) {
+		height := heightAt(timeUtc)
 		lat_datum = math.Min(height, lat_datum)
 		hat_datum = math.Max(height, hat_datum)
 		tideHeight = height + tideHeight
+		count++
+	}
 
-		currentTime = currentTime.Add(step)
-		if end.Sub(currentTime) < 0 {
-			break
+	highWaters := []tideextrema.Event{}
+	lowWaters := []tideextrema.Event{}
+	for _, event := range tideextrema.FindExtremaFunc(heightAt, epoch.Start, epoch.End) {
+		if event.Type == tideextrema.HighWater {
+			highWaters = append(highWaters, event)
+		} else {
+			lowWaters = append(lowWaters, event)
 		}
-		nbrTideData = nbrTideData + 1
 	}
 
+	// spring periods are centered around new and full moon (phase angle 0 and 180),
+	// neap periods around the quarters (phase angle 90 and 270)
+	springHighs := extremesPerHalfLunation(highWaters, 90, math.Max)
+	springLows := extremesPerHalfLunation(lowWaters, 90, math.Min)
+	neapHighs := extremesPerHalfLunation(highWaters, 0, math.Min)
+	neapLows := extremesPerHalfLunation(lowWaters, 0, math.Max)
+
 	return &TideDatums{
 		HAT:  float32(hat_datum),
 		LAT:  float32(lat_datum),
-		MHWS: 0,
-		MHW:  0,
-		MHWN: 0,
-		MSL:  (float32(tideHeight) / float32(nbrTideData)),
-		MLWN: 0,
-		MLW:  0,
-		MLWS: 0,
-	}, nil
+		MHWS: float32(mean(springHighs)),
+		MHW:  float32(mean(heightsOf(highWaters))),
+		MHWN: float32(mean(neapHighs)),
+		MSL:  float32(tideHeight / float64(count)),
+		MLWN: float32(mean(neapLows)),
+		MLW:  float32(mean(heightsOf(lowWaters))),
+		MLWS: float32(mean(springLows)),
+	}
+}
+
+// groups the water levels into half lunations, a new period starts each time the moon phase
+// angle crosses boundary or boundary+180, and returns the extreme (selected by pick) of each period,
+// the first and last period are skipped because they are most likely incomplete
+func extremesPerHalfLunation(waterLevels []tideextrema.Event, boundary float64, pick func(float64, float64) float64) []float64 {
+	extremes := []float64{}
+	if len(waterLevels) == 0 {
+		return extremes
+	}
+
+	period := func(level tideextrema.Event) int {
+		angle := math.Mod(astro.GetMoonPhaseAngle(level.Time)-boundary+360, 360)
+		return int(angle / 180)
+	}
+
+	isFirstPeriod := true
+	currentPeriod := period(waterLevels[0])
+	currentExtreme := waterLevels[0].Height
+	for _, level := range waterLevels[1:] {
+		levelPeriod := period(level)
+		if levelPeriod != currentPeriod {
+			if !isFirstPeriod {
+				extremes = append(extremes, currentExtreme)
+			}
+			isFirstPeriod = false
+			currentPeriod = levelPeriod
+			currentExtreme = level.Height
+			continue
+		}
+		currentExtreme = pick(currentExtreme, level.Height)
+	}
+	return extremes
+}
+
+func heightsOf(waterLevels []tideextrema.Event) []float64 {
+	heights := make([]float64, len(waterLevels))
+	for i, level := range waterLevels {
+		heights[i] = level.Height
+	}
+	return heights
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum = sum + value
+	}
+	return sum / float64(len(values))
 }
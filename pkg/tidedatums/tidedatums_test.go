@@ -0,0 +1,110 @@
+package tidedatums
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/solver"
+	"github.com/mzeiher/perth3-go/pkg/solver/perth3"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+	"github.com/mzeiher/perth3-go/pkg/tideextrema"
+)
+
+// semidiurnal tide of M2 and S2 only, spring tide is at new and full moon with
+// a height of m2+s2, neap tide at the quarters with a height of m2-s2
+func m2s2Tide(m2 float64, s2 float64) func(time.Time) float64 {
+	return func(timeUtc time.Time) float64 {
+		args := perth3.CalculateArguments(timeUtc)
+		return m2*math.Cos(args[5]*(math.Pi/180)) + s2*math.Cos(args[6]*(math.Pi/180))
+	}
+}
+
+func assertDatum(t *testing.T, name string, expected float64, actual float32, tolerance float64) {
+	t.Helper()
+	if math.Abs(expected-float64(actual)) > tolerance {
+		t.Errorf("%s: expected %f, got %f", name, expected, actual)
+	}
+}
+
+func TestCalculateDatums(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	datums := calculateDatums(m2s2Tide(100, 30), DatumEpoch{Start: start, End: start.AddDate(1, 0, 0), Step: 15 * time.Minute})
+
+	assertDatum(t, "HAT", 130, datums.HAT, 0.5)
+	assertDatum(t, "MHWS", 130, datums.MHWS, 1)
+	assertDatum(t, "MHWN", 70, datums.MHWN, 1)
+	assertDatum(t, "MSL", 0, datums.MSL, 0.5)
+	assertDatum(t, "MLWN", -70, datums.MLWN, 1)
+	assertDatum(t, "MLWS", -130, datums.MLWS, 1)
+	assertDatum(t, "LAT", -130, datums.LAT, 0.5)
+
+	// mean of the spring/neap envelope |100 + 30*e^(i*theta)|
+	expectedMean := 0.0
+	for i := 0; i < 3600; i++ {
+		expectedMean = expectedMean + math.Hypot(100+30*math.Cos(float64(i)*math.Pi/1800), 30*math.Sin(float64(i)*math.Pi/1800))
+	}
+	expectedMean = expectedMean / 3600
+	assertDatum(t, "MHW", expectedMean, datums.MHW, 1)
+	assertDatum(t, "MLW", -expectedMean, datums.MLW, 1)
+}
+
+// MHW and MLW must be the mean of the high and low waters of the tide table
+func TestDatumsMatchExtrema(t *testing.T) {
+	station := &stationdb.Station{ID: "test", Lat: 50.5, Lon: 0.5}
+	for constituent, amplitude := range map[constituents.Constituent]float64{constituents.C_M2: 100, constituents.C_S2: 30, constituents.C_K1: 10, constituents.C_O1: 8, constituents.C_N2: 10} {
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{Constituent: constituent, Amplitude: amplitude, Phase: 42})
+	}
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	epoch := DatumEpoch{Name: "test", Start: start, End: start.AddDate(0, 2, 0), Step: 15 * time.Minute}
+
+	datums, err := GetDatumsForLatLan(station, solver.PERTH_3, station.Lat, station.Lon, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tideextrema.FindExtrema(station, solver.PERTH_3, station.Lat, station.Lon, epoch.Start, epoch.End)
+	if err != nil {
+		t.Fatal(err)
+	}
+	highWaters, lowWaters := 0.0, 0.0
+	highCount, lowCount := 0, 0
+	for _, event := range events {
+		if event.Type == tideextrema.HighWater {
+			highWaters = highWaters + event.Height
+			highCount++
+		} else {
+			lowWaters = lowWaters + event.Height
+			lowCount++
+		}
+	}
+	assertDatum(t, "MHW", highWaters/float64(highCount), datums.MHW, 1e-4)
+	assertDatum(t, "MLW", lowWaters/float64(lowCount), datums.MLW, 1e-4)
+}
+
+func TestGetEpochFromString(t *testing.T) {
+	for _, expected := range []DatumEpoch{EPOCH_2000_2020, EPOCH_NODAL_CYCLE, EPOCH_NTDE_1983_2001} {
+		epoch, err := GetEpochFromString(expected.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if epoch != expected {
+			t.Errorf("expected epoch %v, got %v", expected, epoch)
+		}
+	}
+	if _, err := GetEpochFromString("unknown"); err != ErrEpochNotFound {
+		t.Errorf("expected ErrEpochNotFound, got %v", err)
+	}
+}
+
+func TestNodalCycleEpoch(t *testing.T) {
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	epoch := NewNodalCycleEpoch(start)
+	years := epoch.End.Sub(epoch.Start).Hours() / 24 / 365.25
+	if math.Abs(years-18.61) > 1e-6 {
+		t.Errorf("expected a nodal cycle of 18.61 years, got %f", years)
+	}
+	if !EPOCH_NTDE_1983_2001.End.Before(time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)) || EPOCH_NTDE_1983_2001.End.Year() != 2001 {
+		t.Errorf("NTDE 1983-2001 must end in 2001, got %s", EPOCH_NTDE_1983_2001.End)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+This package finds the high and low waters (extrema of the tide) for a location, like in a tide table.
+The extrema are bracketed on a coarse grid of the predicted series and refined with a golden section search.
+*/
+package tideextrema
+
+import (
+	"math"
+	"time"
+
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/solver"
+)
+
+type EventType string
+
+const (
+	HighWater EventType = "HighWater"
+	LowWater  EventType = "LowWater"
+)
+
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	Height float64
+}
+
+// step of the coarse grid used to bracket the extrema, must be well below the
+// shortest time between two extrema (~3h for quarter diurnal tides)
+const COARSE_STEP = 10 * time.Minute
+
+// precision of the refined event times
+const TIME_TOLERANCE = time.Second
+
+var invGoldenRatio = (math.Sqrt(5) - 1) / 2
+
+// finds all high and low waters between startUtc and endUtc (inclusive), sorted by time,
+// the harmonic constants for the location are only looked up once for the whole search
+func FindExtrema(provider constituents.HarmonicConstantsProvider, solverName solver.Solver, lat float32, lon float32, startUtc time.Time, endUtc time.Time, options ...solver.Option) ([]Event, error) {
+	locationSolver, err := solver.GetLocationSolver(solverName)
+	if err != nil {
+		return nil, err
+	}
+	heightAt, err := locationSolver(provider, lat, lon, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindExtremaFunc(heightAt, startUtc, endUtc), nil
+}
+
+// finds all high and low waters of the tide function heightAt between startUtc and endUtc (inclusive), sorted by time,
+// e.g. for a location solver or a synthetic tide
+func FindExtremaFunc(heightAt func(time.Time) float64, startUtc time.Time, endUtc time.Time) []Event {
+	// extend the coarse grid by one step on each side to also bracket extrema close to start and end
+	coarseStart := startUtc.Add(-COARSE_STEP)
+	heights := []float64{}
+	for timeUtc := coarseStart; !timeUtc.After(endUtc.Add(COARSE_STEP)); timeUtc = timeUtc.Add(COARSE_STEP) {
+		heights = append(heights, heightAt(timeUtc))
+	}
+
+	events := []Event{}
+	for i := 1; i < len(heights)-1; i++ {
+		previous, current, next := heights[i-1], heights[i], heights[i+1]
+		var eventType EventType
+		if current > previous && current >= next {
+			eventType = HighWater
+		} else if current < previous && current <= next {
+			eventType = LowWater
+		} else {
+			continue
+		}
+
+		bracketStart := coarseStart.Add(time.Duration(i-1) * COARSE_STEP)
+		bracketEnd := coarseStart.Add(time.Duration(i+1) * COARSE_STEP)
+		sign := 1.0
+		if eventType == LowWater {
+			sign = -1.0
+		}
+		eventTime, height := goldenSectionSearch(func(timeUtc time.Time) float64 {
+			return sign * heightAt(timeUtc)
+		}, bracketStart, bracketEnd)
+		if eventTime.Before(startUtc) || eventTime.After(endUtc) {
+			continue
+		}
+		events = append(events, Event{
+			Type:   eventType,
+			Time:   eventTime,
+			Height: sign * height,
+		})
+	}
+	return events
+}
+
+// searches the maximum of f between a and b until the bracket is smaller than TIME_TOLERANCE
+func goldenSectionSearch(f func(time.Time) float64, a time.Time, b time.Time) (time.Time, float64) {
+	interval := func(from time.Time, to time.Time, fraction float64) time.Time {
+		return from.Add(time.Duration(float64(to.Sub(from)) * fraction))
+	}
+
+	c := interval(b, a, invGoldenRatio)
+	d := interval(a, b, invGoldenRatio)
+	fc := f(c)
+	fd := f(d)
+	for b.Sub(a) > TIME_TOLERANCE {
+		if fc > fd {
+			b = d
+			d = c
+			fd = fc
+			c = interval(b, a, invGoldenRatio)
+			fc = f(c)
+		} else {
+			a = c
+			c = d
+			fc = fd
+			d = interval(a, b, invGoldenRatio)
+			fd = f(d)
+		}
+	}
+
+	extremum := interval(a, b, 0.5)
+	return extremum, f(extremum)
+}
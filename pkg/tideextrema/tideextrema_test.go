@@ -0,0 +1,130 @@
+package tideextrema_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mzeiher/perth3-go/internal/testutil"
+	"github.com/mzeiher/perth3-go/pkg/constituents"
+	"github.com/mzeiher/perth3-go/pkg/solver"
+	"github.com/mzeiher/perth3-go/pkg/solver/perth3"
+	"github.com/mzeiher/perth3-go/pkg/stationdb"
+	"github.com/mzeiher/perth3-go/pkg/tidedatadb"
+	"github.com/mzeiher/perth3-go/pkg/tideextrema"
+)
+
+// semidiurnal tide dominated by M2, the other constituents of the solver have amplitude 0
+var testAmplitudes = map[constituents.Constituent]float32{
+	constituents.C_M2: 100,
+	constituents.C_S2: 30,
+	constituents.C_K1: 10,
+	constituents.C_O1: 8,
+	constituents.C_N2: 10,
+}
+
+// creates a constituent db with testAmplitudes and phase 42 for all constituents
+func createTestDb(t *testing.T) *tidedatadb.TideDataDB {
+	tideDataDb := tidedatadb.NewMemoryTideDataDb()
+	testutil.CreateConstituentGrids(t, tideDataDb, tidedatadb.Dimensions{
+		MinLat:        50,
+		MaxLat:        51,
+		MinLon:        0,
+		MaxLon:        1,
+		ResolutionLat: 1,
+		ResolutionLon: 1,
+		GridXSize:     2,
+		GridYSize:     2,
+	}, func(index int, x uint64, y uint64) (float32, float32) {
+		return testAmplitudes[testutil.SolverConstituents[index]], 42
+	})
+	return tideDataDb
+}
+
+func TestFindExtrema(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	events, err := tideextrema.FindExtrema(tideDataDb, solver.PERTH_3, 50.5, 0.5, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ~2 high and 2 low waters per lunar day (24h50m)
+	if len(events) < 26 || len(events) > 28 {
+		t.Fatalf("expected 26-28 events in 7 days, got %d", len(events))
+	}
+
+	for index, event := range events {
+		if event.Time.Before(start) || event.Time.After(end) {
+			t.Errorf("event %d at %s outside of the requested time span", index, event.Time)
+		}
+		if index > 0 {
+			if events[index-1].Type == event.Type {
+				t.Errorf("event %d: high and low waters must alternate", index)
+			}
+			if gap := event.Time.Sub(events[index-1].Time); gap < 4*time.Hour || gap > 8*time.Hour {
+				t.Errorf("event %d: unexpected time between events %s", index, gap)
+			}
+		}
+
+		// the event must be the extremum within +-30 seconds
+		for _, offset := range []time.Duration{-30 * time.Second, 30 * time.Second} {
+			height, err := perth3.Solve(tideDataDb, 50.5, 0.5, event.Time.Add(offset))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Type == tideextrema.HighWater && height > event.Height+1e-6 {
+				t.Errorf("high water %d at %s is not a maximum, %f > %f at %s", index, event.Time, height, event.Height, offset)
+			}
+			if event.Type == tideextrema.LowWater && height < event.Height-1e-6 {
+				t.Errorf("low water %d at %s is not a minimum, %f < %f at %s", index, event.Time, height, event.Height, offset)
+			}
+		}
+		if event.Type == tideextrema.HighWater && event.Height < 50 {
+			t.Errorf("high water %d too low: %f", index, event.Height)
+		}
+		if event.Type == tideextrema.LowWater && event.Height > -50 {
+			t.Errorf("low water %d too high: %f", index, event.Height)
+		}
+	}
+}
+
+func TestFindExtremaStationMatchesGrid(t *testing.T) {
+	tideDataDb := createTestDb(t)
+	defer tideDataDb.Close()
+
+	station := &stationdb.Station{ID: "test", Lat: 50.5, Lon: 0.5}
+	// all constituents of the grid, Q1, P1 and K2 with amplitude 0 would be inferred if they were missing
+	for _, constituent := range testutil.SolverConstituents {
+		station.Constituents = append(station.Constituents, constituents.ConstituentDatum{Constituent: constituent, Amplitude: float64(testAmplitudes[constituent]), Phase: 42})
+	}
+
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	expected, err := tideextrema.FindExtrema(tideDataDb, solver.PERTH_3, 50.5, 0.5, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tideextrema.FindExtrema(station, solver.PERTH_3, station.Lat, station.Lon, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for index, event := range events {
+		if event.Type != expected[index].Type || absDuration(event.Time.Sub(expected[index].Time)) > tideextrema.TIME_TOLERANCE || math.Abs(event.Height-expected[index].Height) > 1e-3 {
+			t.Errorf("event %d: expected %+v, got %+v", index, expected[index], event)
+		}
+	}
+}
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+	return duration
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// returned if a location is outside of the grid
+var ErrOutOfGrid = errors.New("location outside of the grid")
+
+// returns the fractional index of value in the strictly increasing axis by a binary search,
+// e.g. 1.25 for 12.5 in [0, 10, 20, 40], ErrOutOfGrid if value is outside of the axis
+func AxisPosition(axis []float32, value float32) (float32, error) {
+	if len(axis) == 0 || value < axis[0] || value > axis[len(axis)-1] || math.IsNaN(float64(value)) {
+		return 0, ErrOutOfGrid
+	}
+	if len(axis) == 1 {
+		return 0, nil
+	}
+	// first index with axis[index] > value
+	index := sort.Search(len(axis), func(i int) bool { return axis[i] > value })
+	if index == len(axis) {
+		return float32(len(axis) - 1), nil
+	}
+	lower := index - 1
+	return float32(lower) + (value-axis[lower])/(axis[index]-axis[lower]), nil
+}
+
+// returns the position s/t (0..1) of the point x/y in the quadrilateral cell with the corners p00, p10, p01
+// and p11 ([x, y], p10 is the neighbour of p00 along s), false if the point is not within the cell.
+// The bilinear mapping of the cell is inverted by a newton iteration
+func CellPosition(p00 [2]float64, p10 [2]float64, p01 [2]float64, p11 [2]float64, x float64, y float64) (float64, float64, bool) {
+	const epsilon = 1e-6
+	target := [2]float64{x, y}
+	s, t := 0.5, 0.5
+	for i := 0; i < 20; i++ {
+		var residual, ds, dt [2]float64
+		for k := 0; k < 2; k++ {
+			residual[k] = p00[k]*(1-s)*(1-t) + p10[k]*s*(1-t) + p01[k]*(1-s)*t + p11[k]*s*t - target[k]
+			ds[k] = (p10[k]-p00[k])*(1-t) + (p11[k]-p01[k])*t
+			dt[k] = (p01[k]-p00[k])*(1-s) + (p11[k]-p10[k])*s
+		}
+		determinant := ds[0]*dt[1] - ds[1]*dt[0]
+		if determinant == 0 {
+			return 0, 0, false
+		}
+		stepS := (residual[0]*dt[1] - residual[1]*dt[0]) / determinant
+		stepT := (ds[0]*residual[1] - ds[1]*residual[0]) / determinant
+		s = s - stepS
+		t = t - stepT
+		if math.Abs(stepS) < 1e-9 && math.Abs(stepT) < 1e-9 {
+			break
+		}
+	}
+	if math.IsNaN(s) || math.IsNaN(t) || s < -epsilon || s > 1+epsilon || t < -epsilon || t > 1+epsilon {
+		return 0, 0, false
+	}
+	return math.Min(math.Max(s, 0), 1), math.Min(math.Max(t, 0), 1), true
+}
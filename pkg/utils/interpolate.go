@@ -1,77 +1,342 @@
 package utils
 
-type Interpolatable interface {
-	GetDataXY(x uint64, y uint64) ([]float32, error)
-}
+import (
+	"errors"
+	"math"
+)
 
-func InterpolateValues(lat float32, lon float32, minLat float32, maxLat float32, minLon float32, maxLon float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool) ([]float32, error) {
+var (
+	// returned by an Interpolatable for grid points without data (e.g. land) and by
+	// InterpolateValues if there is not enough valid data around the position
+	ErrUndefinedValue             = errors.New("undefined value")
+	ErrUnknownInterpolationMethod = errors.New("unknown interpolation method")
+)
 
-	xResInDegree := (maxLon - minLon) / float32(gridSizeX-1)
-	yResInDegree := (maxLat - minLat) / float32(gridSizeY-1)
+// how the values between the grid points are calculated
+type InterpolationMethod byte
 
-	x0PosForLon := MapValue(lon, minLon, maxLon, 0, float32(gridSizeX-1))
-	y0PosForLat := MapValue(lat, minLat, maxLat, 0, float32(gridSizeY-1))
+const (
+	// weighted mean of the 4 surrounding grid points (default)
+	INTERPOLATION_BILINEAR InterpolationMethod = iota
+	// cubic convolution (catmull-rom) of the 16 surrounding grid points, falls back to bilinear
+	// if one of them is undefined (e.g. at the coast)
+	INTERPOLATION_BICUBIC
+	// the value of the nearest grid point, e.g. to compare with the cells of a model
+	INTERPOLATION_NEAREST
+)
 
-	x0PosForLonInt := uint64(x0PosForLon)
-	y0PosForLatInt := uint64(y0PosForLat)
+func InterpolationMethodFromString(name string) (InterpolationMethod, error) {
+	switch name {
+	case "bilinear":
+		return INTERPOLATION_BILINEAR, nil
+	case "bicubic":
+		return INTERPOLATION_BICUBIC, nil
+	case "nearest":
+		return INTERPOLATION_NEAREST, nil
+	}
+	return 0, ErrUnknownInterpolationMethod
+}
 
-	x1PosForLonInt := x0PosForLonInt + 1
-	y1PosForLatInt := y0PosForLatInt + 1
+func (i InterpolationMethod) String() string {
+	switch i {
+	case INTERPOLATION_BILINEAR:
+		return "bilinear"
+	case INTERPOLATION_BICUBIC:
+		return "bicubic"
+	case INTERPOLATION_NEAREST:
+		return "nearest"
+	}
+	return ""
+}
 
-	// calculate weights
-	weightWest := (xResInDegree - (lon - float32((x0PosForLonInt))*xResInDegree - minLon)) / xResInDegree
-	weightEast := 1 - weightWest
-	weightSouth := (yResInDegree - (lat - float32((y0PosForLatInt))*yResInDegree - minLat)) / yResInDegree
-	weightNorth := 1 - weightSouth
+type Interpolatable interface {
+	// returns the values at x/y or ErrUndefinedValue if there is no data at the grid point
+	GetDataXY(x uint64, y uint64) ([]float32, error)
+}
 
-	weightNW := weightNorth * weightWest
-	weightNE := weightNorth * weightEast
-	weightSE := weightSouth * weightEast
-	weightSW := weightSouth * weightWest
+// grid point and its weight in an interpolation
+type gridCorner struct {
+	x      uint64
+	y      uint64
+	weight float32
+}
 
-	combinedWeight := weightNE + weightNW + weightSE + weightSW
+// positions within this distance (in grid points) beyond the first or last row or column are
+// treated as on it, e.g. MaxLat which is mapped slightly beyond the last row by rounding
+const gridEpsilon = 1e-4
 
-	// special case, for example if 90,0 is queried, we need to wrap at the northpole, if 0,180 we need to wrap along the null meridian
-	y1x0WithPossibleWrap := x0PosForLonInt
-	y1x1WithPossibleWrap := x1PosForLonInt
-	if wrap {
-		if x1PosForLonInt >= gridSizeX {
-			x1PosForLonInt = 0
-		}
-		if y1PosForLatInt >= gridSizeY {
-			y1PosForLatInt = y0PosForLatInt
-			y1x0WithPossibleWrap = (gridSizeX - 1) - x0PosForLonInt
-			y1x1WithPossibleWrap = (gridSizeX - 1) - x1PosForLonInt
-		}
+// interpolates bilinear at lat/lon on the regular grid from minLat/minLon to maxLat/maxLon. The longitude is
+// shifted into the longitudes of the grid first, so grids from -180 to 180 and from 0 to 360 degree are
+// queried alike. If wrap is set and the grid covers all longitudes (see IsGlobalLongitude), locations between
+// the last and the first column are interpolated across the seam and locations between the first or last row
+// and a pole (e.g. rows at the centre of cells) across the pole with the same row on the opposite meridian.
+// ErrOutOfGrid if the location is outside of the grid
+func InterpolateValues(lat float32, lon float32, minLat float32, maxLat float32, minLon float32, maxLon float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool) ([]float32, error) {
+	corners, err := latLonCorners(lat, lon, minLat, maxLat, minLon, maxLon, gridSizeX, gridSizeY, wrap)
+	if err != nil {
+		return nil, err
 	}
+	return weightedMean(corners, dataGrid)
+}
 
-	constituentsNW, err := dataGrid.GetDataXY(y1x0WithPossibleWrap, y1PosForLatInt)
+// like InterpolateValues with the given interpolation method, bicubic interpolation is bilinear
+// between a pole and the first or last row
+func InterpolateValuesWithMethod(lat float32, lon float32, minLat float32, maxLat float32, minLon float32, maxLon float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool, method InterpolationMethod) ([]float32, error) {
+	corners, err := latLonCorners(lat, lon, minLat, maxLat, minLon, maxLon, gridSizeX, gridSizeY, wrap)
 	if err != nil {
 		return nil, err
 	}
-	constituentsNE, err := dataGrid.GetDataXY(y1x1WithPossibleWrap, y1PosForLatInt)
+	switch method {
+	case INTERPOLATION_NEAREST:
+		return nearestCorner(corners, dataGrid)
+	case INTERPOLATION_BICUBIC:
+		y := MapValue(lat, minLat, maxLat, 0, float32(gridSizeY-1))
+		if y >= -gridEpsilon && y <= float32(gridSizeY-1)+gridEpsilon {
+			wrap = wrap && IsGlobalLongitude(minLon, maxLon, gridSizeX)
+			x := lonPosition(lon, minLon, maxLon, gridSizeX, wrap)
+			columns := gridSizeX
+			if wrap && HasDuplicateSeamColumn(minLon, maxLon, gridSizeX) {
+				// the last column is the first one again, it must not be counted twice in the grid points around the seam
+				columns = gridSizeX - 1
+				x = float32(math.Mod(float64(x), float64(columns)))
+			}
+			return interpolateBicubic(x, y, columns, gridSizeY, dataGrid, wrap)
+		}
+	}
+	return weightedMean(corners, dataGrid)
+}
+
+// interpolates bilinear at the fractional grid position x/y, e.g. 1.5/2 is between the grid points 1,2 and 2,2.
+// Used directly for grids with irregular coordinates, where the position is found by a search on the coordinates.
+// If wrap is set, positions between gridSizeX-1 and gridSizeX are between the last and the first column.
+// ErrOutOfGrid if the position is outside of the grid
+func InterpolateGridPosition(x float32, y float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool) ([]float32, error) {
+	corners, err := positionCorners(x, y, gridSizeX, gridSizeY, wrap)
 	if err != nil {
 		return nil, err
 	}
-	constituentsSE, err := dataGrid.GetDataXY(x1PosForLonInt, y0PosForLatInt)
+	return weightedMean(corners, dataGrid)
+}
+
+// like InterpolateGridPosition with the given interpolation method
+func InterpolateGridPositionWithMethod(x float32, y float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool, method InterpolationMethod) ([]float32, error) {
+	corners, err := positionCorners(x, y, gridSizeX, gridSizeY, wrap)
 	if err != nil {
 		return nil, err
 	}
-	constituentsSW, err := dataGrid.GetDataXY(x0PosForLonInt, y0PosForLatInt)
+	switch method {
+	case INTERPOLATION_BICUBIC:
+		return interpolateBicubic(x, y, gridSizeX, gridSizeY, dataGrid, wrap)
+	case INTERPOLATION_NEAREST:
+		return nearestCorner(corners, dataGrid)
+	}
+	return weightedMean(corners, dataGrid)
+}
+
+// returns the two grid points around the fractional position on an axis of size grid points and the
+// weight of the second one, the second grid point is the first one if the position is on the last grid point.
+// If wrap is set positions up to size wrap around from the last to the first grid point
+func axisNeighbours(position float32, size uint64, wrap bool) (uint64, uint64, float32, error) {
+	last := float32(size - 1)
+	if position > last && position <= last+gridEpsilon {
+		position = last
+	} else if position < 0 && position >= -gridEpsilon {
+		position = 0
+	}
+	limit := last
+	if wrap {
+		limit = float32(size)
+	}
+	if math.IsNaN(float64(position)) || position < 0 || position > limit || (wrap && position == limit) {
+		return 0, 0, 0, ErrOutOfGrid
+	}
+	index0 := uint64(position)
+	weight := position - float32(index0)
+	index1 := index0 + 1
+	if index1 >= size && wrap {
+		index1 = 0
+	} else if index1 >= size {
+		index1 = index0
+	}
+	return index0, index1, weight, nil
+}
+
+// returns the 4 grid points around the fractional grid position x/y with their bilinear weights,
+// in the order south west, south east, north west, north east
+func positionCorners(x float32, y float32, gridSizeX uint64, gridSizeY uint64, wrap bool) ([4]gridCorner, error) {
+	x0, x1, weightEast, err := axisNeighbours(x, gridSizeX, wrap)
 	if err != nil {
-		return nil, err
+		return [4]gridCorner{}, err
+	}
+	y0, y1, weightNorth, err := axisNeighbours(y, gridSizeY, false)
+	if err != nil {
+		return [4]gridCorner{}, err
+	}
+	return [4]gridCorner{
+		{x0, y0, (1 - weightEast) * (1 - weightNorth)},
+		{x1, y0, weightEast * (1 - weightNorth)},
+		{x0, y1, (1 - weightEast) * weightNorth},
+		{x1, y1, weightEast * weightNorth},
+	}, nil
+}
+
+// returns the fractional column of lon in the regular grid, between gridSizeX-1 and gridSizeX if lon is
+// between the last and the first column of a global grid
+func lonPosition(lon float32, minLon float32, maxLon float32, gridSizeX uint64, wrap bool) float32 {
+	lon = NormalizeLongitude(lon, minLon)
+	if wrap && lon > maxLon {
+		// the gap around the globe may differ from the spacing of the columns (e.g. a column at 0 and 360 degree)
+		return float32(gridSizeX-1) + (lon-maxLon)/(minLon+360-maxLon)
+	}
+	return MapValue(lon, minLon, maxLon, 0, float32(gridSizeX-1))
+}
+
+// returns the 4 grid points around lat/lon in the regular grid with their bilinear weights, in the
+// order of positionCorners. Between a pole and the first or last row of a global grid the north
+// (or south) corners are the grid points of the row on the opposite meridian
+func latLonCorners(lat float32, lon float32, minLat float32, maxLat float32, minLon float32, maxLon float32, gridSizeX uint64, gridSizeY uint64, wrap bool) ([4]gridCorner, error) {
+	if gridSizeX < 2 || gridSizeY < 2 || math.IsNaN(float64(lat)) || math.IsNaN(float64(lon)) {
+		return [4]gridCorner{}, ErrOutOfGrid
+	}
+	wrap = wrap && IsGlobalLongitude(minLon, maxLon, gridSizeX)
+	x := lonPosition(lon, minLon, maxLon, gridSizeX, wrap)
+	y := MapValue(lat, minLat, maxLat, 0, float32(gridSizeY-1))
+	if y >= -gridEpsilon && y <= float32(gridSizeY-1)+gridEpsilon || !wrap {
+		return positionCorners(x, y, gridSizeX, gridSizeY, wrap)
 	}
 
-	values := make([]float32, len(constituentsNE))
+	// over the pole, the distance to the row on the opposite meridian is twice the distance of the row to the pole
+	spacing := (maxLat - minLat) / float32(gridSizeY-1)
+	row, pole := gridSizeY-1, float32(90)
+	if y < 0 {
+		row, pole = 0, -90
+	}
+	rowLat := minLat + float32(row)*spacing
+	polarGap := float32(math.Abs(float64(pole - rowLat)))
+	if polarGap == 0 || polarGap > spacing*(1+gridEpsilon) || math.Abs(float64(lat)) > 90 {
+		return [4]gridCorner{}, ErrOutOfGrid
+	}
+	weightOpposite := float32(math.Abs(float64(lat-rowLat))) / (2 * polarGap)
+	corners, err := positionCorners(x, float32(row), gridSizeX, gridSizeY, wrap)
+	if err != nil {
+		return [4]gridCorner{}, err
+	}
+	opposite, err := positionCorners(lonPosition(lon+180, minLon, maxLon, gridSizeX, wrap), float32(row), gridSizeX, gridSizeY, wrap)
+	if err != nil {
+		return [4]gridCorner{}, err
+	}
+	// the grid points on the opposite meridian take the place of the corners beyond the row
+	return [4]gridCorner{
+		{corners[0].x, row, corners[0].weight * (1 - weightOpposite)},
+		{corners[1].x, row, corners[1].weight * (1 - weightOpposite)},
+		{opposite[0].x, row, opposite[0].weight * weightOpposite},
+		{opposite[1].x, row, opposite[1].weight * weightOpposite},
+	}, nil
+}
 
-	for i := 0; i < len(constituentsNE); i++ {
+// returns the weighted mean of the values of the corners. Undefined corners are excluded from the weighting,
+// if the weight of the valid corners is not above 0.5 there is no valid data at the position (like in perth3.f)
+func weightedMean(corners [4]gridCorner, dataGrid Interpolatable) ([]float32, error) {
+	var values []float32
+	combinedWeight := float32(0)
+	for _, corner := range corners {
+		if corner.weight == 0 {
+			continue
+		}
+		cornerValues, err := dataGrid.GetDataXY(corner.x, corner.y)
+		if errors.Is(err, ErrUndefinedValue) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = make([]float32, len(cornerValues))
+		}
+		for i := 0; i < len(cornerValues); i++ {
+			values[i] = values[i] + corner.weight*cornerValues[i]
+		}
+		combinedWeight = combinedWeight + corner.weight
+	}
 
-		values[i] = ((weightNW * constituentsNW[i]) +
-			(weightNE * constituentsNE[i]) +
-			(weightSE * constituentsSE[i]) +
-			(weightSW * constituentsSW[i])) / combinedWeight
+	if combinedWeight <= 0.5 {
+		return nil, ErrUndefinedValue
+	}
 
+	for i := 0; i < len(values); i++ {
+		values[i] = values[i] / combinedWeight
 	}
 
 	return values, nil
 }
+
+// returns the values of the corner with the highest weight, ErrUndefinedValue if it has no data
+func nearestCorner(corners [4]gridCorner, dataGrid Interpolatable) ([]float32, error) {
+	nearest := 0
+	for i := range corners {
+		if corners[i].weight > corners[nearest].weight {
+			nearest = i
+		}
+	}
+	return dataGrid.GetDataXY(corners[nearest].x, corners[nearest].y)
+}
+
+// returns the column of the grid point index (may be outside of the grid), wrapped around the
+// grid if wrap is set, otherwise clamped to the first or last column
+func gridColumn(index int64, gridSizeX uint64, wrap bool) uint64 {
+	if wrap {
+		return uint64(((index % int64(gridSizeX)) + int64(gridSizeX)) % int64(gridSizeX))
+	}
+	return uint64(clampIndex(index, gridSizeX))
+}
+
+func clampIndex(index int64, size uint64) int64 {
+	if index < 0 {
+		return 0
+	} else if index >= int64(size) {
+		return int64(size) - 1
+	}
+	return index
+}
+
+// weights of the 4 grid points around a position t (0..1) between the second and third one
+func cubicWeights(t float32) [4]float32 {
+	t2 := t * t
+	t3 := t2 * t
+	return [4]float32{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
+	}
+}
+
+// interpolates bicubic (cubic convolution) at the fractional grid position x/y with the 4x4 grid points
+// around it. Rows beyond the first and last row (and columns if not wrapping) repeat the border, if one
+// of the grid points is undefined the position is interpolated bilinear
+func interpolateBicubic(x float32, y float32, gridSizeX uint64, gridSizeY uint64, dataGrid Interpolatable, wrap bool) ([]float32, error) {
+	x0 := int64(math.Floor(float64(x)))
+	y0 := int64(math.Floor(float64(y)))
+	weightsX := cubicWeights(x - float32(x0))
+	weightsY := cubicWeights(y - float32(y0))
+
+	var values []float32
+	for j := int64(0); j < 4; j++ {
+		row := uint64(clampIndex(y0-1+j, gridSizeY))
+		for i := int64(0); i < 4; i++ {
+			pointValues, err := dataGrid.GetDataXY(gridColumn(x0-1+i, gridSizeX, wrap), row)
+			if errors.Is(err, ErrUndefinedValue) {
+				return InterpolateGridPosition(x, y, gridSizeX, gridSizeY, dataGrid, wrap)
+			} else if err != nil {
+				return nil, err
+			}
+			if values == nil {
+				values = make([]float32, len(pointValues))
+			}
+			weight := weightsX[i] * weightsY[j]
+			for k := 0; k < len(pointValues); k++ {
+				values[k] = values[k] + weight*pointValues[k]
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// static kd-tree for nearest neighbour lookups of lat/lon points, e.g. the grid points of a curvilinear grid.
+// The points are stored as unit vectors, so distances are correct across the antimeridian and at the poles
+type KDTree struct {
+	points [][3]float64
+	// indices of the points, each subtree is split at its median
+	nodes []int
+}
+
+// creates a tree of the points lats[i]/lons[i], points with NaN coordinates are left out
+func NewKDTree(lats []float32, lons []float32) *KDTree {
+	tree := &KDTree{points: make([][3]float64, len(lats))}
+	for i := range lats {
+		if math.IsNaN(float64(lats[i])) || math.IsNaN(float64(lons[i])) {
+			continue
+		}
+		tree.points[i] = unitVector(lats[i], lons[i])
+		tree.nodes = append(tree.nodes, i)
+	}
+	tree.build(tree.nodes, 0)
+	return tree
+}
+
+func unitVector(lat float32, lon float32) [3]float64 {
+	latRad := float64(lat) * (math.Pi / 180)
+	lonRad := float64(lon) * (math.Pi / 180)
+	return [3]float64{math.Cos(latRad) * math.Cos(lonRad), math.Cos(latRad) * math.Sin(lonRad), math.Sin(latRad)}
+}
+
+func (k *KDTree) build(nodes []int, depth int) {
+	if len(nodes) <= 1 {
+		return
+	}
+	axis := depth % 3
+	sort.Slice(nodes, func(i, j int) bool {
+		return k.points[nodes[i]][axis] < k.points[nodes[j]][axis]
+	})
+	median := len(nodes) / 2
+	k.build(nodes[:median], depth+1)
+	k.build(nodes[median+1:], depth+1)
+}
+
+// returns the index of the point nearest to lat/lon, -1 if the tree is empty
+func (k *KDTree) Nearest(lat float32, lon float32) int {
+	return k.NearestWithin(lat, lon, 180, nil)
+}
+
+// returns the index of the point nearest to lat/lon within the angular distance radius (degree) for
+// which accept returns true (all points if accept is nil), -1 if there is no such point
+func (k *KDTree) NearestWithin(lat float32, lon float32, radius float64, accept func(index int) bool) int {
+	query := unitVector(lat, lon)
+	nearest := -1
+	// squared chord length of the radius, slightly widened for rounding
+	chord := 2 * math.Sin(math.Min(radius, 180)*(math.Pi/180)/2)
+	nearestDistance := chord*chord + 1e-12
+	k.search(k.nodes, 0, query, accept, &nearest, &nearestDistance)
+	return nearest
+}
+
+func (k *KDTree) search(nodes []int, depth int, query [3]float64, accept func(index int) bool, nearest *int, nearestDistance *float64) {
+	if len(nodes) == 0 {
+		return
+	}
+	median := len(nodes) / 2
+	point := k.points[nodes[median]]
+	distance := 0.0
+	for i := 0; i < 3; i++ {
+		distance = distance + (point[i]-query[i])*(point[i]-query[i])
+	}
+	if distance < *nearestDistance && (accept == nil || accept(nodes[median])) {
+		*nearest = nodes[median]
+		*nearestDistance = distance
+	}
+
+	axis := depth % 3
+	delta := query[axis] - point[axis]
+	near, far := nodes[:median], nodes[median+1:]
+	if delta > 0 {
+		near, far = far, near
+	}
+	k.search(near, depth+1, query, accept, nearest, nearestDistance)
+	// the other side can only contain a nearer point if the splitting plane is nearer
+	if delta*delta < *nearestDistance {
+		k.search(far, depth+1, query, accept, nearest, nearestDistance)
+	}
+}
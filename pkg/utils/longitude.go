@@ -0,0 +1,34 @@
+package utils
+
+import "math"
+
+// shifts lon by multiples of 360 degree into the longitudes from minLon to minLon+360, so locations in
+// the -180..180 convention are found in grids from 0 to 360 degree and the other way round
+func NormalizeLongitude(lon float32, minLon float32) float32 {
+	offset := math.Mod(float64(lon-minLon), 360)
+	if offset < 0 {
+		offset = offset + 360
+	}
+	return minLon + float32(offset)
+}
+
+// returns true if the gridSizeX columns from minLon to maxLon cover all longitudes, i.e. the gap between
+// the last and the first column around the globe is not larger than the spacing of the columns
+// (e.g. 0 to 359.75 in steps of 0.25 or -180 to 180 with the seam column twice)
+func IsGlobalLongitude(minLon float32, maxLon float32, gridSizeX uint64) bool {
+	if gridSizeX < 2 {
+		return false
+	}
+	spacing := math.Abs(float64(maxLon-minLon)) / float64(gridSizeX-1)
+	return math.Abs(float64(maxLon-minLon))+spacing >= 360-spacing/2
+}
+
+// returns true if the last of the gridSizeX columns from minLon to maxLon repeats the first one
+// around the globe (e.g. 0 to 360 or -180 to 180)
+func HasDuplicateSeamColumn(minLon float32, maxLon float32, gridSizeX uint64) bool {
+	if gridSizeX < 2 {
+		return false
+	}
+	spacing := math.Abs(float64(maxLon-minLon)) / float64(gridSizeX-1)
+	return math.Abs(math.Abs(float64(maxLon-minLon))-360) < spacing/2
+}
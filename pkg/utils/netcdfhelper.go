@@ -1,3 +1,5 @@
+//go:build cgo && !nonetcdf
+
 package utils
 
 import (
@@ -9,6 +11,7 @@ import (
 var (
 	ErrNetcdfAttributeNotFound = errors.New("attribute not found")
 	ErrNetcdfDimensionNotFound = errors.New("dimension not found")
+	ErrNetcdfUnsupportedType   = errors.New("unsupported variable type")
 )
 
 func NetcdfGetDimensionFromVariable(name string, variable *netcdf.Var) (*netcdf.Dim, error) {
@@ -48,6 +51,48 @@ func NetcdfGetFloat32FromAttribute(name string, variable *netcdf.Var) ([]float32
 
 }
 
+// reads the first value of a numeric attribute of any type
+func NetcdfGetFloat64FromAttribute(name string, variable *netcdf.Var) (float64, error) {
+	attr, err := NetcdfGetAttribute(name, variable)
+	if err != nil {
+		return 0, err
+	}
+	attrType, err := attr.Type()
+	if err != nil {
+		return 0, err
+	}
+	attrLen, err := attr.Len()
+	if err != nil {
+		return 0, err
+	}
+	if attrLen == 0 {
+		return 0, ErrNetcdfAttributeNotFound
+	}
+	switch attrType {
+	case netcdf.FLOAT:
+		buffer := make([]float32, attrLen)
+		err = attr.ReadFloat32s(buffer)
+		return float64(buffer[0]), err
+	case netcdf.DOUBLE:
+		buffer := make([]float64, attrLen)
+		err = attr.ReadFloat64s(buffer)
+		return buffer[0], err
+	case netcdf.INT:
+		buffer := make([]int32, attrLen)
+		err = attr.ReadInt32s(buffer)
+		return float64(buffer[0]), err
+	case netcdf.SHORT:
+		buffer := make([]int16, attrLen)
+		err = attr.ReadInt16s(buffer)
+		return float64(buffer[0]), err
+	case netcdf.BYTE:
+		buffer := make([]int8, attrLen)
+		err = attr.ReadInt8s(buffer)
+		return float64(buffer[0]), err
+	}
+	return 0, ErrNetcdfUnsupportedType
+}
+
 func NetcdfGetStringFromAttribute(name string, variable *netcdf.Var) (string, error) {
 	attr, err := NetcdfGetAttribute(name, variable)
 	if err != nil {
@@ -84,3 +129,75 @@ func NetcdfGetAttribute(name string, variable *netcdf.Var) (*netcdf.Attr, error)
 	}
 	return nil, ErrNetcdfAttributeNotFound
 }
+
+// reads a slice of a numeric variable of any type converted to float32
+func NetcdfReadFloat32Slice(variable *netcdf.Var, start []uint64, count []uint64) ([]float32, error) {
+	variableType, err := variable.Type()
+	if err != nil {
+		return nil, err
+	}
+	length := uint64(1)
+	for _, c := range count {
+		length = length * c
+	}
+	data := make([]float32, length)
+	switch variableType {
+	case netcdf.FLOAT:
+		err = variable.ReadFloat32Slice(data, start, count)
+	case netcdf.DOUBLE:
+		buffer := make([]float64, length)
+		err = variable.ReadFloat64Slice(buffer, start, count)
+		for i, value := range buffer {
+			data[i] = float32(value)
+		}
+	case netcdf.INT:
+		buffer := make([]int32, length)
+		err = variable.ReadInt32Slice(buffer, start, count)
+		for i, value := range buffer {
+			data[i] = float32(value)
+		}
+	case netcdf.SHORT:
+		buffer := make([]int16, length)
+		err = variable.ReadInt16Slice(buffer, start, count)
+		for i, value := range buffer {
+			data[i] = float32(value)
+		}
+	case netcdf.BYTE:
+		buffer := make([]int8, length)
+		err = variable.ReadInt8Slice(buffer, start, count)
+		for i, value := range buffer {
+			data[i] = float32(value)
+		}
+	default:
+		return nil, ErrNetcdfUnsupportedType
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// reads a whole numeric variable of any type converted to float32
+func NetcdfReadFloat32s(variable *netcdf.Var) ([]float32, error) {
+	count, err := variable.LenDims()
+	if err != nil {
+		return nil, err
+	}
+	return NetcdfReadFloat32Slice(variable, make([]uint64, len(count)), count)
+}
+
+// returns the names of the dimensions of a variable in order
+func NetcdfGetDimensionNames(variable *netcdf.Var) ([]string, error) {
+	dims, err := variable.Dims()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(dims))
+	for i, dim := range dims {
+		names[i], err = dim.Name()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
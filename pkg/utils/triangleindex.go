@@ -0,0 +1,146 @@
+package utils
+
+import "math"
+
+// spatial index of the triangles of a mesh (e.g. a finite element model) to find the triangle containing
+// a location. The triangles are sorted into the cells of a regular lat/lon grid over the mesh, a lookup
+// only tests the triangles of one cell. Triangles crossing the antimeridian are supported
+type TriangleIndex struct {
+	lats      []float32
+	lons      []float32
+	triangles []uint32
+
+	minLat   float64
+	minLon   float64
+	maxLon   float64
+	cellSize float64
+	sizeX    int
+	sizeY    int
+	// triangles of cell i are cellTriangles[cellStart[i]:cellStart[i+1]]
+	cellStart     []uint32
+	cellTriangles []uint32
+}
+
+// returns the longitude of lon relative to reference in [reference-180, reference+180)
+func unwrapLongitude(lon float64, reference float64) float64 {
+	return reference + math.Mod(math.Mod(lon-reference+180, 360)+360, 360) - 180
+}
+
+// creates the index of the triangles (three indices into lats/lons per triangle)
+func NewTriangleIndex(lats []float32, lons []float32, triangles []uint32) *TriangleIndex {
+	index := &TriangleIndex{lats: lats, lons: lons, triangles: triangles}
+	numberTriangles := len(triangles) / 3
+
+	// the longitudes of a triangle are unwrapped relative to its first node
+	bounds := make([][4]float64, numberTriangles)
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for t := 0; t < numberTriangles; t++ {
+		bound := [4]float64{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+		reference := float64(lons[triangles[3*t]])
+		for k := 0; k < 3; k++ {
+			lat := float64(lats[triangles[3*t+k]])
+			lon := unwrapLongitude(float64(lons[triangles[3*t+k]]), reference)
+			bound = [4]float64{math.Min(bound[0], lat), math.Max(bound[1], lat), math.Min(bound[2], lon), math.Max(bound[3], lon)}
+		}
+		bounds[t] = bound
+		minLat, maxLat = math.Min(minLat, bound[0]), math.Max(maxLat, bound[1])
+		minLon, maxLon = math.Min(minLon, bound[2]), math.Max(maxLon, bound[3])
+	}
+	if numberTriangles == 0 || math.IsNaN(minLat+minLon) {
+		index.cellStart = []uint32{0, 0}
+		index.sizeX, index.sizeY, index.cellSize = 1, 1, 1
+		return index
+	}
+
+	// about one triangle per cell
+	area := math.Max((maxLat-minLat)*(maxLon-minLon), 1e-12)
+	index.cellSize = math.Max(math.Sqrt(area/float64(numberTriangles)), 1e-6)
+	index.sizeX = int((maxLon-minLon)/index.cellSize) + 1
+	index.sizeY = int((maxLat-minLat)/index.cellSize) + 1
+	index.minLat, index.minLon, index.maxLon = minLat, minLon, maxLon
+
+	// counting pass and filling pass over the cells covered by the bounds of the triangles
+	counts := make([]uint32, index.sizeX*index.sizeY+1)
+	forEachCell := func(bound [4]float64, apply func(cell int)) {
+		x0, y0 := index.cellXY(bound[0], bound[2])
+		x1, y1 := index.cellXY(bound[1], bound[3])
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				apply(y*index.sizeX + x)
+			}
+		}
+	}
+	for _, bound := range bounds {
+		forEachCell(bound, func(cell int) { counts[cell+1]++ })
+	}
+	for i := 1; i < len(counts); i++ {
+		counts[i] = counts[i] + counts[i-1]
+	}
+	index.cellStart = counts
+	index.cellTriangles = make([]uint32, counts[len(counts)-1])
+	next := append([]uint32{}, counts[:len(counts)-1]...)
+	for t, bound := range bounds {
+		forEachCell(bound, func(cell int) {
+			index.cellTriangles[next[cell]] = uint32(t)
+			next[cell]++
+		})
+	}
+	return index
+}
+
+// returns the cell of lat/lon (unwrapped to the extent of the index), clamped to the grid
+func (t *TriangleIndex) cellXY(lat float64, lon float64) (int, int) {
+	x := int((lon - t.minLon) / t.cellSize)
+	y := int((lat - t.minLat) / t.cellSize)
+	if x < 0 {
+		x = 0
+	} else if x >= t.sizeX {
+		x = t.sizeX - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= t.sizeY {
+		y = t.sizeY - 1
+	}
+	return x, y
+}
+
+// returns the triangle containing lat/lon and the barycentric weights of its three nodes,
+// false if the location is outside of the mesh
+func (t *TriangleIndex) Locate(lat float32, lon float32) (int, [3]float32, bool) {
+	for _, candidate := range []float64{float64(lon), float64(lon) - 360, float64(lon) + 360} {
+		if candidate < t.minLon-t.cellSize || candidate > t.maxLon+t.cellSize {
+			continue
+		}
+		x, y := t.cellXY(float64(lat), candidate)
+		cell := y*t.sizeX + x
+		for _, triangle := range t.cellTriangles[t.cellStart[cell]:t.cellStart[cell+1]] {
+			if weights, ok := t.barycentric(int(triangle), float64(lat), candidate); ok {
+				return int(triangle), weights, true
+			}
+		}
+	}
+	return -1, [3]float32{}, false
+}
+
+// returns the barycentric weights of lat/lon in the triangle, false if the location is outside of it
+func (t *TriangleIndex) barycentric(triangle int, lat float64, lon float64) ([3]float32, bool) {
+	const epsilon = 1e-9
+	var points [3][2]float64
+	for k := 0; k < 3; k++ {
+		node := t.triangles[3*triangle+k]
+		points[k] = [2]float64{unwrapLongitude(float64(t.lons[node]), lon), float64(t.lats[node])}
+	}
+	determinant := (points[1][1]-points[2][1])*(points[0][0]-points[2][0]) + (points[2][0]-points[1][0])*(points[0][1]-points[2][1])
+	if determinant == 0 || math.IsNaN(determinant) {
+		return [3]float32{}, false
+	}
+	weight0 := ((points[1][1]-points[2][1])*(lon-points[2][0]) + (points[2][0]-points[1][0])*(lat-points[2][1])) / determinant
+	weight1 := ((points[2][1]-points[0][1])*(lon-points[2][0]) + (points[0][0]-points[2][0])*(lat-points[2][1])) / determinant
+	weight2 := 1 - weight0 - weight1
+	if weight0 < -epsilon || weight1 < -epsilon || weight2 < -epsilon {
+		return [3]float32{}, false
+	}
+	return [3]float32{float32(weight0), float32(weight1), float32(weight2)}, true
+}